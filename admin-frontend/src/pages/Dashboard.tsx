@@ -8,6 +8,8 @@ const Dashboard: React.FC = () => {
   const handleLogout = () => {
     localStorage.removeItem('adminAuth');
     localStorage.removeItem('adminUser');
+    localStorage.removeItem('adminToken');
+    localStorage.removeItem('adminRole');
     navigate('/login');
   };
 
@@ -20,6 +20,8 @@ const Login: React.FC = () => {
       const response = await adminAPI.login(username, password);
       if (response.data.success) {
         localStorage.setItem('adminAuth', 'true');
+        localStorage.setItem('adminToken', response.data.token);
+        localStorage.setItem('adminRole', response.data.user.role);
         localStorage.setItem('adminUser', username);
         navigate('/');
       }
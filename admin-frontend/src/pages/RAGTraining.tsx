@@ -10,21 +10,100 @@ interface RAGDocument {
   category: string;
   location?: string;
   tags: string[];
+  status: 'draft' | 'pending_review' | 'published' | 'archived';
+  review?: {
+    reviewer: string;
+    decision: string;
+    comment?: string;
+  };
+  source?: {
+    file_name: string;
+    row?: number;
+  };
+  // Set for documents generated from a database row, which are kept in
+  // sync with it and can't be edited here
+  record?: {
+    table: string;
+    id?: string;
+  };
+  effective_from?: string;
+  expires_at?: string;
+  published_at?: string;
+}
+
+interface UploadResult {
+  file: string;
+  status: 'ingested' | 'duplicate' | 'failed';
+  documents?: string[];
+  duplicates?: string[];
+  error?: string;
+}
+
+interface ImportReport {
+  created: string[];
+  updated: string[];
+  unchanged: string[];
+  deleted: string[];
+  skipped: string[];
+  errors: { line: number; id?: string; error: string }[];
+}
+
+interface DocumentVersion {
+  version: number;
+  action: 'create' | 'update' | 'delete' | 'rollback' | 'submit' | 'approve' | 'reject' | 'archive';
+  author: string;
+  timestamp: string;
+  restored_from?: number;
+  comment?: string;
 }
 
+const emptyForm = {
+  title: '',
+  content: '',
+  category: '',
+  location: '',
+  tags: '',
+  effectiveFrom: '',
+  expiresAt: '',
+  submit: false,
+};
+
+// Stored dates are RFC 3339; the date inputs take the day only
+const dateInput = (value?: string) => (value ? value.slice(0, 10) : '');
+
+const emptyUpload = {
+  submit: false,
+  category: '',
+  location: '',
+  tags: '',
+  titleColumn: '',
+  contentColumns: '',
+  categoryColumn: '',
+  locationColumn: '',
+  tagsColumn: '',
+};
+
 const RAGTraining: React.FC = () => {
   const navigate = useNavigate();
   const [documents, setDocuments] = useState<RAGDocument[]>([]);
   const [loading, setLoading] = useState(true);
   const [editingDoc, setEditingDoc] = useState<RAGDocument | null>(null);
   const [showForm, setShowForm] = useState(false);
-  const [formData, setFormData] = useState({
-    title: '',
-    content: '',
-    category: '',
-    location: '',
-    tags: '',
-  });
+  const [formData, setFormData] = useState(emptyForm);
+  const [statusFilter, setStatusFilter] = useState('');
+  // Reasons each document is due for review, while the stale report is shown
+  const [staleReasons, setStaleReasons] = useState<Record<string, string[]> | null>(null);
+  const isReviewer = localStorage.getItem('adminRole') === 'reviewer';
+  const [showUpload, setShowUpload] = useState(false);
+  const [uploadFiles, setUploadFiles] = useState<File[]>([]);
+  const [uploadData, setUploadData] = useState(emptyUpload);
+  const [uploading, setUploading] = useState(false);
+  const [uploadResults, setUploadResults] = useState<UploadResult[]>([]);
+  const [importMode, setImportMode] = useState('merge');
+  const [importConflict, setImportConflict] = useState('skip');
+  const [historyDoc, setHistoryDoc] = useState<RAGDocument | null>(null);
+  const [versions, setVersions] = useState<DocumentVersion[]>([]);
+  const [diff, setDiff] = useState<{ version: number; text: string } | null>(null);
 
   useEffect(() => {
     fetchDocuments();
@@ -52,6 +131,9 @@ const RAGTraining: React.FC = () => {
         category: formData.category,
         location: formData.location || undefined,
         tags,
+        effective_from: formData.effectiveFrom || undefined,
+        expires_at: formData.expiresAt || undefined,
+        submit: formData.submit,
       };
 
       if (editingDoc) {
@@ -62,7 +144,7 @@ const RAGTraining: React.FC = () => {
 
       setShowForm(false);
       setEditingDoc(null);
-      setFormData({ title: '', content: '', category: '', location: '', tags: '' });
+      setFormData(emptyForm);
       fetchDocuments();
     } catch (error: any) {
       console.error('Failed to save document:', error);
@@ -78,6 +160,9 @@ const RAGTraining: React.FC = () => {
       category: doc.category,
       location: doc.location || '',
       tags: doc.tags.join(', '),
+      effectiveFrom: dateInput(doc.effective_from),
+      expiresAt: dateInput(doc.expires_at),
+      submit: false,
     });
     setShowForm(true);
   };
@@ -95,6 +180,168 @@ const RAGTraining: React.FC = () => {
     }
   };
 
+  const openHistory = async (doc: RAGDocument) => {
+    setHistoryDoc(doc);
+    setVersions([]);
+    setDiff(null);
+    try {
+      const response = await adminAPI.getRAGHistory(doc.id);
+      setVersions(response.data.versions || []);
+    } catch (error: any) {
+      console.error('Failed to fetch history:', error);
+    }
+  };
+
+  const showDiff = async (version: number) => {
+    if (!historyDoc) {
+      return;
+    }
+    try {
+      const response = await adminAPI.diffRAGVersions(historyDoc.id, version - 1, version);
+      const { fields, content } = response.data.diff;
+      const fieldLines = Object.entries(fields).map(
+        ([name, change]: [string, any]) => `${name}: ${JSON.stringify(change.from)} → ${JSON.stringify(change.to)}`
+      );
+      setDiff({ version, text: [...fieldLines, content].filter(t => t).join('\n') || 'No changes' });
+    } catch (error: any) {
+      console.error('Failed to diff versions:', error);
+    }
+  };
+
+  const handleRollback = async (version: number) => {
+    if (!historyDoc || !window.confirm(`Restore version ${version} of this document?`)) {
+      return;
+    }
+    try {
+      await adminAPI.rollbackRAGDocument(historyDoc.id, version);
+      openHistory(historyDoc);
+      fetchDocuments();
+    } catch (error: any) {
+      console.error('Failed to roll back document:', error);
+      alert('Failed to roll back document: ' + (error.response?.data?.error || error.message));
+    }
+  };
+
+  const handleReview = async (doc: RAGDocument, action: 'submit' | 'approve' | 'reject' | 'archive') => {
+    let comment: string | undefined;
+    if (action === 'approve' || action === 'reject') {
+      const input = window.prompt(action === 'reject' ? 'Why is this document rejected?' : 'Comment (optional)');
+      if (input === null || (action === 'reject' && !input.trim())) {
+        return;
+      }
+      comment = input.trim() || undefined;
+    } else if (action === 'archive' && !window.confirm('Archive this document? It will no longer be used in answers.')) {
+      return;
+    }
+    try {
+      await adminAPI.reviewRAGDocument(doc.id, action, comment);
+      fetchDocuments();
+    } catch (error: any) {
+      console.error(`Failed to ${action} document:`, error);
+      alert(`Failed to ${action} document: ` + (error.response?.data?.error || error.message));
+    }
+  };
+
+  const handleExport = async () => {
+    try {
+      const response = await adminAPI.exportRAG(true);
+      const url = URL.createObjectURL(response.data);
+      const link = document.createElement('a');
+      link.href = url;
+      link.download = `rag-export-${new Date().toISOString().slice(0, 10)}.jsonl`;
+      link.click();
+      URL.revokeObjectURL(url);
+    } catch (error: any) {
+      console.error('Failed to export documents:', error);
+      alert('Failed to export documents: ' + error.message);
+    }
+  };
+
+  // Imports run as a dry run first, and are applied once the summary of
+  // what would change is confirmed
+  const handleImport = async (file: File) => {
+    const summarize = (r: ImportReport) =>
+      `${r.created.length} created, ${r.updated.length} updated, ${r.deleted.length} deleted, ` +
+      `${r.unchanged.length} unchanged, ${r.skipped.length} skipped`;
+    const params = { mode: importMode, on_conflict: importConflict };
+    try {
+      const dryRun = await adminAPI.importRAG(file, { ...params, dry_run: true });
+      const report: ImportReport = dryRun.data.report;
+      if (report.errors.length > 0) {
+        alert('Nothing can be imported:\n' + report.errors.map(e => `line ${e.line}: ${e.error}`).join('\n'));
+        return;
+      }
+      if (!window.confirm(`Import ${file.name}? ${summarize(report)}.`)) {
+        return;
+      }
+      const response = await adminAPI.importRAG(file, { ...params, dry_run: false });
+      alert('Imported: ' + summarize(response.data.report));
+      fetchDocuments();
+    } catch (error: any) {
+      console.error('Failed to import documents:', error);
+      alert('Failed to import documents: ' + (error.response?.data?.error || error.message));
+    }
+  };
+
+  const toggleStaleReport = async () => {
+    if (staleReasons) {
+      setStaleReasons(null);
+      return;
+    }
+    try {
+      const response = await adminAPI.getStaleRAGDocuments();
+      const reasons: Record<string, string[]> = {};
+      (response.data.documents || []).forEach((s: { document: RAGDocument; reasons: string[] }) => {
+        reasons[s.document.id] = s.reasons;
+      });
+      setStaleReasons(reasons);
+    } catch (error: any) {
+      console.error('Failed to fetch stale documents:', error);
+    }
+  };
+
+  const visibleDocuments = documents.filter(
+    d => (!statusFilter || d.status === statusFilter) && (!staleReasons || staleReasons[d.id])
+  );
+
+  const hasCSV = uploadFiles.some(f => f.name.toLowerCase().endsWith('.csv'));
+
+  const openUpload = () => {
+    setShowUpload(true);
+    setUploadFiles([]);
+    setUploadData(emptyUpload);
+    setUploadResults([]);
+  };
+
+  const handleUpload = async (e: React.FormEvent) => {
+    e.preventDefault();
+    const data = new FormData();
+    uploadFiles.forEach(file => data.append('file', file));
+    data.append('category', uploadData.category);
+    data.append('location', uploadData.location);
+    data.append('tags', uploadData.tags);
+    data.append('submit', String(uploadData.submit));
+    if (hasCSV) {
+      data.append('title_column', uploadData.titleColumn);
+      data.append('content_columns', uploadData.contentColumns);
+      data.append('category_column', uploadData.categoryColumn);
+      data.append('location_column', uploadData.locationColumn);
+      data.append('tags_column', uploadData.tagsColumn);
+    }
+
+    setUploading(true);
+    try {
+      const response = await adminAPI.uploadRAGFiles(data);
+      setUploadResults(response.data.files || []);
+      fetchDocuments();
+    } catch (error: any) {
+      console.error('Failed to upload files:', error);
+      alert('Failed to upload files: ' + (error.response?.data?.error || error.message));
+    } finally {
+      setUploading(false);
+    }
+  };
+
   const categories = ['crime_stats', 'locations', 'perps', 'strategy', 'history'];
 
   if (loading) {
@@ -113,9 +360,51 @@ const RAGTraining: React.FC = () => {
         </button>
         <h1>RAG Data Training</h1>
         <p>Format and manage RAG documents for AI training</p>
-        <button onClick={() => { setShowForm(true); setEditingDoc(null); setFormData({ title: '', content: '', category: '', location: '', tags: '' }); }} className="add-button">
+        <button onClick={() => { setShowForm(true); setEditingDoc(null); setFormData(emptyForm); }} className="add-button">
           + Add New Document
         </button>
+        <button onClick={openUpload} className="add-button">
+          ⬆ Upload Files
+        </button>
+        <select value={statusFilter} onChange={(e) => setStatusFilter(e.target.value)} className="status-filter">
+          <option value="">All statuses</option>
+          <option value="draft">Drafts</option>
+          <option value="pending_review">Pending review</option>
+          <option value="published">Published</option>
+          <option value="archived">Archived</option>
+        </select>
+        <button onClick={toggleStaleReport} className="add-button">
+          {staleReasons ? 'Show All Documents' : '⏰ Due for Review'}
+        </button>
+        <button onClick={handleExport} className="add-button">
+          ⬇ Export
+        </button>
+        <select value={importMode} onChange={(e) => setImportMode(e.target.value)} className="status-filter">
+          <option value="merge">Import: merge</option>
+          <option value="replace">Import: replace all</option>
+        </select>
+        {importMode === 'merge' && (
+          <select value={importConflict} onChange={(e) => setImportConflict(e.target.value)} className="status-filter">
+            <option value="skip">Existing IDs: skip</option>
+            <option value="overwrite">Existing IDs: overwrite</option>
+            <option value="rename">Existing IDs: import as new</option>
+          </select>
+        )}
+        <label className="add-button">
+          ⬆ Import
+          <input
+            type="file"
+            accept=".jsonl"
+            hidden
+            onChange={(e) => {
+              const file = e.target.files?.[0];
+              e.target.value = '';
+              if (file) {
+                handleImport(file);
+              }
+            }}
+          />
+        </label>
       </header>
 
       {showForm && (
@@ -172,6 +461,32 @@ const RAGTraining: React.FC = () => {
                   placeholder="e.g., crime, statistics, olathe"
                 />
               </div>
+              <div className="form-group">
+                <label>Effective from (optional)</label>
+                <input
+                  type="date"
+                  value={formData.effectiveFrom}
+                  onChange={(e) => setFormData({ ...formData, effectiveFrom: e.target.value })}
+                />
+              </div>
+              <div className="form-group">
+                <label>Expires (optional)</label>
+                <input
+                  type="date"
+                  value={formData.expiresAt}
+                  onChange={(e) => setFormData({ ...formData, expiresAt: e.target.value })}
+                />
+              </div>
+              <div className="form-group">
+                <label>
+                  <input
+                    type="checkbox"
+                    checked={formData.submit}
+                    onChange={(e) => setFormData({ ...formData, submit: e.target.checked })}
+                  />
+                  {' '}Submit for review (otherwise saved as a draft)
+                </label>
+              </div>
               <div className="form-actions">
                 <button type="submit" className="save-button">
                   {editingDoc ? 'Update' : 'Create'} Document
@@ -185,23 +500,221 @@ const RAGTraining: React.FC = () => {
         </div>
       )}
 
+      {showUpload && (
+        <div className="form-overlay">
+          <div className="form-container">
+            <h2>Upload Files</h2>
+            <form onSubmit={handleUpload}>
+              <div className="form-group">
+                <label>Files (PDF, DOCX, Markdown, HTML, TXT, CSV)</label>
+                <input
+                  type="file"
+                  multiple
+                  accept=".pdf,.docx,.md,.markdown,.html,.htm,.txt,.csv"
+                  onChange={(e) => setUploadFiles(Array.from(e.target.files || []))}
+                  required
+                />
+              </div>
+              <div className="form-group">
+                <label>Category</label>
+                <select
+                  value={uploadData.category}
+                  onChange={(e) => setUploadData({ ...uploadData, category: e.target.value })}
+                  required={!uploadData.categoryColumn}
+                >
+                  <option value="">Select category</option>
+                  {categories.map(cat => (
+                    <option key={cat} value={cat}>{cat}</option>
+                  ))}
+                </select>
+              </div>
+              <div className="form-group">
+                <label>Location (optional)</label>
+                <input
+                  type="text"
+                  value={uploadData.location}
+                  onChange={(e) => setUploadData({ ...uploadData, location: e.target.value })}
+                  placeholder="e.g., Olathe, KS"
+                />
+              </div>
+              <div className="form-group">
+                <label>Tags (comma-separated)</label>
+                <input
+                  type="text"
+                  value={uploadData.tags}
+                  onChange={(e) => setUploadData({ ...uploadData, tags: e.target.value })}
+                />
+              </div>
+              <div className="form-group">
+                <label>
+                  <input
+                    type="checkbox"
+                    checked={uploadData.submit}
+                    onChange={(e) => setUploadData({ ...uploadData, submit: e.target.checked })}
+                  />
+                  {' '}Submit for review (otherwise saved as drafts)
+                </label>
+              </div>
+              {hasCSV && (
+                <fieldset className="csv-mapping">
+                  <legend>CSV column mapping</legend>
+                  <div className="form-group">
+                    <label>Title column</label>
+                    <input
+                      type="text"
+                      value={uploadData.titleColumn}
+                      onChange={(e) => setUploadData({ ...uploadData, titleColumn: e.target.value })}
+                      required
+                    />
+                  </div>
+                  <div className="form-group">
+                    <label>Content columns (comma-separated, blank for all others)</label>
+                    <input
+                      type="text"
+                      value={uploadData.contentColumns}
+                      onChange={(e) => setUploadData({ ...uploadData, contentColumns: e.target.value })}
+                    />
+                  </div>
+                  <div className="form-group">
+                    <label>Category column (optional)</label>
+                    <input
+                      type="text"
+                      value={uploadData.categoryColumn}
+                      onChange={(e) => setUploadData({ ...uploadData, categoryColumn: e.target.value })}
+                    />
+                  </div>
+                  <div className="form-group">
+                    <label>Location column (optional)</label>
+                    <input
+                      type="text"
+                      value={uploadData.locationColumn}
+                      onChange={(e) => setUploadData({ ...uploadData, locationColumn: e.target.value })}
+                    />
+                  </div>
+                  <div className="form-group">
+                    <label>Tags column (optional)</label>
+                    <input
+                      type="text"
+                      value={uploadData.tagsColumn}
+                      onChange={(e) => setUploadData({ ...uploadData, tagsColumn: e.target.value })}
+                    />
+                  </div>
+                </fieldset>
+              )}
+              {uploadResults.length > 0 && (
+                <ul className="upload-results">
+                  {uploadResults.map((result, idx) => (
+                    <li key={idx} className={`upload-${result.status}`}>
+                      <strong>{result.file}</strong>: {result.status}
+                      {result.documents && ` (${result.documents.length} added)`}
+                      {result.duplicates && ` (${result.duplicates.length} already in the knowledge base)`}
+                      {result.error && ` - ${result.error}`}
+                    </li>
+                  ))}
+                </ul>
+              )}
+              <div className="form-actions">
+                <button type="submit" className="save-button" disabled={uploading}>
+                  {uploading ? 'Uploading...' : 'Upload'}
+                </button>
+                <button type="button" onClick={() => setShowUpload(false)} className="cancel-button">
+                  Close
+                </button>
+              </div>
+            </form>
+          </div>
+        </div>
+      )}
+
+      {historyDoc && (
+        <div className="form-overlay">
+          <div className="form-container">
+            <h2>History: {historyDoc.title}</h2>
+            <ul className="version-list">
+              {versions.map((v) => (
+                <li key={v.version}>
+                  <strong>v{v.version}</strong> {v.action}
+                  {v.restored_from && ` of v${v.restored_from}`} by {v.author} on {new Date(v.timestamp).toLocaleString()}
+                  {v.comment && `: "${v.comment}"`}
+                  {v.version > 1 && (
+                    <button onClick={() => showDiff(v.version)} className="edit-button">Diff</button>
+                  )}
+                  {!historyDoc.record && v.version < versions.length && v.action !== 'delete' && (
+                    <button onClick={() => handleRollback(v.version)} className="edit-button">Restore</button>
+                  )}
+                </li>
+              ))}
+            </ul>
+            {diff && (
+              <>
+                <h3>Changes in v{diff.version}</h3>
+                <pre className="version-diff">{diff.text}</pre>
+              </>
+            )}
+            <div className="form-actions">
+              <button type="button" onClick={() => setHistoryDoc(null)} className="cancel-button">
+                Close
+              </button>
+            </div>
+          </div>
+        </div>
+      )}
+
       <div className="documents-list">
-        {documents.length === 0 ? (
+        {visibleDocuments.length === 0 ? (
           <div className="no-data">No RAG documents found. Create your first document!</div>
         ) : (
-          documents.map((doc) => (
+          visibleDocuments.map((doc) => (
             <div key={doc.id} className="document-card">
               <div className="document-header">
                 <h3>{doc.title}</h3>
                 <div className="document-actions">
-                  <button onClick={() => handleEdit(doc)} className="edit-button">Edit</button>
-                  <button onClick={() => handleDelete(doc.id)} className="delete-button">Delete</button>
+                  <button onClick={() => openHistory(doc)} className="edit-button">History</button>
+                  {!doc.record && (
+                    <>
+                      {doc.status === 'draft' && (
+                        <button onClick={() => handleReview(doc, 'submit')} className="edit-button">Submit</button>
+                      )}
+                      {doc.status === 'pending_review' && isReviewer && (
+                        <>
+                          <button onClick={() => handleReview(doc, 'approve')} className="save-button">Approve</button>
+                          <button onClick={() => handleReview(doc, 'reject')} className="delete-button">Reject</button>
+                        </>
+                      )}
+                      {doc.status !== 'archived' && (
+                        <button onClick={() => handleReview(doc, 'archive')} className="edit-button">Archive</button>
+                      )}
+                      <button onClick={() => handleEdit(doc)} className="edit-button">Edit</button>
+                      <button onClick={() => handleDelete(doc.id)} className="delete-button">Delete</button>
+                    </>
+                  )}
                 </div>
               </div>
               <div className="document-meta">
+                <span className={`status status-${doc.status}`}>{doc.status.replace('_', ' ')}</span>
                 <span className="category">{doc.category}</span>
                 {doc.location && <span className="location">📍 {doc.location}</span>}
+                {doc.source && (
+                  <a href={adminAPI.ragFileURL(doc.id)} className="source-file">
+                    📎 {doc.source.file_name}{doc.source.row ? ` (row ${doc.source.row})` : ''}
+                  </a>
+                )}
+                {doc.record && (
+                  <span className="record-source">
+                    🗄 {doc.record.table}{doc.record.id ? ` / ${doc.record.id}` : ''} (synced)
+                  </span>
+                )}
+                {doc.published_at && <span className="dates">Published {dateInput(doc.published_at)}</span>}
+                {doc.expires_at && <span className="dates">Expires {dateInput(doc.expires_at)}</span>}
+                {staleReasons?.[doc.id] && (
+                  <span className="stale-reasons">{staleReasons[doc.id].join(', ').replace(/_/g, ' ')}</span>
+                )}
               </div>
+              {doc.review?.comment && (
+                <p className="review-comment">
+                  {doc.review.decision === 'reject' ? 'Rejected' : 'Approved'} by {doc.review.reviewer}: {doc.review.comment}
+                </p>
+              )}
               <p className="document-content">{doc.content}</p>
               <div className="document-tags">
                 {doc.tags.map((tag, idx) => (
@@ -14,6 +14,15 @@ const api = axios.create({
   },
 });
 
+// Send the admin session so RAG changes are recorded under the admin
+api.interceptors.request.use((config) => {
+  const token = localStorage.getItem('adminToken');
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`;
+  }
+  return config;
+});
+
 export const adminAPI = {
   // Admin authentication
   login: (username: string, password: string) => 
@@ -41,6 +50,9 @@ export const adminAPI = {
     category: string;
     location?: string;
     tags: string[];
+    effective_from?: string;
+    expires_at?: string;
+    submit?: boolean;
   }) => api.post('/rag/documents', data),
   updateRAGDocument: (id: string, data: {
     title: string;
@@ -48,8 +60,32 @@ export const adminAPI = {
     category: string;
     location?: string;
     tags: string[];
+    effective_from?: string;
+    expires_at?: string;
+    submit?: boolean;
   }) => api.put(`/rag/documents/${id}`, data),
   deleteRAGDocument: (id: string) => api.delete(`/rag/documents/${id}`),
+  // Multipart upload of PDF, DOCX, Markdown, HTML, text and CSV files
+  uploadRAGFiles: (data: FormData) =>
+    api.post('/rag/upload', data, { headers: { 'Content-Type': 'multipart/form-data' } }),
+  ragFileURL: (id: string) => `${API_BASE_URL}/rag/documents/${id}/file`,
+  // Review workflow: submit and archive by editors, approve and reject by reviewers
+  reviewRAGDocument: (id: string, action: 'submit' | 'approve' | 'reject' | 'archive', comment?: string) =>
+    api.post(`/rag/documents/${id}/${action}`, comment ? { comment } : undefined),
+  // Version history
+  getRAGHistory: (id: string) => api.get(`/rag/documents/${id}/history`),
+  diffRAGVersions: (id: string, from: number, to: number) =>
+    api.get(`/rag/documents/${id}/diff`, { params: { from, to } }),
+  rollbackRAGDocument: (id: string, version: number) =>
+    api.post(`/rag/documents/${id}/rollback`, { version }),
+  // JSONL snapshots for moving the knowledge base between environments
+  exportRAG: (embeddings: boolean) =>
+    api.get('/rag/export', { params: { embeddings }, responseType: 'blob' }),
+  importRAG: (file: File, params: { mode: string; on_conflict: string; dry_run: boolean }) =>
+    api.post('/rag/import', file, { params, headers: { 'Content-Type': 'application/x-ndjson' } }),
+  // Documents expired, expiring within the window or due for re-review
+  getStaleRAGDocuments: (withinDays = 30) =>
+    api.get('/rag/reports/stale', { params: { within_days: withinDays } }),
 };
 
 export default api;
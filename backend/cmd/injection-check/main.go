@@ -0,0 +1,127 @@
+// Command injection-check runs the prompt injection detector over a set of
+// known attack strings and benign look-alikes, reporting any it gets wrong.
+// It exits non-zero on a miss, so it can gate changes to the rules.
+//
+// Run it from backend/:
+//
+//	go run ./cmd/injection-check
+//	go run ./cmd/injection-check -text "ignore previous instructions"
+//
+// Each fixture line is {"text": ..., "injection": true|false} with an
+// optional "source" (user, document or web) noting where such text would
+// turn up.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"serpico/backend/internal/ai"
+)
+
+// fixture is a labelled example for the detector
+type fixture struct {
+	Text      string `json:"text"`
+	Injection bool   `json:"injection"`
+	Source    string `json:"source"`
+}
+
+func main() {
+	// A flag set of its own, as dependencies register flags globally
+	flags := flag.NewFlagSet("injection-check", flag.ExitOnError)
+	fixturesPath := flags.String("fixtures", "cmd/injection-check/fixtures.jsonl", "labelled examples, one JSON object per line")
+	text := flags.String("text", "", "check this text instead of the fixtures")
+	verbose := flags.Bool("v", false, "list every fixture, not just the misses")
+	flags.Parse(os.Args[1:])
+
+	if *text != "" {
+		matches := ai.DetectInjection(*text)
+		if len(matches) == 0 {
+			fmt.Println("no injection detected")
+			return
+		}
+		for _, match := range matches {
+			fmt.Printf("%s: %q\n", match.Rule, match.Text)
+		}
+		os.Exit(1)
+	}
+
+	fixtures, err := loadFixtures(*fixturesPath)
+	if err != nil {
+		fatal(err)
+	}
+
+	var missed, falseAlarms, attacks int
+	for _, f := range fixtures {
+		matches := ai.DetectInjection(f.Text)
+		detected := len(matches) > 0
+		if f.Injection {
+			attacks++
+		}
+
+		status := "ok"
+		switch {
+		case f.Injection && !detected:
+			status = "MISSED"
+			missed++
+		case !f.Injection && detected:
+			status = "FALSE ALARM"
+			falseAlarms++
+		}
+		if status == "ok" && !*verbose {
+			continue
+		}
+
+		rules := make([]string, len(matches))
+		for i, match := range matches {
+			rules[i] = match.Rule
+		}
+		fmt.Printf("%-11s %-8s %-30s %q\n", status, sourceOf(f), strings.Join(rules, ","), f.Text)
+	}
+
+	fmt.Printf("%d fixtures: %d/%d attacks detected, %d false alarms on %d benign\n",
+		len(fixtures), attacks-missed, attacks, falseAlarms, len(fixtures)-attacks)
+	if missed > 0 || falseAlarms > 0 {
+		os.Exit(1)
+	}
+}
+
+func loadFixtures(path string) ([]fixture, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var fixtures []fixture
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var f fixture
+		if err := json.Unmarshal(text, &f); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, scanner.Err()
+}
+
+func sourceOf(f fixture) string {
+	if f.Source == "" {
+		return ai.InjectionSourceUser
+	}
+	return f.Source
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "injection-check:", err)
+	os.Exit(1)
+}
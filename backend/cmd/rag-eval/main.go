@@ -0,0 +1,205 @@
+// Command rag-eval scores knowledge base retrieval against a labelled query
+// set, reporting recall@k, MRR and nDCG@k, and compares two retrieval
+// configurations side by side. It runs on copies of the local document
+// store, so the live store is never changed.
+//
+// Run it from backend/:
+//
+//	go run ./cmd/rag-eval -a embedding=none -b embedding=hash
+//
+// Configurations are comma-separated key=value settings over the RAG_*
+// environment variables: embedding, model, chunk_tokens, chunk_overlap,
+// recency_weight and half_life_days. Embeddings default to the offline
+// hash embedder.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"serpico/backend/internal/ai"
+)
+
+func main() {
+	// A flag set of its own, as dependencies register flags globally
+	flags := flag.NewFlagSet("rag-eval", flag.ExitOnError)
+	dataPath := flags.String("data", "data/rag", "document store to evaluate against")
+	queriesPath := flags.String("queries", "cmd/rag-eval/queries.jsonl", "labelled queries, one JSON object per line")
+	k := flags.Int("k", 5, "documents retrieved per query")
+	specA := flags.String("a", "", "baseline configuration")
+	specB := flags.String("b", "", "configuration to compare with the baseline; omit to evaluate one")
+	verbose := flags.Bool("v", false, "list every query, not just misses or those where the configurations differ")
+	asJSON := flags.Bool("json", false, "print the reports as JSON")
+	flags.Parse(os.Args[1:])
+
+	// Store and embedder logging would drown the report
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	if *k <= 0 {
+		fatal(fmt.Errorf("-k must be positive"))
+	}
+	queries, err := ai.LoadEvalQueries(*queriesPath)
+	if err != nil {
+		fatal(err)
+	}
+
+	specs := []string{*specA}
+	if *specB != "" {
+		specs = append(specs, *specB)
+	}
+
+	ctx := context.Background()
+	reports := make([]ai.EvalReport, 0, len(specs))
+	for _, spec := range specs {
+		config, err := parseConfig(spec)
+		if err != nil {
+			fatal(err)
+		}
+		rag, cleanup, err := ai.OpenEvalStore(*dataPath, config)
+		if err != nil {
+			fatal(err)
+		}
+		if missing := rag.MissingDocuments(queries); len(missing) > 0 {
+			fmt.Fprintf(os.Stderr, "warning: labelled documents not in the store: %s\n", strings.Join(missing, ", "))
+		}
+		reports = append(reports, rag.Evaluate(ctx, queries, *k))
+		cleanup()
+	}
+
+	if *asJSON {
+		out := make([]map[string]any, len(reports))
+		for i, report := range reports {
+			out[i] = map[string]any{"config": specs[i], "report": report}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	printReports(specs, reports, *verbose)
+}
+
+// parseConfig applies a configuration spec over the environment's AI
+// config. Embeddings use the hash embedder unless the spec names another,
+// so evaluations run offline by default.
+func parseConfig(spec string) (*ai.Config, error) {
+	config := ai.LoadConfig()
+	config.EmbeddingProvider = "hash"
+	config.EmbeddingModel = ""
+
+	for _, setting := range strings.Split(spec, ",") {
+		if setting = strings.TrimSpace(setting); setting == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return nil, fmt.Errorf("config setting %q is not key=value", setting)
+		}
+
+		var err error
+		switch key {
+		case "embedding":
+			config.EmbeddingProvider = value
+		case "model":
+			config.EmbeddingModel = value
+		case "chunk_tokens":
+			config.RAGChunkTokens, err = strconv.Atoi(value)
+		case "chunk_overlap":
+			config.RAGChunkOverlap, err = strconv.Atoi(value)
+		case "recency_weight":
+			config.RAGRecencyWeight, err = strconv.ParseFloat(value, 64)
+		case "half_life_days":
+			var days int
+			days, err = strconv.Atoi(value)
+			config.RAGRecencyHalfLife = time.Duration(days) * 24 * time.Hour
+		default:
+			return nil, fmt.Errorf("unknown config setting %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("config setting %s: %w", key, err)
+		}
+	}
+
+	if config.EmbeddingModel == "" {
+		switch config.EmbeddingProvider {
+		case "gemini":
+			config.EmbeddingModel = "text-embedding-004"
+		case "openai":
+			config.EmbeddingModel = "nomic-embed-text"
+		}
+	}
+	return config, nil
+}
+
+func printReports(specs []string, reports []ai.EvalReport, verbose bool) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "metric\tA"
+	if len(reports) == 2 {
+		header += "\tB\tB-A"
+	}
+	fmt.Fprintln(w, header)
+
+	k := reports[0].K
+	metrics := []struct {
+		name  string
+		value func(ai.EvalReport) float64
+	}{
+		{fmt.Sprintf("recall@%d", k), func(r ai.EvalReport) float64 { return r.Recall }},
+		{"MRR", func(r ai.EvalReport) float64 { return r.MRR }},
+		{fmt.Sprintf("nDCG@%d", k), func(r ai.EvalReport) float64 { return r.NDCG }},
+	}
+	for _, m := range metrics {
+		line := fmt.Sprintf("%s\t%.3f", m.name, m.value(reports[0]))
+		if len(reports) == 2 {
+			a, b := m.value(reports[0]), m.value(reports[1])
+			line += fmt.Sprintf("\t%.3f\t%+.3f", b, b-a)
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+
+	fmt.Printf("\n%d queries\nA: %s\n", len(reports[0].Queries), describe(specs[0]))
+	if len(reports) == 2 {
+		fmt.Printf("B: %s\n", describe(specs[1]))
+	}
+
+	// Per query, show where the configurations disagree
+	fmt.Println()
+	for i, a := range reports[0].Queries {
+		if len(reports) == 1 {
+			if verbose || a.Recall < 1 {
+				fmt.Printf("%q\n  relevant: %s\n  A: %s  nDCG %.3f\n", a.Query, strings.Join(a.Relevant, " "), strings.Join(a.Retrieved, " "), a.NDCG)
+			}
+			continue
+		}
+		b := reports[1].Queries[i]
+		if verbose || a.NDCG != b.NDCG {
+			fmt.Printf("%q\n  relevant: %s\n  A: %s  nDCG %.3f\n  B: %s  nDCG %.3f\n",
+				a.Query, strings.Join(a.Relevant, " "),
+				strings.Join(a.Retrieved, " "), a.NDCG,
+				strings.Join(b.Retrieved, " "), b.NDCG)
+		}
+	}
+}
+
+func describe(spec string) string {
+	if spec == "" {
+		return "environment defaults, hash embeddings"
+	}
+	return spec
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "rag-eval:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,144 @@
+// Command rag-snapshot exports the knowledge base to JSONL and imports
+// such a snapshot, for moving documents between environments. It opens
+// the document store directly, so stop the server first, or use the
+// /api/v1/rag/export and /api/v1/rag/import endpoints while it's running.
+//
+// Run it from backend/:
+//
+//	go run ./cmd/rag-snapshot export -embeddings -o snapshot.jsonl
+//	go run ./cmd/rag-snapshot import -mode replace -dry-run snapshot.jsonl
+//
+// Embeddings use the same EMBEDDING_* settings as the server.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"serpico/backend/internal/ai"
+)
+
+const usage = `usage:
+  rag-snapshot export [-data dir] [-o file] [-embeddings] [-records] [-status s1,s2]
+  rag-snapshot import [-data dir] [-mode merge|replace] [-on-conflict skip|overwrite|rename] [-dry-run] file`
+
+func main() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = export(os.Args[2:])
+	case "import":
+		err = importSnapshot(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "rag-snapshot:", err)
+		os.Exit(1)
+	}
+}
+
+func export(args []string) error {
+	config := ai.LoadConfig()
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	dataPath := flags.String("data", config.RAGDataPath, "document store")
+	output := flags.String("o", "", "file to write; standard output if omitted")
+	embeddings := flags.Bool("embeddings", false, "include chunk vectors")
+	records := flags.Bool("records", false, "include documents generated from database records")
+	statuses := flags.String("status", "", "comma-separated review statuses to export; all if omitted")
+	flags.Parse(args)
+
+	rag, err := ai.OpenRAGDatabase(config, *dataPath)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+
+	options := ai.ExportOptions{Embeddings: *embeddings, Records: *records}
+	for _, status := range strings.Split(*statuses, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			options.Statuses = append(options.Statuses, status)
+		}
+	}
+	n, err := rag.Export(w, options)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d documents\n", n)
+	return nil
+}
+
+func importSnapshot(args []string) error {
+	config := ai.LoadConfig()
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	dataPath := flags.String("data", config.RAGDataPath, "document store")
+	mode := flags.String("mode", ai.ImportMerge, "merge adds documents; replace also deletes those not in the snapshot")
+	onConflict := flags.String("on-conflict", ai.ConflictSkip, "when merging a document whose ID is taken: skip, overwrite or rename")
+	dryRun := flags.Bool("dry-run", false, "validate and report what would change without changing anything")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("import takes one snapshot file")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rag, err := ai.OpenRAGDatabase(config, *dataPath)
+	if err != nil {
+		return err
+	}
+
+	// Recorded as the author of every change in the document history
+	author := "rag-snapshot"
+	if user := os.Getenv("USER"); user != "" {
+		author += ":" + user
+	}
+	ctx := ai.WithAuthor(context.Background(), author)
+	report, err := rag.Import(ctx, file, ai.ImportOptions{Mode: *mode, OnConflict: *onConflict, DryRun: *dryRun})
+	if err != nil && !errors.Is(err, ai.ErrInvalidImport) {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if encErr := enc.Encode(report); encErr != nil {
+		return encErr
+	}
+	if err != nil {
+		return fmt.Errorf("%d invalid lines; nothing was imported", len(report.Errors))
+	}
+	summary := fmt.Sprintf("created %d, updated %d, deleted %d, unchanged %d, skipped %d",
+		len(report.Created), len(report.Updated), len(report.Deleted), len(report.Unchanged), len(report.Skipped))
+	if *dryRun {
+		summary += " (dry run)"
+	}
+	fmt.Fprintln(os.Stderr, summary)
+	return nil
+}
@@ -0,0 +1,143 @@
+package ai
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: k1 limits how much repeated terms count, b how much
+// long documents are penalized
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// Title terms count this many times, so title matches rank higher
+	bm25TitleBoost = 2
+)
+
+var stopWords = map[string]bool{
+	"a": true, "about": true, "after": true, "all": true, "also": true, "an": true,
+	"and": true, "any": true, "are": true, "as": true, "at": true, "be": true,
+	"been": true, "being": true, "but": true, "by": true, "can": true, "could": true,
+	"did": true, "do": true, "does": true, "for": true, "from": true, "had": true,
+	"has": true, "have": true, "how": true, "i": true, "if": true, "in": true,
+	"into": true, "is": true, "it": true, "its": true, "me": true, "my": true,
+	"of": true, "on": true, "or": true, "our": true, "should": true, "so": true,
+	"than": true, "that": true, "the": true, "their": true, "them": true, "then": true,
+	"there": true, "these": true, "they": true, "this": true, "to": true, "us": true,
+	"was": true, "we": true, "were": true, "what": true, "when": true, "where": true,
+	"which": true, "who": true, "why": true, "will": true, "with": true, "would": true,
+	"you": true, "your": true,
+}
+
+// tokenize splits text into lowercase stemmed terms without stop words
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		if stopWords[word] {
+			continue
+		}
+		terms = append(terms, stem(word))
+	}
+	return terms
+}
+
+// bm25Index is an inverted index over chunk text
+type bm25Index struct {
+	// postings maps a term to the chunks containing it and how often
+	postings map[string]map[string]int
+	lengths  map[string]int
+	total    int
+}
+
+func newBM25Index() *bm25Index {
+	return &bm25Index{
+		postings: make(map[string]map[string]int),
+		lengths:  make(map[string]int),
+	}
+}
+
+// indexText is the text indexed for a chunk. Every chunk carries its
+// document's title and metadata so a match on them finds any chunk.
+func indexText(doc RAGDocument, chunk RAGChunk) []string {
+	terms := tokenize(chunk.Text + " " + doc.Location + " " + strings.Join(doc.Tags, " "))
+	title := tokenize(doc.Title)
+	for i := 0; i < bm25TitleBoost; i++ {
+		terms = append(terms, title...)
+	}
+	return terms
+}
+
+// Add indexes terms under id, replacing any earlier version
+func (x *bm25Index) Add(id string, terms []string) {
+	x.Remove(id)
+
+	for _, term := range terms {
+		docs, ok := x.postings[term]
+		if !ok {
+			docs = make(map[string]int)
+			x.postings[term] = docs
+		}
+		docs[id]++
+	}
+	x.lengths[id] = len(terms)
+	x.total += len(terms)
+}
+
+// Remove drops an entry from the index
+func (x *bm25Index) Remove(id string) {
+	length, ok := x.lengths[id]
+	if !ok {
+		return
+	}
+	for term, docs := range x.postings {
+		if _, ok := docs[id]; ok {
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(x.postings, term)
+			}
+		}
+	}
+	delete(x.lengths, id)
+	x.total -= length
+}
+
+// Score returns BM25 scores for chunks matching query. When allowed is
+// non-nil only those chunks are scored.
+func (x *bm25Index) Score(query string, allowed map[string]bool) map[string]float64 {
+	scores := make(map[string]float64)
+	n := len(x.lengths)
+	if n == 0 {
+		return scores
+	}
+	avgLength := float64(x.total) / float64(n)
+
+	seen := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		// Repeating a word in the question shouldn't count it twice
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		docs := x.postings[term]
+		if len(docs) == 0 {
+			continue
+		}
+		df := float64(len(docs))
+		idf := math.Log(1 + (float64(n)-df+0.5)/(df+0.5))
+
+		for id, tf := range docs {
+			if allowed != nil && !allowed[id] {
+				continue
+			}
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(x.lengths[id])/avgLength)
+			scores[id] += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + norm)
+		}
+	}
+	return scores
+}
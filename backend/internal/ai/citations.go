@@ -0,0 +1,116 @@
+package ai
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Source types
+const (
+	SourceDocument = "document"
+	SourceWeb      = "web"
+)
+
+// citationPrompt is added to the system prompt when the question comes
+// with numbered context
+const citationPrompt = `When you use the provided context, cite it with its bracketed number right after the claim, e.g. [1] or [2][3]. Only cite sources you actually used.`
+
+// Source is context supplied with a chat message: a knowledge base document
+// or web search results
+type Source struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Category string `json:"category,omitempty"`
+	Type     string `json:"type"`
+	// Marker is the number the model was given for the source, as in [1]
+	Marker int     `json:"marker"`
+	Score  float64 `json:"score,omitempty"`
+	// Cited is set when the answer references the source
+	Cited bool `json:"cited"`
+}
+
+// Citation is an inline marker in the answer resolved to its source
+type Citation struct {
+	Marker   int    `json:"marker"`
+	SourceID string `json:"source_id"`
+	// Offset is the byte offset of the marker in the answer
+	Offset int `json:"offset"`
+}
+
+// sourcesFor numbers the retrieved context in the order buildPrompt
+// presents it: documents first, then web results
+func sourcesFor(docs []contextDoc, webResult string) []Source {
+	sources := make([]Source, 0, len(docs)+1)
+	for i, doc := range docs {
+		sources = append(sources, Source{
+			ID:       doc.Document.ID,
+			Title:    doc.Document.Title,
+			Category: doc.Document.Category,
+			Type:     SourceDocument,
+			Marker:   i + 1,
+			Score:    doc.Score,
+		})
+	}
+	if webResult != "" {
+		sources = append(sources, Source{
+			ID:     "web",
+			Title:  "Web search results",
+			Type:   SourceWeb,
+			Marker: len(docs) + 1,
+		})
+	}
+	return sources
+}
+
+// citationPattern matches [1], [1, 2] and [1-3] style markers
+var citationPattern = regexp.MustCompile(`\[(\d+(?:\s*[,\-–]\s*\d+)*)\]`)
+
+// resolveCitations finds citation markers in text, marks the sources they
+// refer to as cited and returns them. Markers that don't match a source,
+// e.g. numbers the model made up, are ignored.
+func resolveCitations(text string, sources []Source) []Citation {
+	byMarker := make(map[int]int, len(sources))
+	for i, source := range sources {
+		byMarker[source.Marker] = i
+	}
+
+	var citations []Citation
+	for _, match := range citationPattern.FindAllStringSubmatchIndex(text, -1) {
+		offset := match[0]
+		for _, marker := range expandMarkers(text[match[2]:match[3]]) {
+			i, ok := byMarker[marker]
+			if !ok {
+				continue
+			}
+			sources[i].Cited = true
+			citations = append(citations, Citation{Marker: marker, SourceID: sources[i].ID, Offset: offset})
+		}
+	}
+	return citations
+}
+
+// expandMarkers turns "1, 3-4" into 1, 3, 4
+func expandMarkers(list string) []int {
+	var markers []int
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.FieldsFunc(part, func(r rune) bool { return r == '-' || r == '–' })
+		if len(bounds) == 0 {
+			continue
+		}
+		first, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			continue
+		}
+		last := first
+		if len(bounds) > 1 {
+			if n, err := strconv.Atoi(strings.TrimSpace(bounds[len(bounds)-1])); err == nil && n >= first && n-first < 20 {
+				last = n
+			}
+		}
+		for marker := first; marker <= last; marker++ {
+			markers = append(markers, marker)
+		}
+	}
+	return markers
+}
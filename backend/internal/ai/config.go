@@ -2,13 +2,50 @@ package ai
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	GeminiAPIKey     string
-	GeminiModel      string
-	RAGDataPath      string
-	EnableWebSearch  bool
+	GeminiAPIKey  string
+	GeminiModel   string
+	OpenAIBaseURL string
+	OpenAIAPIKey  string
+	OpenAIModel   string
+	LLMProviders  []string // Tried in order until one answers
+	// EmbeddingProvider is gemini, openai, hash or none
+	EmbeddingProvider string
+	EmbeddingModel    string
+	// HistoryTokenBudget caps how much prior conversation is sent per turn
+	HistoryTokenBudget int
+	// RAGChunkTokens and RAGChunkOverlap size the chunks long documents
+	// are split into for retrieval
+	RAGChunkTokens  int
+	RAGChunkOverlap int
+	// RAGContextTokenBudget caps how much retrieved text is sent per turn
+	RAGContextTokenBudget int
+	RAGDataPath           string
+	// RAGSyncInterval is how often documents generated from database
+	// records are reconciled with the tables; zero only syncs at startup
+	RAGSyncInterval time.Duration
+	// RAGRecencyWeight is the weight of the ranking by document age in
+	// retrieval, where freshness halves every RAGRecencyHalfLife
+	RAGRecencyWeight   float64
+	RAGRecencyHalfLife time.Duration
+	// RAGStaleAfter is when a published document is reported as due for
+	// review
+	RAGStaleAfter time.Duration
+	ChatModesPath string
+	// ScreenerPath holds the prompt screener's rules and examples
+	ScreenerPath string
+	// PIIPath holds the per-role PII policy
+	PIIPath string
+	// SafetyPath holds the output safety filter's rules and audit log
+	SafetyPath      string
+	EnableWebSearch bool
+	// EnableTools lets the model call database lookup functions
+	EnableTools bool
 }
 
 func LoadConfig() *Config {
@@ -22,11 +59,102 @@ func LoadConfig() *Config {
 		model = "gemini-2.5-flash"
 	}
 
+	// OpenAI-compatible server, e.g. Ollama (http://localhost:11434/v1)
+	// or llama.cpp (http://localhost:8080/v1)
+	openAIBaseURL := os.Getenv("OPENAI_BASE_URL")
+	openAIModel := os.Getenv("OPENAI_MODEL")
+	if openAIModel == "" {
+		openAIModel = "llama3.1"
+	}
+
+	// Fall back to the local model when one is configured
+	providers := []string{"gemini"}
+	if openAIBaseURL != "" {
+		providers = append(providers, "openai")
+	}
+	if env := os.Getenv("LLM_PROVIDERS"); env != "" {
+		providers = nil
+		for _, name := range strings.Split(env, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				providers = append(providers, name)
+			}
+		}
+	}
+
+	// Embeddings for RAG retrieval; "hash" works offline, "none" disables
+	embeddingProvider := os.Getenv("EMBEDDING_PROVIDER")
+	if embeddingProvider == "" {
+		embeddingProvider = "gemini"
+	}
+	embeddingModel := os.Getenv("EMBEDDING_MODEL")
+	if embeddingModel == "" {
+		switch embeddingProvider {
+		case "gemini":
+			embeddingModel = "text-embedding-004"
+		case "openai":
+			embeddingModel = "nomic-embed-text"
+		}
+	}
+
+	historyBudget := 2000
+	if env, err := strconv.Atoi(os.Getenv("CHAT_HISTORY_TOKEN_BUDGET")); err == nil && env >= 0 {
+		historyBudget = env
+	}
+
+	chunkTokens := 300
+	if env, err := strconv.Atoi(os.Getenv("RAG_CHUNK_TOKENS")); err == nil && env > 0 {
+		chunkTokens = env
+	}
+	chunkOverlap := 50
+	if env, err := strconv.Atoi(os.Getenv("RAG_CHUNK_OVERLAP")); err == nil && env >= 0 {
+		chunkOverlap = env
+	}
+	contextBudget := 1500
+	if env, err := strconv.Atoi(os.Getenv("RAG_CONTEXT_TOKEN_BUDGET")); err == nil && env > 0 {
+		contextBudget = env
+	}
+
+	syncInterval := 15 * time.Minute
+	if env, err := time.ParseDuration(os.Getenv("RAG_SYNC_INTERVAL")); err == nil && env >= 0 {
+		syncInterval = env
+	}
+
+	recencyWeight := 0.1
+	if env, err := strconv.ParseFloat(os.Getenv("RAG_RECENCY_WEIGHT"), 64); err == nil && env >= 0 && env <= 1 {
+		recencyWeight = env
+	}
+	halfLifeDays := 365
+	if env, err := strconv.Atoi(os.Getenv("RAG_RECENCY_HALF_LIFE_DAYS")); err == nil && env > 0 {
+		halfLifeDays = env
+	}
+	staleAfterDays := 365
+	if env, err := strconv.Atoi(os.Getenv("RAG_STALE_AFTER_DAYS")); err == nil && env > 0 {
+		staleAfterDays = env
+	}
+
 	return &Config{
-		GeminiAPIKey:    apiKey,
-		GeminiModel:     model,
-		RAGDataPath:     "data/rag",
-		EnableWebSearch: true,
+		GeminiAPIKey:          apiKey,
+		GeminiModel:           model,
+		OpenAIBaseURL:         openAIBaseURL,
+		OpenAIAPIKey:          os.Getenv("OPENAI_API_KEY"),
+		OpenAIModel:           openAIModel,
+		LLMProviders:          providers,
+		EmbeddingProvider:     embeddingProvider,
+		EmbeddingModel:        embeddingModel,
+		HistoryTokenBudget:    historyBudget,
+		RAGChunkTokens:        chunkTokens,
+		RAGChunkOverlap:       chunkOverlap,
+		RAGContextTokenBudget: contextBudget,
+		RAGDataPath:           "data/rag",
+		RAGSyncInterval:       syncInterval,
+		RAGRecencyWeight:      recencyWeight,
+		RAGRecencyHalfLife:    time.Duration(halfLifeDays) * 24 * time.Hour,
+		RAGStaleAfter:         time.Duration(staleAfterDays) * 24 * time.Hour,
+		ChatModesPath:         "data/modes",
+		ScreenerPath:          "data/screener",
+		PIIPath:               "data/pii",
+		SafetyPath:            "data/safety",
+		EnableWebSearch:       true,
+		EnableTools:           os.Getenv("ENABLE_TOOLS") != "false",
 	}
 }
-
@@ -0,0 +1,329 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"serpico/backend/internal/metrics"
+)
+
+// EmbedTask says what a text is embedded for. Some models embed queries
+// and documents differently to improve retrieval.
+type EmbedTask string
+
+const (
+	EmbedDocument EmbedTask = "document"
+	EmbedQuery    EmbedTask = "query"
+)
+
+// Embedder turns texts into vectors for similarity search
+type Embedder interface {
+	// Name identifies the provider and model. Vectors from embedders with
+	// different names aren't comparable.
+	Name() string
+	Embed(ctx context.Context, texts []string, task EmbedTask) ([][]float64, error)
+}
+
+// NewEmbedder builds the embedder named in config.EmbeddingProvider. It
+// returns nil when embeddings are disabled, leaving keyword search only.
+func NewEmbedder(config *Config) (Embedder, error) {
+	switch config.EmbeddingProvider {
+	case "", "none":
+		return nil, nil
+	case "gemini":
+		return NewGeminiEmbedder(config.GeminiAPIKey, config.EmbeddingModel), nil
+	case "openai":
+		if config.OpenAIBaseURL == "" {
+			return nil, fmt.Errorf("openai embeddings need OPENAI_BASE_URL")
+		}
+		return NewOpenAIEmbedder(config.OpenAIBaseURL, config.OpenAIAPIKey, config.EmbeddingModel), nil
+	case "hash":
+		return NewHashEmbedder(hashEmbeddingDims), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q", config.EmbeddingProvider)
+	}
+}
+
+// observeEmbedding wraps an embedding call with metrics
+func observeEmbedding(provider string, fn func() ([][]float64, error)) ([][]float64, error) {
+	start := time.Now()
+	vectors, err := fn()
+	metrics.ObserveEmbeddingRequest(provider, time.Since(start), err)
+	return vectors, err
+}
+
+// GeminiEmbedder uses the Gemini embedding API
+type GeminiEmbedder struct {
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+func NewGeminiEmbedder(apiKey, model string) *GeminiEmbedder {
+	return &GeminiEmbedder{
+		apiKey: apiKey,
+		model:  model,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type geminiEmbedRequest struct {
+	Requests []geminiEmbedContentRequest `json:"requests"`
+}
+
+type geminiEmbedContentRequest struct {
+	Model    string  `json:"model"`
+	Content  Content `json:"content"`
+	TaskType string  `json:"taskType,omitempty"`
+}
+
+type geminiEmbedResponse struct {
+	Embeddings []struct {
+		Values []float64 `json:"values"`
+	} `json:"embeddings"`
+}
+
+// geminiEmbedBatch is the most texts one batchEmbedContents call accepts
+const geminiEmbedBatch = 100
+
+func (g *GeminiEmbedder) Name() string {
+	return "gemini/" + g.model
+}
+
+func (g *GeminiEmbedder) Embed(ctx context.Context, texts []string, task EmbedTask) ([][]float64, error) {
+	return observeEmbedding("gemini", func() ([][]float64, error) {
+		var vectors [][]float64
+		for start := 0; start < len(texts); start += geminiEmbedBatch {
+			end := start + geminiEmbedBatch
+			if end > len(texts) {
+				end = len(texts)
+			}
+			batch, err := g.embed(ctx, texts[start:end], task)
+			if err != nil {
+				return nil, err
+			}
+			vectors = append(vectors, batch...)
+		}
+		return vectors, nil
+	})
+}
+
+func (g *GeminiEmbedder) embed(ctx context.Context, texts []string, task EmbedTask) ([][]float64, error) {
+	taskType := "RETRIEVAL_DOCUMENT"
+	if task == EmbedQuery {
+		taskType = "RETRIEVAL_QUERY"
+	}
+
+	request := geminiEmbedRequest{}
+	for _, text := range texts {
+		request.Requests = append(request.Requests, geminiEmbedContentRequest{
+			Model:    "models/" + g.model,
+			Content:  Content{Parts: []Part{{Text: text}}},
+			TaskType: taskType,
+		})
+	}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:batchEmbedContents", g.model)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", g.apiKey)
+
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var embedResp geminiEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embedResp.Embeddings))
+	}
+
+	vectors := make([][]float64, len(texts))
+	for i, embedding := range embedResp.Embeddings {
+		vectors[i] = normalize(embedding.Values)
+	}
+	return vectors, nil
+}
+
+// OpenAIEmbedder uses an OpenAI-compatible embeddings endpoint, e.g. a
+// local Ollama model
+type OpenAIEmbedder struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func NewOpenAIEmbedder(baseURL, apiKey, model string) *OpenAIEmbedder {
+	return &OpenAIEmbedder{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+type openAIEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbedResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float64 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (o *OpenAIEmbedder) Name() string {
+	return "openai/" + o.model
+}
+
+func (o *OpenAIEmbedder) Embed(ctx context.Context, texts []string, task EmbedTask) ([][]float64, error) {
+	return observeEmbedding("openai", func() ([][]float64, error) {
+		jsonData, err := json.Marshal(openAIEmbedRequest{Model: o.model, Input: texts})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/embeddings", bytes.NewBuffer(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		if o.apiKey != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+		}
+
+		resp, err := o.client.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+		}
+
+		var embedResp openAIEmbedResponse
+		if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		if len(embedResp.Data) != len(texts) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embedResp.Data))
+		}
+
+		sort.Slice(embedResp.Data, func(i, j int) bool { return embedResp.Data[i].Index < embedResp.Data[j].Index })
+		vectors := make([][]float64, len(texts))
+		for i, item := range embedResp.Data {
+			vectors[i] = normalize(item.Embedding)
+		}
+		return vectors, nil
+	})
+}
+
+const hashEmbeddingDims = 512
+
+// HashEmbedder hashes words and word pairs into a fixed-size vector. It
+// needs no model or network, so it suits offline use and tests, but only
+// matches shared vocabulary rather than meaning.
+type HashEmbedder struct {
+	dims int
+}
+
+func NewHashEmbedder(dims int) *HashEmbedder {
+	return &HashEmbedder{dims: dims}
+}
+
+func (h *HashEmbedder) Name() string {
+	return fmt.Sprintf("hash-%d", h.dims)
+}
+
+func (h *HashEmbedder) Embed(ctx context.Context, texts []string, task EmbedTask) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vectors[i] = h.embed(text)
+	}
+	return vectors, nil
+}
+
+func (h *HashEmbedder) embed(text string) []float64 {
+	vector := make([]float64, h.dims)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	add := func(feature string, weight float64) {
+		hasher := fnv.New64a()
+		hasher.Write([]byte(feature))
+		sum := hasher.Sum64()
+		// The top bit picks the sign so collisions tend to cancel out
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[sum%uint64(h.dims)] += weight
+	}
+	for i, word := range words {
+		add(word, 1)
+		if i > 0 {
+			add(words[i-1]+" "+word, 0.5)
+		}
+	}
+	return normalize(vector)
+}
+
+// normalize scales v to unit length so cosine similarity is a dot product
+func normalize(v []float64) []float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := math.Sqrt(sum)
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+// cosine returns the cosine similarity of two unit vectors
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
@@ -2,12 +2,17 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
+
+	"serpico/backend/internal/metrics"
+
+	"github.com/google/uuid"
 )
 
 // GeminiClient handles communication with Google Gemini API
@@ -15,6 +20,9 @@ type GeminiClient struct {
 	apiKey string
 	model  string
 	client *http.Client
+	// streamClient has no overall timeout since a streamed answer can take
+	// longer than a blocking call; the request context bounds it instead
+	streamClient *http.Client
 }
 
 func NewGeminiClient(apiKey, model string) *GeminiClient {
@@ -24,13 +32,20 @@ func NewGeminiClient(apiKey, model string) *GeminiClient {
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		streamClient: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				ResponseHeaderTimeout: 30 * time.Second,
+			},
+		},
 	}
 }
 
 // ChatRequest represents a chat request to Gemini
 type ChatRequest struct {
-	Contents []Content `json:"contents"`
-	Tools    []Tool    `json:"tools,omitempty"`
+	SystemInstruction *Content  `json:"systemInstruction,omitempty"`
+	Contents          []Content `json:"contents"`
+	Tools             []Tool    `json:"tools,omitempty"`
 }
 
 type Content struct {
@@ -39,7 +54,21 @@ type Content struct {
 }
 
 type Part struct {
-	Text string `json:"text,omitempty"`
+	Text             string            `json:"text,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// FunctionCall is a model's request to call a declared function
+type FunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+// FunctionResponse returns a function's result to the model
+type FunctionResponse struct {
+	Name     string      `json:"name"`
+	Response interface{} `json:"response"`
 }
 
 type Tool struct {
@@ -54,97 +83,266 @@ type FunctionDeclaration struct {
 
 // ChatResponse represents the response from Gemini
 type ChatResponse struct {
-	Candidates []Candidate `json:"candidates"`
+	Candidates    []Candidate   `json:"candidates"`
+	UsageMetadata UsageMetadata `json:"usageMetadata"`
+}
+
+// UsageMetadata reports token counts for a Gemini call
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
 }
 
 type Candidate struct {
 	Content Content `json:"content"`
 }
 
-// GenerateResponse generates a response using Gemini API with RAG context
-func (g *GeminiClient) GenerateResponse(userMessage string, ragContext []RAGDocument, webSearchResult string) (string, error) {
-	// Build context from RAG documents
-	context := g.buildContext(ragContext, webSearchResult)
-	
-	// Build the prompt
-	prompt := fmt.Sprintf(`You are an AI assistant for Olathe Police Department. You help officers and civilians with crime-related information, pursuit strategies, and case data.
-
-Context from knowledge base:
-%s
-
-Web search results:
-%s
-
-User question: %s
-
-Provide a helpful, accurate response based on the context. If the information is not in the context, say so. Always prioritize safety and official procedures.`, context, webSearchResult, userMessage)
+func (g *GeminiClient) Name() string {
+	return "gemini"
+}
 
-	// Prepare request
-	request := ChatRequest{
-		Contents: []Content{
-			{
-				Parts: []Part{
-					{Text: prompt},
-				},
-			},
-		},
+// Generate sends the conversation to Gemini's generateContent endpoint
+func (g *GeminiClient) Generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
+	start := time.Now()
+	resp, err := g.generate(ctx, req)
+	var usage Usage
+	if resp != nil {
+		usage = resp.Usage
 	}
+	metrics.ObserveLLMRequest(g.Name(), g.model, time.Since(start), usage.PromptTokens, usage.CompletionTokens, err)
+	return resp, err
+}
+
+func (g *GeminiClient) generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
+	request := g.buildRequest(req)
 
 	jsonData, err := json.Marshal(request)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Make API call
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", g.model, g.apiKey)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent", g.model)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Content-Type", "application/json")
+	// Send the key as a header so it never shows up in logged URLs
+	httpReq.Header.Set("x-goog-api-key", g.apiKey)
 
-	resp, err := g.client.Do(req)
+	resp, err := g.client.Do(httpReq)
 	if err != nil {
-		return "", fmt.Errorf("failed to make request: %w", err)
+		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
 	}
 
 	// Parse response
 	var chatResp ChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if len(chatResp.Candidates) == 0 || len(chatResp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("empty response from API")
+		return nil, fmt.Errorf("empty response from API")
+	}
+
+	result := &GenerateResponse{
+		Provider: g.Name(),
+		Model:    g.model,
+		Usage: Usage{
+			PromptTokens:     chatResp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: chatResp.UsageMetadata.CandidatesTokenCount,
+		},
+	}
+	var text strings.Builder
+	for _, part := range chatResp.Candidates[0].Content.Parts {
+		text.WriteString(part.Text)
+		if part.FunctionCall != nil {
+			result.ToolCalls = append(result.ToolCalls, newGeminiToolCall(part.FunctionCall))
+		}
+	}
+	result.Text = text.String()
+
+	if result.Text == "" && len(result.ToolCalls) == 0 {
+		return nil, fmt.Errorf("empty response from API")
 	}
+	return result, nil
+}
 
-	return chatResp.Candidates[0].Content.Parts[0].Text, nil
+// newGeminiToolCall converts a function call part. Gemini doesn't assign
+// call IDs, so one is generated to pair the call with its result.
+func newGeminiToolCall(call *FunctionCall) ToolCall {
+	args := call.Args
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	return ToolCall{
+		ID:        "call-" + uuid.New().String(),
+		Name:      call.Name,
+		Arguments: args,
+	}
 }
 
-func (g *GeminiClient) buildContext(ragDocs []RAGDocument, webSearch string) string {
-	if len(ragDocs) == 0 && webSearch == "" {
-		return "No relevant context found."
+// GenerateStream sends the conversation to streamGenerateContent and relays
+// text chunks as they arrive
+func (g *GeminiClient) GenerateStream(ctx context.Context, req GenerateRequest, onChunk StreamFunc) (*GenerateResponse, error) {
+	start := time.Now()
+	resp, err := g.generateStream(ctx, req, onChunk)
+	var usage Usage
+	if resp != nil {
+		usage = resp.Usage
 	}
+	metrics.ObserveLLMRequest(g.Name(), g.model, time.Since(start), usage.PromptTokens, usage.CompletionTokens, err)
+	return resp, err
+}
 
-	var context strings.Builder
-	context.WriteString("Relevant information:\n\n")
+func (g *GeminiClient) generateStream(ctx context.Context, req GenerateRequest, onChunk StreamFunc) (*GenerateResponse, error) {
+	jsonData, err := json.Marshal(g.buildRequest(req))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse", g.model)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", g.apiKey)
 
-	for i, doc := range ragDocs {
-		context.WriteString(fmt.Sprintf("[%d] %s\n", i+1, doc.Title))
-		context.WriteString(fmt.Sprintf("Category: %s\n", doc.Category))
-		if doc.Location != "" {
-			context.WriteString(fmt.Sprintf("Location: %s\n", doc.Location))
+	resp, err := g.streamClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	result := &GenerateResponse{Provider: g.Name(), Model: g.model}
+	var text strings.Builder
+	err = readSSE(resp.Body, func(data []byte) error {
+		var chunk ChatResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		// Usage is cumulative; the last chunk carries the totals
+		if chunk.UsageMetadata.TotalTokenCount > 0 {
+			result.Usage = Usage{
+				PromptTokens:     chunk.UsageMetadata.PromptTokenCount,
+				CompletionTokens: chunk.UsageMetadata.CandidatesTokenCount,
+			}
 		}
-		context.WriteString(fmt.Sprintf("Content: %s\n\n", doc.Content))
+		if len(chunk.Candidates) == 0 {
+			return nil
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.FunctionCall != nil {
+				result.ToolCalls = append(result.ToolCalls, newGeminiToolCall(part.FunctionCall))
+			}
+			if part.Text == "" {
+				continue
+			}
+			text.WriteString(part.Text)
+			if err := onChunk(part.Text); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return context.String()
+	if text.Len() == 0 && len(result.ToolCalls) == 0 {
+		return nil, fmt.Errorf("empty response from API")
+	}
+	result.Text = text.String()
+	return result, nil
 }
 
+// buildRequest maps a provider-neutral request onto Gemini's format
+func (g *GeminiClient) buildRequest(req GenerateRequest) ChatRequest {
+	request := ChatRequest{}
+	if req.SystemPrompt != "" {
+		request.SystemInstruction = &Content{
+			Parts: []Part{{Text: req.SystemPrompt}},
+		}
+	}
+
+	if len(req.Tools) > 0 {
+		declarations := make([]FunctionDeclaration, len(req.Tools))
+		for i, tool := range req.Tools {
+			declarations[i] = FunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			}
+		}
+		request.Tools = []Tool{{FunctionDeclarations: declarations}}
+	}
+
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case RoleAssistant:
+			content := Content{Role: "model"}
+			if msg.Content != "" {
+				content.Parts = append(content.Parts, Part{Text: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				content.Parts = append(content.Parts, Part{FunctionCall: &FunctionCall{Name: call.Name, Args: call.Arguments}})
+			}
+			request.Contents = append(request.Contents, content)
+		case RoleTool:
+			// Results for one turn's calls go back together in a single content
+			part := Part{FunctionResponse: &FunctionResponse{
+				Name:     msg.Name,
+				Response: map[string]json.RawMessage{"content": json.RawMessage(msg.Content)},
+			}}
+			last := len(request.Contents) - 1
+			if last >= 0 && request.Contents[last].Role == "user" && request.Contents[last].Parts[0].FunctionResponse != nil {
+				request.Contents[last].Parts = append(request.Contents[last].Parts, part)
+			} else {
+				request.Contents = append(request.Contents, Content{Role: "user", Parts: []Part{part}})
+			}
+		default:
+			request.Contents = append(request.Contents, Content{
+				Role:  "user",
+				Parts: []Part{{Text: msg.Content}},
+			})
+		}
+	}
+
+	return request
+}
+
+// Ping checks that the API is reachable and the configured model exists
+func (g *GeminiClient) Ping(ctx context.Context) error {
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s", g.model)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("x-goog-api-key", g.apiKey)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+)
+
+// estimateTokens approximates token count at ~4 characters per token,
+// close enough for budgeting without a provider-specific tokenizer
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// trimHistory keeps the most recent turns that fit in budget tokens. Turns
+// that don't fit are condensed into a short summary of the earlier user
+// questions so the model keeps the thread of the conversation.
+func trimHistory(history []Message, budget int) ([]Message, string) {
+	if budget <= 0 || len(history) == 0 {
+		return nil, ""
+	}
+
+	used := 0
+	start := len(history)
+	for start > 0 {
+		cost := estimateTokens(history[start-1].Content)
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+
+	// Providers expect the conversation to open with a user turn
+	for start < len(history) && history[start].Role != RoleUser {
+		start++
+	}
+
+	return history[start:], summarizeHistory(history[:start])
+}
+
+// summarizeHistory lists the questions asked in dropped turns
+func summarizeHistory(dropped []Message) string {
+	const maxQuestions = 5
+	const maxQuestionLen = 120
+
+	var questions []string
+	for _, msg := range dropped {
+		if msg.Role != RoleUser {
+			continue
+		}
+		question := strings.Join(strings.Fields(msg.Content), " ")
+		if len(question) > maxQuestionLen {
+			question = question[:maxQuestionLen] + "..."
+		}
+		questions = append(questions, question)
+	}
+	if len(questions) == 0 {
+		return ""
+	}
+
+	omitted := 0
+	if len(questions) > maxQuestions {
+		omitted = len(questions) - maxQuestions
+		questions = questions[omitted:]
+	}
+
+	var summary strings.Builder
+	summary.WriteString("Earlier in this conversation the user asked:\n")
+	if omitted > 0 {
+		summary.WriteString(fmt.Sprintf("- (%d earlier questions omitted)\n", omitted))
+	}
+	for _, q := range questions {
+		summary.WriteString("- " + q + "\n")
+	}
+	return summary.String()
+}
@@ -0,0 +1,298 @@
+package ai
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// ErrUnsupportedFormat is returned for files ExtractText can't read
+var ErrUnsupportedFormat = errors.New("unsupported file format")
+
+// ExtractedText is the readable text of an uploaded file
+type ExtractedText struct {
+	// Title is taken from the file's own metadata or first heading when it
+	// has one
+	Title string
+	Text  string
+}
+
+// ExtractText pulls plain text out of a PDF, DOCX, Markdown, HTML or text
+// file, chosen by the file name's extension. Paragraphs are separated by
+// blank lines so chunking can split on them.
+func ExtractText(name string, data []byte) (*ExtractedText, error) {
+	var extracted *ExtractedText
+	var err error
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".txt", ".text":
+		extracted, err = extractPlain(data)
+	case ".md", ".markdown":
+		extracted, err = extractMarkdown(data)
+	case ".html", ".htm":
+		extracted, err = extractHTML(data)
+	case ".docx":
+		extracted, err = extractDOCX(data)
+	case ".pdf":
+		extracted, err = extractPDF(data)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, filepath.Ext(name))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	extracted.Title = strings.TrimSpace(extracted.Title)
+	extracted.Text = normalizeParagraphs(extracted.Text)
+	if extracted.Text == "" {
+		return nil, errors.New("no text found in file")
+	}
+	return extracted, nil
+}
+
+var paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+
+// normalizeParagraphs collapses whitespace within paragraphs and leaves
+// one blank line between them
+func normalizeParagraphs(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var paragraphs []string
+	for _, paragraph := range paragraphBreak.Split(text, -1) {
+		if paragraph = strings.Join(strings.Fields(paragraph), " "); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+func extractPlain(data []byte) (*ExtractedText, error) {
+	if !utf8.Valid(data) {
+		return nil, errors.New("file is not UTF-8 text")
+	}
+	return &ExtractedText{Text: string(data)}, nil
+}
+
+var (
+	markdownFence    = regexp.MustCompile("(?m)^[ \t]*(```|~~~).*$")
+	markdownHeading  = regexp.MustCompile(`(?m)^[ \t]{0,3}#{1,6}[ \t]+(.*?)[ \t]*#*[ \t]*$`)
+	markdownImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	markdownListItem = regexp.MustCompile(`(?m)^[ \t]*([-*+]|\d+[.)])[ \t]+`)
+	markdownQuote    = regexp.MustCompile(`(?m)^[ \t]*>[ \t]?`)
+	markdownRule     = regexp.MustCompile(`(?m)^[ \t]*([-*_][ \t]*){3,}$`)
+	markdownTableSep = regexp.MustCompile(`(?m)^[ \t]*\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	// Underscores only count at word edges so snake_case survives
+	markdownEmphasis = regexp.MustCompile("(\\*\\*|\\*|`|\\b__?|__?\\b)")
+	htmlTag          = regexp.MustCompile(`<[^>]+>`)
+)
+
+// extractMarkdown strips Markdown syntax, keeping link and image text. The
+// first heading becomes the title.
+func extractMarkdown(data []byte) (*ExtractedText, error) {
+	extracted, err := extractPlain(data)
+	if err != nil {
+		return nil, err
+	}
+	text := extracted.Text
+
+	if match := markdownHeading.FindStringSubmatch(text); match != nil {
+		extracted.Title = markdownEmphasis.ReplaceAllString(match[1], "")
+	}
+	text = markdownFence.ReplaceAllString(text, "")
+	// Headings become their own paragraphs
+	text = markdownHeading.ReplaceAllString(text, "\n$1\n")
+	text = markdownImage.ReplaceAllString(text, "$1")
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = markdownTableSep.ReplaceAllString(text, "")
+	text = markdownRule.ReplaceAllString(text, "")
+	text = markdownListItem.ReplaceAllString(text, "")
+	text = markdownQuote.ReplaceAllString(text, "")
+	text = htmlTag.ReplaceAllString(text, "")
+	text = markdownEmphasis.ReplaceAllString(text, "")
+	text = strings.ReplaceAll(text, "|", " ")
+
+	extracted.Text = text
+	return extracted, nil
+}
+
+// htmlBlocks are elements that start a new paragraph
+var htmlBlocks = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "br": true,
+	"dd": true, "div": true, "dl": true, "dt": true, "figcaption": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "main": true, "ol": true, "p": true,
+	"pre": true, "section": true, "table": true, "td": true, "th": true, "tr": true, "ul": true,
+}
+
+// htmlSkipped are elements whose text isn't content
+var htmlSkipped = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"nav": true, "svg": true,
+}
+
+// extractHTML returns the visible text of a page, using <title> or else
+// the first <h1> as the title
+func extractHTML(data []byte) (*ExtractedText, error) {
+	root, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	extracted := &ExtractedText{}
+	var heading string
+	var text strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "title" && extracted.Title == "":
+				extracted.Title = nodeText(n)
+				return
+			case n.Data == "h1" && heading == "":
+				heading = nodeText(n)
+			case htmlSkipped[n.Data]:
+				return
+			}
+		}
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+		}
+
+		block := n.Type == html.ElementNode && htmlBlocks[n.Data]
+		if block {
+			text.WriteString("\n\n")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if block {
+			text.WriteString("\n\n")
+		}
+	}
+	walk(root)
+
+	if extracted.Title == "" {
+		extracted.Title = heading
+	}
+	extracted.Text = text.String()
+	return extracted, nil
+}
+
+// nodeText concatenates the text below n
+func nodeText(n *html.Node) string {
+	var text strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(text.String()), " ")
+}
+
+// maxDOCXPart bounds how much of one part of a DOCX archive is read, so a
+// zip bomb can't exhaust memory
+const maxDOCXPart = 50 << 20
+
+// extractDOCX reads the paragraphs of word/document.xml and the title from
+// docProps/core.xml
+func extractDOCX(data []byte) (*ExtractedText, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open DOCX: %w", err)
+	}
+
+	extracted := &ExtractedText{}
+	var document []byte
+	for _, file := range archive.File {
+		switch file.Name {
+		case "word/document.xml":
+			if document, err = readZipFile(file); err != nil {
+				return nil, err
+			}
+		case "docProps/core.xml":
+			core, err := readZipFile(file)
+			if err != nil {
+				return nil, err
+			}
+			var props struct {
+				Title string `xml:"title"`
+			}
+			if xml.Unmarshal(core, &props) == nil {
+				extracted.Title = props.Title
+			}
+		}
+	}
+	if document == nil {
+		return nil, errors.New("DOCX has no word/document.xml")
+	}
+
+	// Paragraphs are <w:p>, runs of text <w:t>; tabs and breaks are
+	// elements of their own
+	var text strings.Builder
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse DOCX: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				text.WriteString("\t")
+			case "br", "cr":
+				text.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text.WriteString("\n\n")
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+
+	extracted.Text = text.String()
+	return extracted, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxDOCXPart+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file.Name, err)
+	}
+	if len(data) > maxDOCXPart {
+		return nil, fmt.Errorf("%s is too large", file.Name)
+	}
+	return data, nil
+}
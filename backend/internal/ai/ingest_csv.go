@@ -0,0 +1,130 @@
+package ai
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CSVMapping names the CSV columns that fill each document field. Column
+// names match the header row, ignoring case. Only Title is required.
+type CSVMapping struct {
+	Title string
+	// Content columns are written as "Column: value" lines. Empty means
+	// every column not mapped to another field.
+	Content  []string
+	Category string
+	Location string
+	// Tags is a column of tags separated by commas, semicolons or pipes
+	Tags string
+}
+
+// CSVRecord is a document read from one CSV row
+type CSVRecord struct {
+	// Row is the line in the file, counting the header as 1
+	Row      int
+	Title    string
+	Content  string
+	Category string
+	Location string
+	Tags     []string
+}
+
+// ParseCSVDocuments turns each row of a CSV file with a header into a
+// record using mapping. Rows with no content are left out.
+func ParseCSVDocuments(data []byte, mapping CSVMapping) ([]CSVRecord, error) {
+	if mapping.Title == "" {
+		return nil, errors.New("CSV upload needs a title column")
+	}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	}
+	if len(rows) < 2 {
+		return nil, errors.New("CSV has no rows below the header")
+	}
+
+	header := rows[0]
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	column := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		i, ok := columns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return -1, fmt.Errorf("CSV has no %q column; columns are %s", name, strings.Join(header, ", "))
+		}
+		return i, nil
+	}
+
+	var fields [4]int
+	for i, name := range []string{mapping.Title, mapping.Category, mapping.Location, mapping.Tags} {
+		if fields[i], err = column(name); err != nil {
+			return nil, err
+		}
+	}
+	titleCol, categoryCol, locationCol, tagsCol := fields[0], fields[1], fields[2], fields[3]
+
+	var contentCols []int
+	if len(mapping.Content) > 0 {
+		for _, name := range mapping.Content {
+			i, err := column(name)
+			if err != nil {
+				return nil, err
+			}
+			contentCols = append(contentCols, i)
+		}
+	} else {
+		for i := range header {
+			if i != titleCol && i != categoryCol && i != locationCol && i != tagsCol {
+				contentCols = append(contentCols, i)
+			}
+		}
+	}
+
+	cell := func(row []string, i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var records []CSVRecord
+	for n, row := range rows[1:] {
+		var lines []string
+		for _, i := range contentCols {
+			if value := cell(row, i); value != "" {
+				lines = append(lines, strings.TrimSpace(header[i])+": "+value)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		record := CSVRecord{
+			Row:      n + 2,
+			Title:    cell(row, titleCol),
+			Content:  strings.Join(lines, "\n"),
+			Category: cell(row, categoryCol),
+			Location: cell(row, locationCol),
+		}
+		for _, tag := range strings.FieldsFunc(cell(row, tagsCol), func(r rune) bool {
+			return r == ',' || r == ';' || r == '|'
+		}) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				record.Tags = append(record.Tags, tag)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
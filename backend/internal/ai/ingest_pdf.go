@@ -0,0 +1,438 @@
+package ai
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// extractPDF reads the text drawn by a PDF's content streams. It handles
+// uncompressed and Flate-compressed streams with standard or UTF-16 string
+// encodings, which covers PDFs exported from word processors. Scanned
+// pages and fonts with custom glyph encodings have no readable text layer
+// and are rejected.
+func extractPDF(data []byte) (*ExtractedText, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil, errors.New("not a PDF file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return nil, errors.New("encrypted PDFs aren't supported")
+	}
+
+	streams, err := pdfStreams(data)
+	if err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	for _, stream := range streams {
+		if !bytes.Contains(stream, []byte("BT")) {
+			continue
+		}
+		text.WriteString(pdfContentText(stream))
+		text.WriteString("\n\n")
+	}
+
+	extracted := &ExtractedText{Text: text.String()}
+	if match := pdfTitle.FindSubmatch(data); match != nil {
+		extracted.Title = decodePDFString(unescapePDFString(match[1]))
+	}
+	if !mostlyReadable(extracted.Text) {
+		return nil, errors.New("PDF text uses a font encoding that can't be read")
+	}
+	return extracted, nil
+}
+
+var (
+	pdfStreamStart = regexp.MustCompile(`stream\r?\n`)
+	pdfLength      = regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+	pdfTitle       = regexp.MustCompile(`/Title\s*\(((?:\\.|[^\\)])*)\)`)
+)
+
+// pdfSkippedStreams mark streams that never hold page text
+var pdfSkippedStreams = [][]byte{
+	[]byte("/Image"), []byte("/Length1"), []byte("/Length2"), []byte("/FontFile"),
+	[]byte("/ObjStm"), []byte("/XRef"), []byte("/Metadata"), []byte("/EmbeddedFile"),
+}
+
+// pdfStreams returns the decoded content of every stream that may hold
+// page text. Streams with filters other than Flate are skipped. It fails
+// once the decoded streams pass maxPDFDecoded.
+func pdfStreams(data []byte) ([][]byte, error) {
+	var streams [][]byte
+	budget := maxPDFDecoded
+	for _, loc := range pdfStreamStart.FindAllIndex(data, -1) {
+		// The dictionary sits between the object header and "stream"
+		header := data[:loc[0]]
+		if !bytes.HasSuffix(bytes.TrimRight(header, " \r\n\t"), []byte(">>")) {
+			continue
+		}
+		if obj := bytes.LastIndex(header, []byte(" obj")); obj >= 0 {
+			header = header[obj:]
+		}
+		if pdfSkipped(header) {
+			continue
+		}
+
+		start := loc[1]
+		end := -1
+		if match := pdfLength.FindSubmatch(header); match != nil && match[2] == nil {
+			if length, err := strconv.Atoi(string(match[1])); err == nil && start+length <= len(data) {
+				end = start + length
+			}
+		}
+		if end < 0 || !bytes.HasPrefix(bytes.TrimLeft(data[end:], "\r\n "), []byte("endstream")) {
+			// Indirect or wrong lengths are common; fall back to the marker
+			end = bytes.Index(data[start:], []byte("endstream"))
+			if end < 0 {
+				continue
+			}
+			end += start
+		}
+		raw := data[start:end]
+
+		switch {
+		case bytes.Contains(header, []byte("/Filter")) && !bytes.Contains(header, []byte("/FlateDecode")):
+			continue
+		case bytes.Contains(header, []byte("/FlateDecode")):
+			decoded, err := inflate(raw, budget)
+			if errors.Is(err, errPDFTooLarge) {
+				return nil, err
+			}
+			if err != nil {
+				continue
+			}
+			budget -= len(decoded)
+			streams = append(streams, decoded)
+		default:
+			if budget -= len(raw); budget < 0 {
+				return nil, errPDFTooLarge
+			}
+			streams = append(streams, raw)
+		}
+	}
+	return streams, nil
+}
+
+func pdfSkipped(header []byte) bool {
+	for _, marker := range pdfSkippedStreams {
+		if bytes.Contains(header, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxPDFStream bounds a decompressed stream, and maxPDFDecoded all of a
+// file's streams together, so a small file can't expand without limit
+const (
+	maxPDFStream  = 50 << 20
+	maxPDFDecoded = 100 << 20
+)
+
+var errPDFTooLarge = fmt.Errorf("PDF content expands to more than %d MB", maxPDFDecoded>>20)
+
+// inflate decompresses a Flate stream, failing with errPDFTooLarge if it
+// holds more than budget bytes. A stream past maxPDFStream is cut short.
+func inflate(raw []byte, budget int) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	limit := min(maxPDFStream, budget+1)
+	decoded, err := io.ReadAll(io.LimitReader(reader, int64(limit)))
+	if len(decoded) > budget {
+		return nil, errPDFTooLarge
+	}
+	// Streams truncated by a bad length still yield usable text
+	if err != nil && len(decoded) == 0 {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// pdfContentText interprets the text operators of a content stream: Tj, TJ,
+// ' and " show strings, and T*, Td, TD and Tm moving down start new lines
+func pdfContentText(content []byte) string {
+	var text strings.Builder
+	var operands []interface{}
+	var lastLineY float64
+	lexer := &pdfLexer{data: content}
+
+	for {
+		token, ok := lexer.next()
+		if !ok {
+			break
+		}
+		switch t := token.(type) {
+		case pdfOperator:
+			switch t {
+			case "Tj":
+				if s, ok := lastString(operands); ok {
+					text.WriteString(s)
+				}
+			case "'", "\"":
+				text.WriteString("\n")
+				if s, ok := lastString(operands); ok {
+					text.WriteString(s)
+				}
+			case "TJ":
+				for _, item := range operands {
+					switch v := item.(type) {
+					case string:
+						text.WriteString(v)
+					case float64:
+						// Large negative kerning is a word gap
+						if v < -200 {
+							text.WriteString(" ")
+						}
+					}
+				}
+			case "T*", "ET":
+				text.WriteString("\n")
+			case "Td", "TD":
+				if len(operands) >= 2 {
+					if ty, ok := operands[len(operands)-1].(float64); ok && ty != 0 {
+						text.WriteString("\n")
+					} else {
+						text.WriteString(" ")
+					}
+				}
+			case "Tm":
+				if len(operands) >= 6 {
+					if y, ok := operands[len(operands)-1].(float64); ok {
+						if y != lastLineY {
+							text.WriteString("\n")
+						} else {
+							text.WriteString(" ")
+						}
+						lastLineY = y
+					}
+				}
+			case "BI":
+				lexer.skipInlineImage()
+			}
+			operands = operands[:0]
+		case pdfArrayEnd:
+			// TJ's array operands are flattened onto the stack
+		default:
+			operands = append(operands, token)
+		}
+	}
+	return text.String()
+}
+
+func lastString(operands []interface{}) (string, bool) {
+	if len(operands) == 0 {
+		return "", false
+	}
+	s, ok := operands[len(operands)-1].(string)
+	return s, ok
+}
+
+type (
+	pdfOperator string
+	pdfName     string
+	pdfArrayEnd struct{}
+)
+
+// pdfLexer splits a content stream into operands and operators. Strings
+// are returned decoded, numbers as float64.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (interface{}, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return decodePDFString(l.literalString()), true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			l.pos += 2
+		case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+			l.pos += 2
+		case c == '<':
+			return decodePDFString(l.hexString()), true
+		case c == '[':
+			l.pos++
+		case c == ']':
+			l.pos++
+			return pdfArrayEnd{}, true
+		case c == '/':
+			l.pos++
+			return pdfName(l.word()), true
+		default:
+			word := l.word()
+			if word == "" {
+				// A stray delimiter
+				l.pos++
+				continue
+			}
+			if n, err := strconv.ParseFloat(word, 64); err == nil {
+				return n, true
+			}
+			return pdfOperator(word), true
+		}
+	}
+	return nil, false
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *pdfLexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+// literalString reads a (...) string, which may nest balanced parentheses
+func (l *pdfLexer) literalString() []byte {
+	l.pos++
+	start := l.pos
+	depth := 1
+	for l.pos < len(l.data) {
+		switch l.data[l.pos] {
+		case '\\':
+			l.pos++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				raw := l.data[start:l.pos]
+				l.pos++
+				return unescapePDFString(raw)
+			}
+		}
+		l.pos++
+	}
+	return unescapePDFString(l.data[start:])
+}
+
+func (l *pdfLexer) hexString() []byte {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if c := l.data[l.pos]; unicode.Is(unicode.ASCII_Hex_Digit, rune(c)) {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(n)
+	}
+	return out
+}
+
+// skipInlineImage jumps past the binary data of an inline image to EI
+func (l *pdfLexer) skipInlineImage() {
+	end := bytes.Index(l.data[l.pos:], []byte("EI"))
+	if end < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += end + 2
+}
+
+// unescapePDFString resolves backslash escapes in a literal string
+func unescapePDFString(raw []byte) []byte {
+	out := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' || i+1 == len(raw) {
+			out = append(out, c)
+			continue
+		}
+		i++
+		switch c = raw[i]; c {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case '\r', '\n':
+			// A backslash at a line end continues the string
+			if c == '\r' && i+1 < len(raw) && raw[i+1] == '\n' {
+				i++
+			}
+		default:
+			if c >= '0' && c <= '7' {
+				n := 0
+				j := i
+				for ; j < len(raw) && j < i+3 && raw[j] >= '0' && raw[j] <= '7'; j++ {
+					n = n*8 + int(raw[j]-'0')
+				}
+				out = append(out, byte(n))
+				i = j - 1
+			} else {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// decodePDFString reads UTF-16 strings marked with a byte order mark and
+// treats anything else as Latin-1, close enough to PDFDocEncoding for text
+func decodePDFString(raw []byte) string {
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// mostlyReadable reports whether text is mostly letters, digits, spaces
+// and punctuation. Glyph IDs decoded as characters come out as control
+// characters and stray symbols.
+func mostlyReadable(text string) bool {
+	total, readable := 0, 0
+	for _, r := range text {
+		total++
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) || unicode.IsPunct(r) {
+			readable++
+		}
+	}
+	return total == 0 || float64(readable)/float64(total) >= 0.85
+}
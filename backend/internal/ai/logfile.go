@@ -0,0 +1,96 @@
+package ai
+
+import (
+	"bytes"
+	"os"
+)
+
+// maxLogSize is how large an append-only JSONL log grows before it's
+// rotated. The previous file is kept as <name>.1, so a log takes at most
+// twice this on disk.
+const maxLogSize = 10 << 20
+
+// logReadBlock is how much of a log is read at a time from the end
+const logReadBlock = 64 << 10
+
+// appendLogLine appends line to the log at path, first moving a full log
+// aside to path.1. The caller serializes appends.
+func appendLogLine(path string, line []byte) error {
+	if info, err := os.Stat(path); err == nil && info.Size()+int64(len(line))+1 > maxLogSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// tailLogLines returns up to the last n lines of the log at path,
+// continuing into the rotated file when the current one is short, oldest
+// first. Only the end of each file is read.
+func tailLogLines(path string, n int) ([][]byte, error) {
+	lines, err := tailLines(path, n)
+	if err != nil || len(lines) >= n {
+		return lines, err
+	}
+	older, err := tailLines(path+".1", n-len(lines))
+	if err != nil {
+		return nil, err
+	}
+	return append(older, lines...), nil
+}
+
+// tailLines returns up to the last n lines of a file, oldest first, or
+// nothing if it doesn't exist
+func tailLines(path string, n int) ([][]byte, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read blocks back from the end until there's a newline before the
+	// last n lines, so the first line kept is whole
+	end := info.Size()
+	var data []byte
+	newlines := 0
+	for end > 0 && newlines <= n {
+		start := max(0, end-logReadBlock)
+		block := make([]byte, end-start)
+		if _, err := file.ReadAt(block, start); err != nil {
+			return nil, err
+		}
+		newlines += bytes.Count(block, []byte("\n"))
+		data = append(block, data...)
+		end = start
+	}
+
+	data = bytes.TrimRight(data, "\n")
+	if len(data) == 0 {
+		return nil, nil
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	// The first line read is cut off unless it starts the file
+	if end > 0 {
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
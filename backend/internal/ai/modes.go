@@ -0,0 +1,238 @@
+package ai
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultChatMode is used when a request doesn't name a mode
+const DefaultChatMode = "general"
+
+// Built-in mode templates, copied to the modes directory on first run so
+// they can be edited there
+//
+//go:embed modes/*.json
+var defaultModes embed.FS
+
+var (
+	ErrUnknownChatMode    = errors.New("unknown chat mode")
+	ErrChatModeNotAllowed = errors.New("chat mode not available for this role")
+)
+
+var modeNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ChatMode is a persona for one app module: its system prompt, the tools
+// and knowledge base categories it may use and extra screener rules
+type ChatMode struct {
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	// Roles that may select the mode; empty means everyone
+	Roles        []string `json:"roles,omitempty"`
+	SystemPrompt string   `json:"system_prompt"`
+	// Tools the mode may call, further limited by role. Null allows every
+	// tool the role may use; an empty list disables tools.
+	Tools []string `json:"tools"`
+	// RAGCategories limits retrieval to these categories; empty searches all
+	RAGCategories []string      `json:"rag_categories,omitempty"`
+	Screener      ScreenerRules `json:"screener"`
+}
+
+// ScreenerRules adjust the prompt screener for a mode
+type ScreenerRules struct {
+	// Keywords that mark a prompt as on-topic in addition to the defaults
+	Keywords []string `json:"keywords,omitempty"`
+	// BlockedPatterns are rejected in addition to the defaults, unless the
+	// prompt is otherwise on-topic
+	BlockedPatterns []string `json:"blocked_patterns,omitempty"`
+	// Deny rejects prompts the mode shouldn't handle, however on-topic
+	Deny []string `json:"deny,omitempty"`
+	// ClassifierThreshold overrides the on-topic probability needed when
+	// no keyword matches
+	ClassifierThreshold float64 `json:"classifier_threshold,omitempty"`
+}
+
+// Allows reports whether role may use the mode
+func (m *ChatMode) Allows(role string) bool {
+	return len(m.Roles) == 0 || contains(m.Roles, role)
+}
+
+// Validate checks the fields needed to serve the mode
+func (m *ChatMode) Validate() error {
+	if !modeNamePattern.MatchString(m.Name) {
+		return fmt.Errorf("invalid mode name %q", m.Name)
+	}
+	if strings.TrimSpace(m.SystemPrompt) == "" {
+		return errors.New("system_prompt is required")
+	}
+	return nil
+}
+
+// ChatModeStore holds chat modes, one JSON file per mode
+type ChatModeStore struct {
+	mu       sync.RWMutex
+	modes    map[string]ChatMode
+	aliases  map[string]string
+	dataPath string
+}
+
+func NewChatModeStore(dataPath string) (*ChatModeStore, error) {
+	store := &ChatModeStore{dataPath: dataPath}
+
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		return nil, err
+	}
+	if err := store.seedModes(); err != nil {
+		return nil, fmt.Errorf("failed to seed chat modes: %w", err)
+	}
+	if err := store.loadModes(); err != nil {
+		return nil, err
+	}
+	if _, ok := store.modes[DefaultChatMode]; !ok {
+		return nil, fmt.Errorf("chat mode %q is missing from %s", DefaultChatMode, dataPath)
+	}
+
+	return store, nil
+}
+
+// seedModes writes any built-in mode that has no file yet, leaving edited
+// files alone
+func (s *ChatModeStore) seedModes() error {
+	entries, err := defaultModes.ReadDir("modes")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		filePath := filepath.Join(s.dataPath, entry.Name())
+		if _, err := os.Stat(filePath); err == nil {
+			continue
+		}
+		data, err := defaultModes.ReadFile("modes/" + entry.Name())
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filePath, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ChatModeStore) loadModes() error {
+	files, err := filepath.Glob(filepath.Join(s.dataPath, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	s.modes = make(map[string]ChatMode, len(files))
+	s.aliases = make(map[string]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var mode ChatMode
+		if err := json.Unmarshal(data, &mode); err != nil {
+			return fmt.Errorf("failed to parse chat mode %s: %w", file, err)
+		}
+		if err := mode.Validate(); err != nil {
+			return fmt.Errorf("chat mode %s: %w", file, err)
+		}
+		s.add(mode)
+	}
+	return nil
+}
+
+func (s *ChatModeStore) add(mode ChatMode) {
+	s.modes[mode.Name] = mode
+	for _, alias := range mode.Aliases {
+		s.aliases[alias] = mode.Name
+	}
+}
+
+// Get returns the mode called name, also matching aliases
+func (s *ChatModeStore) Get(name string) (ChatMode, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if canonical, ok := s.aliases[name]; ok {
+		name = canonical
+	}
+	mode, ok := s.modes[name]
+	return mode, ok
+}
+
+// Resolve picks the mode for a chat request. An empty name selects the
+// default mode.
+func (s *ChatModeStore) Resolve(name, role string) (ChatMode, error) {
+	if name == "" {
+		name = DefaultChatMode
+	}
+	mode, ok := s.Get(name)
+	if !ok {
+		return ChatMode{}, fmt.Errorf("%w: %s", ErrUnknownChatMode, name)
+	}
+	if !mode.Allows(role) {
+		return ChatMode{}, fmt.Errorf("%w: %s", ErrChatModeNotAllowed, name)
+	}
+	return mode, nil
+}
+
+// List returns all modes sorted by name
+func (s *ChatModeStore) List() []ChatMode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	modes := make([]ChatMode, 0, len(s.modes))
+	for _, mode := range s.modes {
+		modes = append(modes, mode)
+	}
+	sort.Slice(modes, func(i, j int) bool { return modes[i].Name < modes[j].Name })
+	return modes
+}
+
+// Save creates or replaces a mode and writes its file
+func (s *ChatModeStore) Save(mode ChatMode) error {
+	if err := mode.Validate(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(mode, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.WriteFile(filepath.Join(s.dataPath, mode.Name+".json"), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	// Drop aliases the previous version had
+	for alias, name := range s.aliases {
+		if name == mode.Name {
+			delete(s.aliases, alias)
+		}
+	}
+	s.add(mode)
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,342 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"serpico/backend/internal/metrics"
+)
+
+// OpenAIClient talks to any OpenAI-compatible chat completions API,
+// including local llama.cpp and Ollama servers
+type OpenAIClient struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func NewOpenAIClient(baseURL, apiKey, model string) *OpenAIClient {
+	return &OpenAIClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client: &http.Client{
+			// Local models on CPU can be slow to produce a full answer
+			Timeout: 120 * time.Second,
+		},
+	}
+}
+
+// OpenAIChatRequest represents a chat completions request
+type OpenAIChatRequest struct {
+	Model         string               `json:"model"`
+	Messages      []OpenAIMessage      `json:"messages"`
+	Tools         []OpenAITool         `json:"tools,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *OpenAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type OpenAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// OpenAITool declares a function the model may call
+type OpenAITool struct {
+	Type     string             `json:"type"`
+	Function OpenAIToolFunction `json:"function"`
+}
+
+type OpenAIToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// OpenAIToolCall is a function call in a response. When streaming, Index
+// identifies which call a fragment belongs to.
+type OpenAIToolCall struct {
+	Index    *int                   `json:"index,omitempty"`
+	ID       string                 `json:"id,omitempty"`
+	Type     string                 `json:"type,omitempty"`
+	Function OpenAIToolCallFunction `json:"function"`
+}
+
+type OpenAIToolCallFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// OpenAIChatResponse represents a chat completions response
+type OpenAIChatResponse struct {
+	Choices []OpenAIChoice `json:"choices"`
+	Usage   OpenAIUsage    `json:"usage"`
+}
+
+type OpenAIChoice struct {
+	Message OpenAIMessage `json:"message"`
+	Delta   OpenAIMessage `json:"delta"`
+}
+
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+func (o *OpenAIClient) Name() string {
+	return "openai"
+}
+
+// Generate sends the conversation to the chat completions endpoint
+func (o *OpenAIClient) Generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
+	start := time.Now()
+	resp, err := o.generate(ctx, req)
+	var usage Usage
+	if resp != nil {
+		usage = resp.Usage
+	}
+	metrics.ObserveLLMRequest(o.Name(), o.model, time.Since(start), usage.PromptTokens, usage.CompletionTokens, err)
+	return resp, err
+}
+
+func (o *OpenAIClient) generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
+	request := o.buildRequest(req)
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	o.authorize(httpReq)
+
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var chatResp OpenAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("empty response from API")
+	}
+	message := chatResp.Choices[0].Message
+	if message.Content == "" && len(message.ToolCalls) == 0 {
+		return nil, fmt.Errorf("empty response from API")
+	}
+
+	return &GenerateResponse{
+		Text:      message.Content,
+		ToolCalls: toToolCalls(message.ToolCalls),
+		Provider:  o.Name(),
+		Model:     o.model,
+		Usage: Usage{
+			PromptTokens:     chatResp.Usage.PromptTokens,
+			CompletionTokens: chatResp.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+// GenerateStream requests a streamed completion and relays content deltas
+func (o *OpenAIClient) GenerateStream(ctx context.Context, req GenerateRequest, onChunk StreamFunc) (*GenerateResponse, error) {
+	start := time.Now()
+	resp, err := o.generateStream(ctx, req, onChunk)
+	var usage Usage
+	if resp != nil {
+		usage = resp.Usage
+	}
+	metrics.ObserveLLMRequest(o.Name(), o.model, time.Since(start), usage.PromptTokens, usage.CompletionTokens, err)
+	return resp, err
+}
+
+func (o *OpenAIClient) generateStream(ctx context.Context, req GenerateRequest, onChunk StreamFunc) (*GenerateResponse, error) {
+	request := o.buildRequest(req)
+	request.Stream = true
+	request.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	o.authorize(httpReq)
+
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API error: %d - %s", resp.StatusCode, string(body))
+	}
+
+	result := &GenerateResponse{Provider: o.Name(), Model: o.model}
+	var text strings.Builder
+	// Tool calls arrive in fragments keyed by index
+	var calls []OpenAIToolCall
+	err = readSSE(resp.Body, func(data []byte) error {
+		if string(data) == "[DONE]" {
+			return errStopSSE
+		}
+
+		var chunk OpenAIChatResponse
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to decode stream chunk: %w", err)
+		}
+		if chunk.Usage.PromptTokens > 0 || chunk.Usage.CompletionTokens > 0 {
+			result.Usage = Usage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+			}
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+		for _, call := range chunk.Choices[0].Delta.ToolCalls {
+			calls = mergeToolCallDelta(calls, call)
+		}
+		if chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		text.WriteString(delta)
+		return onChunk(delta)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if text.Len() == 0 && len(calls) == 0 {
+		return nil, fmt.Errorf("empty response from API")
+	}
+	result.Text = text.String()
+	result.ToolCalls = toToolCalls(calls)
+	return result, nil
+}
+
+// mergeToolCallDelta folds a streamed tool call fragment into calls
+func mergeToolCallDelta(calls []OpenAIToolCall, delta OpenAIToolCall) []OpenAIToolCall {
+	index := len(calls)
+	if delta.Index != nil {
+		index = *delta.Index
+	}
+	for len(calls) <= index {
+		calls = append(calls, OpenAIToolCall{Type: "function"})
+	}
+
+	call := &calls[index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Function.Name != "" {
+		call.Function.Name = delta.Function.Name
+	}
+	call.Function.Arguments += delta.Function.Arguments
+	return calls
+}
+
+func toToolCalls(calls []OpenAIToolCall) []ToolCall {
+	var result []ToolCall
+	for _, call := range calls {
+		args := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(args) {
+			args = json.RawMessage("{}")
+		}
+		result = append(result, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: args})
+	}
+	return result
+}
+
+// buildRequest maps a provider-neutral request onto the chat completions format
+func (o *OpenAIClient) buildRequest(req GenerateRequest) OpenAIChatRequest {
+	request := OpenAIChatRequest{Model: o.model}
+	if req.SystemPrompt != "" {
+		request.Messages = append(request.Messages, OpenAIMessage{Role: "system", Content: req.SystemPrompt})
+	}
+	for _, tool := range req.Tools {
+		request.Tools = append(request.Tools, OpenAITool{
+			Type: "function",
+			Function: OpenAIToolFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	for _, msg := range req.Messages {
+		message := OpenAIMessage{Role: msg.Role, Content: msg.Content, ToolCallID: msg.ToolCallID}
+		for _, call := range msg.ToolCalls {
+			message.ToolCalls = append(message.ToolCalls, OpenAIToolCall{
+				ID:   call.ID,
+				Type: "function",
+				Function: OpenAIToolCallFunction{
+					Name:      call.Name,
+					Arguments: string(call.Arguments),
+				},
+			})
+		}
+		request.Messages = append(request.Messages, message)
+	}
+	return request
+}
+
+// Ping lists models, which every OpenAI-compatible server supports
+func (o *OpenAIClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", o.baseURL+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	o.authorize(req)
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API error: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (o *OpenAIClient) authorize(req *http.Request) {
+	// Local servers usually don't need a key
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+}
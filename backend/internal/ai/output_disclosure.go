@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// defaultDisclosureWindow is how far from a name details are searched
+// when the rules don't say
+const defaultDisclosureWindow = 200
+
+// disclosures finds non-public perp details and officers' personal data in
+// an answer. A detail only counts near the perp's alias or ID or the
+// officer's name, so "Downtown Olathe" alone isn't a disclosure, except for
+// an officer's vehicle plate, which identifies them anywhere. If the
+// records can't be read the answer passes, with a warning, rather than
+// failing every chat.
+func (g *OutputGuard) disclosures(ctx context.Context, config SafetyConfig, answer string) []SafetyReason {
+	if g.db == nil {
+		return nil
+	}
+	window := config.Disclosure.Window
+	if window == 0 {
+		window = defaultDisclosureWindow
+	}
+	near := func(start, end int) string {
+		return answer[max(0, start-window):min(len(answer), end+window)]
+	}
+
+	var reasons []SafetyReason
+	if len(config.Disclosure.PerpDetails) > 0 {
+		perps, err := g.db.AllPerps()
+		if err != nil {
+			slog.WarnContext(ctx, "failed to load perps for output safety check", "error", err)
+		}
+		for _, perp := range perps {
+			values := map[string]string{PerpLocation: perp.Location, PerpLastSeen: perp.LastSeen, PerpStatus: perp.Status}
+			var found []string
+			for _, loc := range append(findWord(answer, perp.Alias), findWord(answer, perp.ID)...) {
+				text := near(loc[0], loc[1])
+				for _, field := range config.Disclosure.PerpDetails {
+					if !contains(found, field) && len(findWord(text, values[field])) > 0 {
+						found = append(found, field)
+					}
+				}
+			}
+			if len(found) > 0 {
+				reasons = append(reasons, SafetyReason{
+					Code:   SafetyPerpDisclosure,
+					Detail: fmt.Sprintf("%s: %s", perp.ID, strings.Join(found, ", ")),
+				})
+			}
+		}
+	}
+
+	if len(config.Disclosure.OfficerDetails) > 0 {
+		officers, err := g.db.AllOfficers()
+		if err != nil {
+			slog.WarnContext(ctx, "failed to load officers for output safety check", "error", err)
+		}
+		findings := ScanPII(answer)
+		for _, officer := range officers {
+			var found []string
+			if contains(config.Disclosure.OfficerDetails, OfficerPlate) && len(findWord(answer, officer.VehiclePlate)) > 0 {
+				found = append(found, OfficerPlate)
+			}
+			for _, loc := range findWord(answer, strings.TrimPrefix(officer.Name, "Officer ")) {
+				start, end := max(0, loc[0]-window), min(len(answer), loc[1]+window)
+				for _, f := range findings {
+					if f.Start < end && start < f.End && contains(config.Disclosure.OfficerDetails, f.Type) && !contains(found, f.Type) {
+						found = append(found, f.Type)
+					}
+				}
+			}
+			if len(found) > 0 {
+				reasons = append(reasons, SafetyReason{
+					Code:   SafetyOfficerData,
+					Detail: fmt.Sprintf("%s: %s", officer.ID, strings.Join(found, ", ")),
+				})
+			}
+		}
+	}
+	return reasons
+}
+
+// findWord returns where phrase appears in text as whole words, ignoring
+// case, or nil for an empty phrase
+func findWord(text, phrase string) [][]int {
+	phrase = strings.TrimSpace(phrase)
+	if phrase == "" {
+		return nil
+	}
+	pattern := regexp.QuoteMeta(phrase)
+	// \b only sits between a word character and something else, so it's
+	// left off ends that aren't word characters
+	if isWordByte(phrase[0]) {
+		pattern = `\b` + pattern
+	}
+	if isWordByte(phrase[len(phrase)-1]) {
+		pattern += `\b`
+	}
+	return regexp.MustCompile(`(?i)`+pattern).FindAllStringIndex(text, -1)
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
@@ -0,0 +1,404 @@
+package ai
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"serpico/backend/internal/database"
+	"serpico/backend/internal/metrics"
+)
+
+// Built-in output safety rules, copied to the safety directory on first
+// run so they can be tuned there
+//
+//go:embed safety/safety.json
+var defaultSafetyConfig []byte
+
+const (
+	safetyConfigFile = "safety.json"
+	safetyAuditFile  = "audit.jsonl"
+)
+
+// maxAuditedText caps how much of a question or answer is audited
+const maxAuditedText = 2000
+
+// What the output safety filter did with an answer
+const (
+	SafetyAllowed = "allowed"
+	// SafetyAmended answers had cautions or disclaimers appended
+	SafetyAmended = "amended"
+	// SafetyBlocked answers were replaced with the blocked message
+	SafetyBlocked = "blocked"
+)
+
+// Why the output safety filter blocked or amended an answer, also used for
+// metrics
+const (
+	SafetyPerpDisclosure = "perp_disclosure"
+	SafetyOfficerData    = "officer_personal_data"
+	SafetyPolicyConflict = "policy_conflict"
+	SafetyDisclaimer     = "disclaimer"
+)
+
+// Perp fields that count as non-public details
+const (
+	PerpLocation = "location"
+	PerpLastSeen = "last_seen"
+	PerpStatus   = "status"
+)
+
+// OfficerPlate is the officer detail for a vehicle plate. The other
+// officer details are PII types.
+const OfficerPlate = "plate"
+
+// SafetyDisclaimerConfig is text appended to answers for some roles, when
+// the question or answer mentions one of its triggers. Triggers match like
+// screener phrases; none means every answer.
+type SafetyDisclaimerConfig struct {
+	ID string `json:"id"`
+	// Roles the disclaimer applies to; empty means every role
+	Roles    []string `json:"roles,omitempty"`
+	Triggers []string `json:"triggers,omitempty"`
+	Text     string   `json:"text"`
+}
+
+// SafetyConfig is the output safety filter's rules file
+type SafetyConfig struct {
+	Enabled bool `json:"enabled"`
+	// RestrictedRoles can't be told non-public perp details or officers'
+	// personal data. Their streamed answers are held back until checked.
+	RestrictedRoles []string `json:"restricted_roles"`
+	// BlockedMessage replaces an answer that would disclose either
+	BlockedMessage string `json:"blocked_message"`
+	Disclosure     struct {
+		// PerpDetails are the perp fields that can't be given alongside
+		// the perp's alias or ID
+		PerpDetails []string `json:"perp_details"`
+		// OfficerDetails are "plate" and the PII types that can't be given
+		// alongside an officer's name. A plate is blocked wherever it is.
+		OfficerDetails []string `json:"officer_details"`
+		// Window is how many bytes either side of a name are searched
+		Window int `json:"window"`
+	} `json:"disclosure"`
+	Policy struct {
+		Enabled bool `json:"enabled"`
+		// Categories of published documents holding policy directives
+		Categories []string `json:"categories"`
+		// MinOverlap is the share of a directive's terms an answer
+		// sentence needs to be compared with it
+		MinOverlap float64 `json:"min_overlap"`
+		// Caution introduces the directives an answer may conflict with
+		Caution string `json:"caution"`
+	} `json:"policy"`
+	Disclaimers []SafetyDisclaimerConfig `json:"disclaimers"`
+	// Audit appends every blocked or amended answer to audit.jsonl
+	Audit bool `json:"audit"`
+}
+
+// validate checks the rules name fields and types the filter knows
+func (c *SafetyConfig) validate() error {
+	if c.BlockedMessage == "" {
+		return errors.New("blocked_message is required")
+	}
+	for _, field := range c.Disclosure.PerpDetails {
+		if field != PerpLocation && field != PerpLastSeen && field != PerpStatus {
+			return fmt.Errorf("unknown perp detail %q", field)
+		}
+	}
+	for _, field := range c.Disclosure.OfficerDetails {
+		if field != OfficerPlate && !contains(PIITypes, field) {
+			return fmt.Errorf("unknown officer detail %q", field)
+		}
+	}
+	if c.Disclosure.Window < 0 {
+		return errors.New("disclosure window can't be negative")
+	}
+	if c.Policy.MinOverlap <= 0 || c.Policy.MinOverlap > 1 {
+		return errors.New("policy min_overlap must be above 0 and at most 1")
+	}
+	for i, disclaimer := range c.Disclaimers {
+		if disclaimer.ID == "" || disclaimer.Text == "" {
+			return fmt.Errorf("disclaimer %d needs an id and text", i+1)
+		}
+	}
+	return nil
+}
+
+// SafetyReason is one reason an answer was blocked or amended
+type SafetyReason struct {
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+	// DocumentID is the policy document behind a policy conflict
+	DocumentID string `json:"document_id,omitempty"`
+}
+
+// SafetyReview is the output safety filter's verdict on an answer
+type SafetyReview struct {
+	Action  string         `json:"action"`
+	Reasons []SafetyReason `json:"reasons,omitempty"`
+	// Content is the answer to send: the blocked message, or the answer
+	// with Appended added
+	Content string `json:"content"`
+	// Appended is the cautions and disclaimers added to the answer
+	Appended string `json:"appended,omitempty"`
+}
+
+// SafetyAuditEntry is an entry in the output safety audit log
+type SafetyAuditEntry struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Role      string         `json:"role"`
+	Mode      string         `json:"mode"`
+	Action    string         `json:"action"`
+	Reasons   []SafetyReason `json:"reasons"`
+	// Question and Answer, as generated, are redacted under the role's PII
+	// log policy
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// safetyDisclaimer is a disclaimer with its triggers compiled
+type safetyDisclaimer struct {
+	SafetyDisclaimerConfig
+	triggers []screenPhrase
+}
+
+// OutputGuard checks answers after they're generated: blocking non-public
+// perp details and officers' personal data for restricted roles, flagging
+// advice that contradicts published policy and appending disclaimers. Its
+// rules are loaded from a directory and can be reloaded while serving.
+type OutputGuard struct {
+	mu          sync.RWMutex
+	config      SafetyConfig
+	disclaimers []safetyDisclaimer
+	dataPath    string
+	rag         *RAGDatabase
+	// db holds the perps and officers checked for; nil skips disclosure
+	// checks
+	db *database.Database
+	// pii redacts the audit log
+	pii *PIIGuard
+	// logMu serializes appends to the audit log
+	logMu sync.Mutex
+}
+
+func NewOutputGuard(dataPath string, rag *RAGDatabase, db *database.Database, pii *PIIGuard) (*OutputGuard, error) {
+	g := &OutputGuard{dataPath: dataPath, rag: rag, db: db, pii: pii}
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		return nil, err
+	}
+	// Seed the built-in rules, leaving edited ones alone
+	configPath := filepath.Join(dataPath, safetyConfigFile)
+	if _, err := os.Stat(configPath); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(configPath, defaultSafetyConfig, 0644); err != nil {
+			return nil, fmt.Errorf("failed to seed output safety rules: %w", err)
+		}
+	}
+	if err := g.Reload(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Reload rereads the rules. On error the current rules stay in place.
+func (g *OutputGuard) Reload() error {
+	data, err := os.ReadFile(filepath.Join(g.dataPath, safetyConfigFile))
+	if err != nil {
+		return err
+	}
+	var config SafetyConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("%s: %w", safetyConfigFile, err)
+	}
+	if err := config.validate(); err != nil {
+		return fmt.Errorf("%s: %w", safetyConfigFile, err)
+	}
+
+	disclaimers := make([]safetyDisclaimer, len(config.Disclaimers))
+	for i, disclaimer := range config.Disclaimers {
+		disclaimers[i] = safetyDisclaimer{SafetyDisclaimerConfig: disclaimer, triggers: compilePhrases(disclaimer.Triggers)}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.config = config
+	g.disclaimers = disclaimers
+	return nil
+}
+
+// Config returns the loaded rules
+func (g *OutputGuard) Config() SafetyConfig {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.config
+}
+
+// Restricts reports whether answers for role are checked for disclosures,
+// so a stream must be held back until the answer is complete
+func (g *OutputGuard) Restricts(role string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.config.Enabled && contains(g.config.RestrictedRoles, defaultRole(role))
+}
+
+// Review checks an answer to question for role without recording
+// anything, for trying out the rules
+func (g *OutputGuard) Review(ctx context.Context, role, question, answer string) SafetyReview {
+	role = defaultRole(role)
+	g.mu.RLock()
+	config := g.config
+	disclaimers := g.disclaimers
+	g.mu.RUnlock()
+
+	review := SafetyReview{Action: SafetyAllowed, Content: answer}
+	if !config.Enabled {
+		return review
+	}
+
+	if contains(config.RestrictedRoles, role) {
+		if reasons := g.disclosures(ctx, config, answer); len(reasons) > 0 {
+			review.Action = SafetyBlocked
+			review.Reasons = reasons
+			review.Content = config.BlockedMessage
+			return review
+		}
+	}
+
+	var notes []string
+	if config.Policy.Enabled {
+		var cautions []string
+		for _, conflict := range g.policyConflicts(config, answer) {
+			review.Reasons = append(review.Reasons, SafetyReason{
+				Code:       SafetyPolicyConflict,
+				Detail:     fmt.Sprintf("%q conflicts with %q", conflict.sentence, conflict.rule.text),
+				DocumentID: conflict.rule.documentID,
+			})
+			cautions = append(cautions, fmt.Sprintf("- %s: %s", conflict.rule.title, conflict.rule.text))
+		}
+		if len(cautions) > 0 {
+			notes = append(notes, config.Policy.Caution+"\n"+strings.Join(cautions, "\n"))
+		}
+	}
+
+	words := screenWords(question + "\n" + answer)
+	for _, disclaimer := range disclaimers {
+		if len(disclaimer.Roles) > 0 && !contains(disclaimer.Roles, role) || strings.Contains(answer, disclaimer.Text) {
+			continue
+		}
+		trigger := "always"
+		if len(disclaimer.triggers) > 0 {
+			var ok bool
+			if trigger, ok = matchPhrases(words, disclaimer.triggers); !ok {
+				continue
+			}
+		}
+		review.Reasons = append(review.Reasons, SafetyReason{
+			Code:   SafetyDisclaimer,
+			Detail: fmt.Sprintf("%s (%s)", disclaimer.ID, trigger),
+		})
+		notes = append(notes, disclaimer.Text)
+	}
+
+	if len(notes) > 0 {
+		review.Action = SafetyAmended
+		review.Appended = "\n\n" + strings.Join(notes, "\n\n")
+		review.Content = answer + review.Appended
+	}
+	return review
+}
+
+// ReviewAnswer is Review with the outcome recorded in metrics and the
+// audit log
+func (g *OutputGuard) ReviewAnswer(ctx context.Context, role, mode, question, answer string) SafetyReview {
+	review := g.Review(ctx, role, question, answer)
+	if review.Action == SafetyAllowed {
+		return review
+	}
+	role = defaultRole(role)
+	for _, reason := range review.Reasons {
+		metrics.ObserveOutputReview(reason.Code, role)
+	}
+	if review.Action == SafetyBlocked {
+		slog.WarnContext(ctx, "blocked answer disclosing restricted details", "role", role, "reason", review.Reasons[0].Code)
+	}
+	g.audit(role, mode, question, answer, review)
+	return review
+}
+
+// audit appends a blocked or amended answer to the audit log, if it's
+// enabled. Failures are logged and otherwise ignored, as they shouldn't
+// fail chat.
+func (g *OutputGuard) audit(role, mode, question, answer string, review SafetyReview) {
+	g.mu.RLock()
+	enabled := g.config.Audit
+	g.mu.RUnlock()
+	if !enabled {
+		return
+	}
+
+	line, err := json.Marshal(SafetyAuditEntry{
+		Timestamp: time.Now().UTC(),
+		Role:      role,
+		Mode:      mode,
+		Action:    review.Action,
+		Reasons:   review.Reasons,
+		Question:  g.auditText(role, question),
+		Answer:    g.auditText(role, answer),
+	})
+	if err != nil {
+		slog.Warn("failed to encode output safety audit entry", "error", err)
+		return
+	}
+
+	g.logMu.Lock()
+	defer g.logMu.Unlock()
+	if err := appendLogLine(filepath.Join(g.dataPath, safetyAuditFile), line); err != nil {
+		slog.Warn("failed to write output safety audit log", "error", err)
+	}
+}
+
+func (g *OutputGuard) auditText(role, text string) string {
+	if g.pii != nil {
+		text = g.pii.Redact(role, PIIUseLog, text)
+	}
+	if runes := []rune(text); len(runes) > maxAuditedText {
+		text = string(runes[:maxAuditedText])
+	}
+	return text
+}
+
+// Audit returns the most recent blocked or amended answers, newest first
+func (g *OutputGuard) Audit(limit int) ([]SafetyAuditEntry, error) {
+	g.logMu.Lock()
+	lines, err := tailLogLines(filepath.Join(g.dataPath, safetyAuditFile), limit)
+	g.logMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []SafetyAuditEntry{}
+	for i := len(lines) - 1; i >= 0; i-- {
+		var entry SafetyAuditEntry
+		// Skip a line cut short by a crash mid-write
+		if err := json.Unmarshal(lines[i], &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// defaultRole treats a missing role as a civilian, as chat does
+func defaultRole(role string) string {
+	if role == "" {
+		return ChatRoleCivilian
+	}
+	return role
+}
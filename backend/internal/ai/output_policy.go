@@ -0,0 +1,271 @@
+package ai
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// How a policy directive constrains an action
+const (
+	// directiveRequire is a plain directive, as in "Request backup
+	// immediately", contradicted by advice not to
+	directiveRequire = iota
+	// directiveProhibit, as in "Avoid high-speed chases in residential
+	// areas", is contradicted by advice to
+	directiveProhibit
+	// directiveRestrict, as in "Use PIT maneuver only on highways", is
+	// contradicted by advice to without the condition
+	directiveRestrict
+)
+
+// policyRule is a directive taken from a published policy document
+type policyRule struct {
+	documentID string
+	title      string
+	text       string
+	kind       int
+	// terms are the stemmed words of the action directed, and condition
+	// those of what a restriction allows it under
+	terms     []string
+	condition []string
+}
+
+// policyConflict is an answer sentence that contradicts a directive
+type policyConflict struct {
+	rule     policyRule
+	sentence string
+}
+
+var (
+	// listMarker starts a numbered or bulleted list item
+	listMarker = regexp.MustCompile(`(?:^|\s)(?:\d{1,2}[.)]|[-*•])\s+`)
+	// directiveMarker makes a sentence outside a list a directive
+	directiveMarker = regexp.MustCompile(`\b(must|should|shall|always|never|avoid|do not|don't|only|prohibited|required|not permitted|not allowed)\b`)
+	prohibitMarker  = regexp.MustCompile(`\b(avoid|avoiding|never|do not|don't|must not|mustn't|should not|shouldn't|shall not|may not|is prohibited|are prohibited|not permitted|not allowed)\b`)
+	onlyMarker      = regexp.MustCompile(`\bonly\b`)
+	parenthetical   = regexp.MustCompile(`\([^)]*\)`)
+	// negation marks a sentence as advising against something
+	negation = regexp.MustCompile(`\b(not|no|never|avoid\w*|don't|dont|doesn't|shouldn't|mustn't|needn't|can't|cannot|without|skip\w*|unnecessary|instead of)\b`)
+	// adviceMarker marks a sentence as advising something
+	adviceMarker = regexp.MustCompile(`\b(should|recommend\w*|suggest\w*|advis\w*|best|go ahead|proceed|feel free|okay|ok|fine|acceptable|consider|try)\b`)
+)
+
+// policyFillers are words too general to tell directives apart, such as
+// the verb in "use spike strips" or "maintain safe distance"
+var policyFillers = stemSet("use", "make", "take", "keep", "maintain", "ensure", "try", "consider", "perform",
+	"conduct", "apply", "employ", "set", "immediately", "significantly", "possible", "always", "available")
+
+// imperativeVerbs start a sentence that tells the reader what to do, as
+// in "Chase the vehicle through the neighborhood"
+var imperativeVerbs = stemSet("use", "chase", "pursue", "continue", "start", "begin", "engage", "initiate", "perform",
+	"execute", "deploy", "attempt", "proceed", "go", "drive", "speed", "follow", "conduct", "make", "set", "ignore",
+	"keep", "maintain", "request", "call", "block", "apply", "employ", "approach", "fire", "shoot", "ram", "cut")
+
+// leadingWords are skipped when looking for a sentence's first verb
+var leadingWords = map[string]bool{"then": true, "next": true, "first": true, "also": true, "just": true, "please": true, "finally": true, "and": true, "so": true}
+
+func stemSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[stem(word)] = true
+	}
+	return set
+}
+
+// policyConflicts finds answer sentences that contradict a directive in a
+// published policy document, at most one per directive
+func (g *OutputGuard) policyConflicts(config SafetyConfig, answer string) []policyConflict {
+	if g.rag == nil {
+		return nil
+	}
+	rules := policyRules(g.rag.GetAllDocuments(), config.Policy.Categories)
+	if len(rules) == 0 {
+		return nil
+	}
+
+	var conflicts []policyConflict
+	conflicted := make(map[int]bool)
+	for _, sentence := range policySentences(answer) {
+		lower := strings.ReplaceAll(strings.ToLower(sentence), "’", "'")
+		terms := make(map[string]bool)
+		for _, term := range tokenize(lower) {
+			terms[term] = true
+		}
+		// A double negative, as in "don't pursue without backup", agrees
+		negated := len(negation.FindAllString(lower, -1))%2 == 1
+		advises := adviceMarker.MatchString(lower) || imperativeVerbs[firstVerb(lower)]
+
+		for i, rule := range rules {
+			if conflicted[i] || !overlaps(rule.terms, terms, config.Policy.MinOverlap) {
+				continue
+			}
+			var conflict bool
+			switch rule.kind {
+			case directiveRequire:
+				conflict = negated
+			case directiveProhibit:
+				conflict = advises && !negated
+			case directiveRestrict:
+				conflict = advises && !negated && !anyTerm(rule.condition, terms)
+			}
+			if conflict {
+				conflicted[i] = true
+				conflicts = append(conflicts, policyConflict{rule: rule, sentence: strings.TrimSpace(sentence)})
+			}
+		}
+	}
+	return conflicts
+}
+
+// policyRules takes the directives from published documents in categories.
+// List items are directives; other sentences need a word such as "must",
+// "avoid" or "only".
+func policyRules(docs []RAGDocument, categories []string) []policyRule {
+	var rules []policyRule
+	for _, doc := range docs {
+		if doc.Status != StatusPublished || !contains(categories, doc.Category) {
+			continue
+		}
+		for _, sentence := range policySentences(doc.Content) {
+			for _, item := range listItems(sentence) {
+				lower := strings.ToLower(item.text)
+				if !item.listed && !directiveMarker.MatchString(lower) {
+					continue
+				}
+				text := strings.TrimRight(item.text, ".;: ")
+				if text == "" {
+					continue
+				}
+				rule := policyRule{documentID: doc.ID, title: doc.Title, text: strings.ToUpper(text[:1]) + text[1:] + "."}
+				lower = parenthetical.ReplaceAllString(lower, " ")
+				switch {
+				case prohibitMarker.MatchString(lower):
+					rule.kind = directiveProhibit
+					rule.terms = policyTerms(prohibitMarker.ReplaceAllString(lower, " "))
+				case onlyMarker.MatchString(lower):
+					rule.kind = directiveRestrict
+					parts := onlyMarker.Split(lower, 2)
+					rule.terms = policyTerms(parts[0])
+					if rule.condition = policyTerms(parts[1]); len(rule.condition) == 0 {
+						continue
+					}
+				default:
+					rule.terms = policyTerms(lower)
+				}
+				// One word is too little to compare answers with
+				if len(rule.terms) >= 2 {
+					rules = append(rules, rule)
+				}
+			}
+		}
+	}
+	return rules
+}
+
+// policyTerms returns the distinct stemmed words of text that say what a
+// directive is about
+func policyTerms(text string) []string {
+	var terms []string
+	for _, term := range tokenize(text) {
+		if policyFillers[term] || strings.IndexFunc(term, unicode.IsLetter) < 0 || contains(terms, term) {
+			continue
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// overlaps reports whether terms has at least two and a minOverlap share
+// of want
+func overlaps(want []string, terms map[string]bool, minOverlap float64) bool {
+	matched := 0
+	for _, term := range want {
+		if terms[term] {
+			matched++
+		}
+	}
+	return matched >= 2 && float64(matched) >= minOverlap*float64(len(want))
+}
+
+func anyTerm(want []string, terms map[string]bool) bool {
+	for _, term := range want {
+		if terms[term] {
+			return true
+		}
+	}
+	return false
+}
+
+// firstVerb returns the stem of a sentence's first word that isn't a list
+// marker or a connective like "then"
+func firstVerb(sentence string) string {
+	for _, word := range strings.FieldsFunc(sentence, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if !leadingWords[word] {
+			return stem(word)
+		}
+	}
+	return ""
+}
+
+// listItem is part of a sentence, split at list markers
+type listItem struct {
+	text   string
+	listed bool
+}
+
+// listItems splits a sentence such as "Urban pursuit protocol: 1) Maintain
+// safe distance" into the text before the list and each item
+func listItems(sentence string) []listItem {
+	var items []listItem
+	last, listed := 0, false
+	for _, loc := range listMarker.FindAllStringIndex(sentence, -1) {
+		if text := strings.TrimSpace(sentence[last:loc[0]]); text != "" {
+			items = append(items, listItem{text: text, listed: listed})
+		}
+		last, listed = loc[1], true
+	}
+	if text := strings.TrimSpace(sentence[last:]); text != "" {
+		items = append(items, listItem{text: text, listed: listed})
+	}
+	return items
+}
+
+// policySentences splits text at line breaks and sentence ends. A full
+// stop after a bare number, as in "1. Request backup", is a list marker
+// rather than an end.
+func policySentences(text string) []string {
+	var sentences []string
+	start := 0
+	add := func(end int) {
+		if s := strings.TrimSpace(text[start:end]); s != "" {
+			sentences = append(sentences, s)
+		}
+	}
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\n':
+			add(i)
+			start = i + 1
+		case (c == '.' || c == '!' || c == '?') && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\n'):
+			if c == '.' && bareNumberBefore(text[start:i]) {
+				continue
+			}
+			add(i + 1)
+			start = i + 1
+		}
+	}
+	add(len(text))
+	return sentences
+}
+
+// bareNumberBefore reports whether text ends in a number of one or two
+// digits standing alone
+func bareNumberBefore(text string) bool {
+	i := len(text)
+	for i > 0 && text[i-1] >= '0' && text[i-1] <= '9' {
+		i--
+	}
+	digits := len(text) - i
+	return digits > 0 && digits <= 2 && (i == 0 || text[i-1] == ' ' || text[i-1] == '\n')
+}
@@ -0,0 +1,235 @@
+package ai
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Kinds of personal information the scanner finds
+const (
+	PIISSN     = "ssn"
+	PIICard    = "card"
+	PIIEmail   = "email"
+	PIIPhone   = "phone"
+	PIIDOB     = "dob"
+	PIIPlate   = "plate"
+	PIIAddress = "address"
+	PIIName    = "name"
+)
+
+// PIITypes lists every kind of personal information, in the order they're
+// scanned for. Earlier kinds win when two overlap, so an SSN isn't also
+// read as a phone number.
+var PIITypes = []string{PIISSN, PIICard, PIIEmail, PIIPhone, PIIDOB, PIIPlate, PIIAddress, PIIName}
+
+// PIIFinding is personal information found in some text. Start and End are
+// byte offsets of Value.
+type PIIFinding struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// piiDetector finds one kind of personal information. When the pattern has
+// a capture group only the group is the value, so labels such as "DOB:" or
+// "plate" stay in the text. valid, if set, rejects lookalikes.
+type piiDetector struct {
+	kind    string
+	pattern *regexp.Regexp
+	valid   func(value string) bool
+}
+
+var piiDetectors = []piiDetector{
+	{PIISSN, regexp.MustCompile(`\b\d{3}[- ]\d{2}[- ]\d{4}\b`), validSSN},
+	{PIISSN, regexp.MustCompile(`(?i)\b(?:ssn|social security(?: number| no\.?| #)?)\s*(?:is|was|of|:|#)?\s*(\d{9})\b`), validSSN},
+	{PIICard, regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), validCard},
+	{PIIEmail, regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`), nil},
+	{PIIPhone, regexp.MustCompile(`(?:\+?1[ .-]?)?(?:\(\d{3}\)\s?|\b\d{3}[ .-])\d{3}[ .-]\d{4}\b`), nil},
+	{PIIPhone, regexp.MustCompile(`(?i)\b(?:phone|cell|mobile|tel|call|text)(?: number| no\.?| #)?\s*(?:is|was|at|:|#)?\s*(\d{10})\b`), nil},
+	{PIIDOB, regexp.MustCompile(`(?i)\b(?:dob|d\.o\.b\.|date of birth|born(?: on)?|birthday)\s*(?:is|was|:|of)?\s*(\d{1,2}[/.-]\d{1,2}[/.-]\d{2,4}|\d{4}-\d{2}-\d{2}|(?:jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.? \d{1,2},? \d{4})`), nil},
+	{PIIPlate, regexp.MustCompile(`(?i)\b(?:license plate|plates?|tags?|registration)(?: number| no\.?| #)?\s*(?:is|was|of|reading|reads|:|#)?\s*([a-z0-9]{1,4}[ -]?[a-z0-9]{2,4})\b`), validPlate},
+	{PIIAddress, regexp.MustCompile(`(?i)\b\d{1,6}(?: [nsew]\.?)? (?:[a-z0-9]+ ){1,3}(?:street|st|avenue|ave|road|rd|boulevard|blvd|drive|dr|lane|ln|court|ct|circle|cir|way|place|pl|parkway|pkwy|terrace|ter|highway|hwy|trail|trl)\b\.?(?:,? (?:apt|apartment|unit|suite|ste|#)\.? ?[a-z0-9-]+)?`), validAddress},
+	{PIIName, regexp.MustCompile(`(?:\b(?:[Nn]amed|[Nn]ame is|[Cc]alled|[Mm]r\.?|[Mm]rs\.?|[Mm]s\.?|[Mm]iss|[Ss]uspect|[Vv]ictim|[Ww]itness|[Cc]omplainant|[Dd]river|[Ss]ubject|[Pp]erp)\s+|\b(?:full_|first_|last_)?name"?\s*:\s*"?)([A-Z][a-z'-]+(?: [A-Z][a-z'-]+){0,2})`), validName},
+}
+
+// ScanPII finds personal information in text, in order of appearance.
+// Overlapping findings are resolved in PIITypes order. A name found by its
+// context, as in "suspect John Smith", is also found wherever else it
+// appears.
+func ScanPII(text string) []PIIFinding {
+	return scanPII(text, nil)
+}
+
+// scanPII is ScanPII that also finds known values, mapped to their type,
+// wherever they appear
+func scanPII(text string, known map[string]string) []PIIFinding {
+	var findings []PIIFinding
+	taken := func(start, end int) bool {
+		for _, f := range findings {
+			if start < f.End && f.Start < end {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, detector := range piiDetectors {
+		for _, loc := range detector.pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[0], loc[1]
+			if len(loc) > 2 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			value := text[start:end]
+			if detector.valid != nil && !detector.valid(value) {
+				continue
+			}
+			if taken(start, end) {
+				continue
+			}
+			findings = append(findings, PIIFinding{Type: detector.kind, Value: value, Start: start, End: end})
+		}
+	}
+
+	// Names need context to be found, so look for bare repeats of those
+	// already found
+	repeats := make(map[string]string, len(known))
+	for value, kind := range known {
+		repeats[value] = kind
+	}
+	for _, f := range findings {
+		if f.Type == PIIName {
+			repeats[f.Value] = f.Type
+		}
+	}
+	values := make([]string, 0, len(repeats))
+	for value := range repeats {
+		values = append(values, value)
+	}
+	// Longest first, so "John Smith" wins over "John"
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		kind := repeats[value]
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], value)
+			if i < 0 {
+				break
+			}
+			start, end := offset+i, offset+i+len(value)
+			offset = end
+			if !taken(start, end) {
+				findings = append(findings, PIIFinding{Type: kind, Value: value, Start: start, End: end})
+			}
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Start < findings[j].Start })
+	return findings
+}
+
+// validSSN rejects numbers the SSA never issues
+func validSSN(value string) bool {
+	digits := onlyDigits(value)
+	if len(digits) != 9 {
+		return false
+	}
+	area, group, serial := digits[:3], digits[3:5], digits[5:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// validCard accepts numbers passing the Luhn check, so long case or
+// report numbers aren't taken for cards
+func validCard(value string) bool {
+	digits := onlyDigits(value)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := range digits {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validPlate needs letters and digits, or enough digits for an all-number
+// plate, so "the plate was stolen" or "tag 2024" isn't a plate. A part
+// that's all letters is at most three and not a word, so "tag 2024 and"
+// isn't one either.
+func validPlate(value string) bool {
+	for _, part := range strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == '-' }) {
+		if strings.IndexFunc(part, unicode.IsDigit) < 0 && (len(part) > 3 || plateWords[strings.ToLower(part)]) {
+			return false
+		}
+	}
+	digits := len(onlyDigits(value))
+	letters := strings.IndexFunc(value, unicode.IsLetter) >= 0
+	return digits > 0 && letters || digits >= 5
+}
+
+// plateWords are short words that can follow a plate number
+var plateWords = map[string]bool{
+	"and": true, "the": true, "was": true, "for": true, "are": true, "has": true, "had": true, "not": true,
+	"but": true, "its": true, "his": true, "her": true, "who": true, "off": true, "on": true, "in": true,
+	"at": true, "to": true, "of": true, "is": true, "or": true, "a": true, "an": true, "car": true,
+}
+
+// streetTypes are the street suffixes an address ends with, mapped to
+// whether they're also everyday words, as in "5 officers drive"
+var streetTypes = map[string]bool{
+	"street": false, "st": false, "avenue": false, "ave": false, "road": false, "rd": false,
+	"boulevard": false, "blvd": false, "parkway": false, "pkwy": false, "highway": false, "hwy": false,
+	"drive": true, "dr": true, "lane": true, "ln": true, "court": true, "ct": true, "circle": true,
+	"cir": true, "way": true, "place": true, "pl": true, "terrace": true, "ter": true, "trail": true, "trl": true,
+}
+
+// validAddress needs a capitalized or numbered street name when the
+// street type is also an everyday word
+func validAddress(value string) bool {
+	fields := strings.Fields(strings.SplitN(value, ",", 2)[0])
+	for i := len(fields) - 1; i > 0; i-- {
+		ambiguous, ok := streetTypes[strings.ToLower(strings.TrimRight(fields[i], "."))]
+		if !ok {
+			continue
+		}
+		if !ambiguous {
+			return true
+		}
+		for _, word := range fields[1:i] {
+			if r := []rune(word)[0]; unicode.IsUpper(r) || unicode.IsDigit(r) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// notNames are capitalized words that follow "suspect" or "driver" without
+// being a name, as in "the driver Was speeding" or "suspect In custody"
+var notNames = map[string]bool{
+	"The": true, "A": true, "An": true, "Is": true, "Was": true, "Has": true, "Had": true, "In": true,
+	"At": true, "On": true, "And": true, "Or": true, "Of": true, "With": true, "Who": true, "That": true,
+	"This": true, "Fled": true, "Vehicle": true, "Description": true, "Unknown": true,
+}
+
+func validName(value string) bool {
+	return !notNames[strings.Fields(value)[0]]
+}
+
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
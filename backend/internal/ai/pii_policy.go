@@ -0,0 +1,307 @@
+package ai
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Built-in PII policy, copied to the PII directory on first run so it can
+// be tuned there
+//
+//go:embed pii/policy.json
+var defaultPIIPolicy []byte
+
+const piiPolicyFile = "policy.json"
+
+// What to do with personal information
+const (
+	PIIAllow = "allow"
+	// PIITokenize swaps values for placeholders such as [PHONE_1], restored
+	// in the answer. It only applies to text sent to providers.
+	PIITokenize = "tokenize"
+	PIIRedact   = "redact"
+)
+
+// Where personal information is going, each with its own actions
+const (
+	PIIUseProvider = "provider"
+	PIIUseStore    = "store"
+	PIIUseLog      = "log"
+)
+
+// PIIRolePolicy says what to do with each kind of personal information for
+// one role. Keys are PII types, with "*" for the rest; anything unlisted
+// is redacted.
+type PIIRolePolicy struct {
+	// Provider covers text sent to the LLM, embedding and web search
+	// providers
+	Provider map[string]string `json:"provider"`
+	// Store covers saved conversations
+	Store map[string]string `json:"store"`
+	// Log covers the rejected prompt log and application logs
+	Log map[string]string `json:"log"`
+}
+
+// PIIConfig is the PII policy file
+type PIIConfig struct {
+	Enabled bool `json:"enabled"`
+	// DefaultRole's policy applies to roles without one, and to logs not
+	// written during a chat
+	DefaultRole string                   `json:"default_role"`
+	Roles       map[string]PIIRolePolicy `json:"roles"`
+}
+
+// validate checks every action is one the use allows
+func (c *PIIConfig) validate() error {
+	if _, ok := c.Roles[c.DefaultRole]; !ok {
+		return fmt.Errorf("default_role %q has no policy", c.DefaultRole)
+	}
+	for role, policy := range c.Roles {
+		uses := map[string]map[string]string{PIIUseProvider: policy.Provider, PIIUseStore: policy.Store, PIIUseLog: policy.Log}
+		for use, actions := range uses {
+			for kind, action := range actions {
+				if kind != "*" && !contains(PIITypes, kind) {
+					return fmt.Errorf("%s %s: unknown PII type %q", role, use, kind)
+				}
+				switch {
+				case action == PIIAllow || action == PIIRedact:
+				case action == PIITokenize && use == PIIUseProvider:
+				default:
+					return fmt.Errorf("%s %s: %q can't be %s", role, use, kind, action)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// action returns what a role's policy does with one kind of personal
+// information for a use. The caller must hold the guard's lock.
+func (c *PIIConfig) action(role, use, kind string) string {
+	if !c.Enabled {
+		return PIIAllow
+	}
+	policy, ok := c.Roles[role]
+	if !ok {
+		policy = c.Roles[c.DefaultRole]
+	}
+	actions := policy.Log
+	switch use {
+	case PIIUseProvider:
+		actions = policy.Provider
+	case PIIUseStore:
+		actions = policy.Store
+	}
+	if action, ok := actions[kind]; ok {
+		return action
+	}
+	if action, ok := actions["*"]; ok {
+		return action
+	}
+	return PIIRedact
+}
+
+// PIIGuard applies the PII policy: tokenizing personal information before
+// it's sent to a provider and redacting it from stored conversations and
+// logs. The policy is loaded from a directory and can be reloaded while
+// serving.
+type PIIGuard struct {
+	mu       sync.RWMutex
+	config   PIIConfig
+	dataPath string
+}
+
+func NewPIIGuard(dataPath string) (*PIIGuard, error) {
+	g := &PIIGuard{dataPath: dataPath}
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		return nil, err
+	}
+	// Seed the built-in policy, leaving an edited one alone
+	policyPath := filepath.Join(dataPath, piiPolicyFile)
+	if _, err := os.Stat(policyPath); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(policyPath, defaultPIIPolicy, 0644); err != nil {
+			return nil, fmt.Errorf("failed to seed PII policy: %w", err)
+		}
+	}
+	if err := g.Reload(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Reload rereads the policy. On error the current policy stays in place.
+func (g *PIIGuard) Reload() error {
+	data, err := os.ReadFile(filepath.Join(g.dataPath, piiPolicyFile))
+	if err != nil {
+		return err
+	}
+	var config PIIConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("%s: %w", piiPolicyFile, err)
+	}
+	if err := config.validate(); err != nil {
+		return fmt.Errorf("%s: %w", piiPolicyFile, err)
+	}
+
+	g.mu.Lock()
+	g.config = config
+	g.mu.Unlock()
+	return nil
+}
+
+// Config returns the loaded policy
+func (g *PIIGuard) Config() PIIConfig {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.config
+}
+
+// actions resolves a role's action for every kind of personal information
+func (g *PIIGuard) actions(role, use string) map[string]string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	actions := make(map[string]string, len(PIITypes))
+	for _, kind := range PIITypes {
+		actions[kind] = g.config.action(role, use, kind)
+	}
+	return actions
+}
+
+// Redact replaces the personal information a role's policy doesn't allow
+// for a use with [REDACTED_TYPE]. Tokenizing needs a chat turn to restore
+// the values, so here it redacts too.
+func (g *PIIGuard) Redact(role, use, text string) string {
+	findings := ScanPII(text)
+	if len(findings) == 0 {
+		return text
+	}
+	actions := g.actions(role, use)
+	return replacePII(text, findings, func(f PIIFinding) string {
+		if actions[f.Type] == PIIAllow {
+			return f.Value
+		}
+		return redactedPII(f.Type)
+	})
+}
+
+// PIITest shows what the policy does with some text for one role
+type PIITest struct {
+	Role     string       `json:"role"`
+	Findings []PIIFinding `json:"findings"`
+	Provider string       `json:"provider"`
+	Store    string       `json:"store"`
+	Log      string       `json:"log"`
+}
+
+// Test applies a role's policy to text for every use, for trying out the
+// policy. Nothing is counted in metrics.
+func (g *PIIGuard) Test(role, text string) PIITest {
+	findings := ScanPII(text)
+	if findings == nil {
+		findings = []PIIFinding{}
+	}
+	vault := g.newVault(role)
+	vault.quiet = true
+	return PIITest{
+		Role:     role,
+		Findings: findings,
+		Provider: vault.protect(text),
+		Store:    g.Redact(role, PIIUseStore, text),
+		Log:      g.Redact(role, PIIUseLog, text),
+	}
+}
+
+func redactedPII(kind string) string {
+	return "[REDACTED_" + strings.ToUpper(kind) + "]"
+}
+
+// replacePII rebuilds text with each finding swapped for replace's result
+func replacePII(text string, findings []PIIFinding, replace func(PIIFinding) string) string {
+	var b strings.Builder
+	last := 0
+	for _, f := range findings {
+		b.WriteString(text[last:f.Start])
+		b.WriteString(replace(f))
+		last = f.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+type chatRoleKey struct{}
+
+// withChatRole attaches the chatting user's role to ctx, so logs written
+// during the turn follow that role's policy
+func withChatRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, chatRoleKey{}, role)
+}
+
+// chatRoleFrom returns the role attached by withChatRole, or "" outside a
+// chat
+func chatRoleFrom(ctx context.Context) string {
+	role, _ := ctx.Value(chatRoleKey{}).(string)
+	return role
+}
+
+// piiLogHandler redacts personal information from log messages and
+// string attributes, including errors
+type piiLogHandler struct {
+	slog.Handler
+	guard *PIIGuard
+}
+
+// LogHandler wraps h so personal information is redacted from every
+// record according to the log policy
+func (g *PIIGuard) LogHandler(h slog.Handler) slog.Handler {
+	return piiLogHandler{Handler: h, guard: g}
+}
+
+func (h piiLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	role := chatRoleFrom(ctx)
+	redacted := slog.NewRecord(r.Time, r.Level, h.guard.Redact(role, PIIUseLog, r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redactAttr(role, attr))
+		return true
+	})
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h piiLogHandler) redactAttr(role string, attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.guard.Redact(role, PIIUseLog, value.String()))
+	case slog.KindGroup:
+		attrs := value.Group()
+		redacted := make([]any, len(attrs))
+		for i, a := range attrs {
+			redacted[i] = h.redactAttr(role, a)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, h.guard.Redact(role, PIIUseLog, err.Error()))
+		}
+	}
+	return attr
+}
+
+func (h piiLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redactAttr("", attr)
+	}
+	return piiLogHandler{Handler: h.Handler.WithAttrs(redacted), guard: h.guard}
+}
+
+func (h piiLogHandler) WithGroup(name string) slog.Handler {
+	return piiLogHandler{Handler: h.Handler.WithGroup(name), guard: h.guard}
+}
@@ -0,0 +1,169 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"serpico/backend/internal/metrics"
+)
+
+// piiPrompt is added to the system prompt when a turn's text had personal
+// information swapped out, so the model repeats placeholders rather than
+// guessing at them
+const piiPrompt = `Personal information has been replaced with placeholders such as [NAME_1], [PHONE_2] or [REDACTED_SSN]. Use the placeholders exactly as written when you refer to that information; never guess the values behind them.`
+
+// piiToken matches the placeholders a vault hands out
+var piiToken = regexp.MustCompile(`\[(?:SSN|CARD|EMAIL|PHONE|DOB|PLATE|ADDRESS|NAME)_\d+\]`)
+
+// piiPartialToken matches the start of a placeholder split across stream
+// chunks
+var piiPartialToken = regexp.MustCompile(`^\[[A-Z]*(?:_\d*)?$`)
+
+// maxPIIToken is the longest placeholder a stream holds back for
+const maxPIIToken = len("[ADDRESS_9999]")
+
+// piiVault protects one chat turn's text before it goes to a provider,
+// swapping personal information for placeholders under the role's policy,
+// and restores the placeholders in the answer. The same value gets the
+// same placeholder all turn, so the model can tell people apart.
+type piiVault struct {
+	actions map[string]string
+	// tokens maps placeholders to values and values to placeholders
+	tokens map[string]string
+	values map[string]string
+	counts map[string]int
+	// known maps values found earlier in the turn to their type, so
+	// they're caught again without the context that found them
+	known map[string]string
+	// used is set once anything has been tokenized or redacted
+	used bool
+	// quiet leaves findings out of metrics, for trying out the policy
+	quiet bool
+}
+
+func (g *PIIGuard) newVault(role string) *piiVault {
+	return &piiVault{
+		actions: g.actions(role, PIIUseProvider),
+		tokens:  make(map[string]string),
+		values:  make(map[string]string),
+		counts:  make(map[string]int),
+		known:   make(map[string]string),
+	}
+}
+
+// protect swaps the personal information in text for placeholders or
+// redactions
+func (v *piiVault) protect(text string) string {
+	findings := scanPII(text, v.known)
+	if len(findings) == 0 {
+		return text
+	}
+	return replacePII(text, findings, func(f PIIFinding) string {
+		v.known[f.Value] = f.Type
+		action := v.actions[f.Type]
+		if !v.quiet {
+			metrics.ObservePII(f.Type, action)
+		}
+		switch action {
+		case PIIAllow:
+			return f.Value
+		case PIITokenize:
+			v.used = true
+			return v.tokenFor(f)
+		default:
+			v.used = true
+			return redactedPII(f.Type)
+		}
+	})
+}
+
+func (v *piiVault) tokenFor(f PIIFinding) string {
+	key := f.Type + "\x00" + f.Value
+	if token, ok := v.values[key]; ok {
+		return token
+	}
+	v.counts[f.Type]++
+	token := fmt.Sprintf("[%s_%d]", strings.ToUpper(f.Type), v.counts[f.Type])
+	v.values[key] = token
+	v.tokens[token] = f.Value
+	return token
+}
+
+// protectMessages protects the content of each message, leaving the
+// originals alone
+func (v *piiVault) protectMessages(messages []Message) []Message {
+	protected := make([]Message, len(messages))
+	for i, message := range messages {
+		message.Content = v.protect(message.Content)
+		protected[i] = message
+	}
+	return protected
+}
+
+// restore puts the values back in place of this turn's placeholders.
+// Placeholders the vault didn't hand out are left as they are.
+func (v *piiVault) restore(text string) string {
+	if len(v.tokens) == 0 {
+		return text
+	}
+	return piiToken.ReplaceAllStringFunc(text, func(token string) string {
+		if value, ok := v.tokens[token]; ok {
+			return value
+		}
+		return token
+	})
+}
+
+// restoreArguments restores placeholders in tool call arguments, so a
+// lookup runs on the real value. Values are JSON-escaped as they land
+// inside strings.
+func (v *piiVault) restoreArguments(args json.RawMessage) json.RawMessage {
+	if len(v.tokens) == 0 {
+		return args
+	}
+	return json.RawMessage(piiToken.ReplaceAllStringFunc(string(args), func(token string) string {
+		value, ok := v.tokens[token]
+		if !ok {
+			return token
+		}
+		quoted, _ := json.Marshal(value)
+		return string(quoted[1 : len(quoted)-1])
+	}))
+}
+
+// piiRestorer restores placeholders in a streamed answer. A chunk ending
+// partway through a placeholder is held back until the rest arrives.
+type piiRestorer struct {
+	vault   *piiVault
+	onChunk StreamFunc
+	pending string
+}
+
+func (v *piiVault) restorer(onChunk StreamFunc) *piiRestorer {
+	return &piiRestorer{vault: v, onChunk: onChunk}
+}
+
+func (r *piiRestorer) write(chunk string) error {
+	text := r.pending + chunk
+	cut := len(text)
+	if i := strings.LastIndexByte(text, '['); i >= 0 && len(text)-i < maxPIIToken && piiPartialToken.MatchString(text[i:]) {
+		cut = i
+	}
+	r.pending = text[cut:]
+	if cut == 0 {
+		return nil
+	}
+	return r.onChunk(r.vault.restore(text[:cut]))
+}
+
+// flush sends anything held back, once the answer is complete
+func (r *piiRestorer) flush() error {
+	if r.pending == "" {
+		return nil
+	}
+	text := r.pending
+	r.pending = ""
+	return r.onChunk(r.vault.restore(text))
+}
@@ -0,0 +1,146 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Message roles shared by all providers. Providers translate them to their
+// own wire format (e.g. Gemini calls the assistant "model").
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message is one turn of a conversation
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ToolCalls are the functions an assistant turn asked to run
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID and Name identify the call a tool turn answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Name       string `json:"name,omitempty"`
+}
+
+// ToolCall is a model's request to run a function
+type ToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ToolSpec declares a function the model may call. Parameters is a JSON
+// schema object.
+type ToolSpec struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+}
+
+// GenerateRequest is a provider-neutral generation request
+type GenerateRequest struct {
+	SystemPrompt string
+	Messages     []Message
+	Tools        []ToolSpec
+}
+
+// Usage reports token counts for a generation
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// GenerateResponse is a provider-neutral generation result
+type GenerateResponse struct {
+	Text      string     `json:"text"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Provider  string     `json:"provider"`
+	Model     string     `json:"model"`
+	Usage     Usage      `json:"usage"`
+}
+
+// LLMProvider is a chat model backend
+type LLMProvider interface {
+	// Name identifies the provider in logs and metrics
+	Name() string
+	Generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error)
+	// Ping checks the provider is reachable without generating anything
+	Ping(ctx context.Context) error
+}
+
+// FallbackProvider tries each provider in order until one succeeds
+type FallbackProvider struct {
+	providers []LLMProvider
+}
+
+func NewFallbackProvider(providers ...LLMProvider) *FallbackProvider {
+	return &FallbackProvider{providers: providers}
+}
+
+func (f *FallbackProvider) Name() string {
+	names := make([]string, len(f.providers))
+	for i, p := range f.providers {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (f *FallbackProvider) Generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
+	var errs []error
+	for _, p := range f.providers {
+		resp, err := p.Generate(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		slog.WarnContext(ctx, "LLM provider failed, trying next", "provider", p.Name(), "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+
+		// Don't keep trying once the caller has gone away
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, fmt.Errorf("all LLM providers failed: %w", errors.Join(errs...))
+}
+
+// Ping succeeds if any provider in the chain is reachable
+func (f *FallbackProvider) Ping(ctx context.Context) error {
+	var errs []error
+	for _, p := range f.providers {
+		err := p.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+	}
+	return errors.Join(errs...)
+}
+
+// NewProvider builds the provider chain named in config.LLMProviders
+func NewProvider(config *Config) (LLMProvider, error) {
+	var providers []LLMProvider
+	for _, name := range config.LLMProviders {
+		switch name {
+		case "gemini":
+			providers = append(providers, NewGeminiClient(config.GeminiAPIKey, config.GeminiModel))
+		case "openai":
+			providers = append(providers, NewOpenAIClient(config.OpenAIBaseURL, config.OpenAIAPIKey, config.OpenAIModel))
+		default:
+			return nil, fmt.Errorf("unknown LLM provider %q", name)
+		}
+	}
+
+	if len(providers) == 0 {
+		return nil, errors.New("no LLM providers configured")
+	}
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+	return NewFallbackProvider(providers...), nil
+}
@@ -1,33 +1,109 @@
 package ai
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"serpico/backend/internal/metrics"
 )
 
 // RAGDocument represents a document in the RAG database
 type RAGDocument struct {
-	ID          string   `json:"id"`
-	Title       string   `json:"title"`
-	Content     string   `json:"content"`
-	Category    string   `json:"category"`
-	Location    string   `json:"location,omitempty"`
-	Tags        []string `json:"tags"`
-	Embedding   []float64 `json:"-"` // Not stored, computed on demand
+	ID       string   `json:"id"`
+	Title    string   `json:"title"`
+	Content  string   `json:"content"`
+	Category string   `json:"category"`
+	Location string   `json:"location,omitempty"`
+	Tags     []string `json:"tags"`
+	// ContentHash is set by the store and used to spot duplicate uploads
+	ContentHash string `json:"content_hash,omitempty"`
+	// Source is set for documents extracted from an uploaded file
+	Source *DocumentSource `json:"source,omitempty"`
+	// Record is set for documents generated from a database row
+	Record *RecordRef `json:"record,omitempty"`
+	// Status is where the document is in review; only published documents
+	// are retrieved
+	Status string `json:"status"`
+	// Review is the latest reviewer decision
+	Review *DocumentReview `json:"review,omitempty"`
+	// EffectiveFrom and ExpiresAt bound when the document is retrieved
+	EffectiveFrom *time.Time `json:"effective_from,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	// PublishedAt is when the document was last approved, or for a
+	// database record when the record dates from
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+var (
+	// ErrDocumentNotFound is returned for a document ID that isn't in the store
+	ErrDocumentNotFound = errors.New("document not found")
+	// ErrRecordDocument is returned when editing or deleting a document
+	// generated from a database record, which the next sync would undo
+	ErrRecordDocument = errors.New("document is generated from a database record; change the record instead")
+)
+
+// clone returns a copy of doc that shares no memory with the store
+func (d RAGDocument) clone() RAGDocument {
+	if d.Tags != nil {
+		d.Tags = append(make([]string, 0, len(d.Tags)), d.Tags...)
+	}
+	if d.Source != nil {
+		source := *d.Source
+		d.Source = &source
+	}
+	if d.Record != nil {
+		record := *d.Record
+		d.Record = &record
+	}
+	if d.Review != nil {
+		review := *d.Review
+		d.Review = &review
+	}
+	d.EffectiveFrom = cloneTime(d.EffectiveFrom)
+	d.ExpiresAt = cloneTime(d.ExpiresAt)
+	d.PublishedAt = cloneTime(d.PublishedAt)
+	return d
 }
 
-// RAGDatabase manages the RAG document store
+// RAGDatabase manages the RAG document store. It's safe for concurrent
+// use: reads share a lock, writes hold it exclusively, and documents are
+// returned as copies so callers can't change the store behind its back.
 type RAGDatabase struct {
+	mu        sync.RWMutex
 	documents []RAGDocument
 	dataPath  string
+	// chunks holds each document's chunks by document ID. They're rebuilt
+	// from the documents on load rather than stored.
+	chunks    map[string][]RAGChunk
+	chunking  ChunkConfig
+	freshness FreshnessConfig
+	// history holds every version of each document by ID, including
+	// deleted ones, and pending the versions of a change being committed
+	history map[string][]DocumentVersion
+	pending []DocumentVersion
+	index   *bm25Index
+	// embedder is nil when only keyword search is used
+	embedder Embedder
 }
 
-func NewRAGDatabase(dataPath string) (*RAGDatabase, error) {
+func NewRAGDatabase(dataPath string, chunking ChunkConfig, freshness FreshnessConfig, embedder Embedder) (*RAGDatabase, error) {
 	db := &RAGDatabase{
 		documents: []RAGDocument{},
 		dataPath:  dataPath,
+		chunks:    make(map[string][]RAGChunk),
+		chunking:  chunking,
+		freshness: freshness,
+		history:   make(map[string][]DocumentVersion),
+		index:     newBM25Index(),
+		embedder:  embedder,
 	}
 
 	// Create directory if it doesn't exist
@@ -39,13 +115,37 @@ func NewRAGDatabase(dataPath string) (*RAGDatabase, error) {
 	if err := db.loadDocuments(); err != nil {
 		return nil, err
 	}
+	for i, doc := range db.documents {
+		if doc.ContentHash == "" {
+			db.documents[i].ContentHash = ContentHash(doc.Content)
+		}
+		// Documents from before the review workflow were already live
+		if doc.Status == "" {
+			db.documents[i].Status = StatusPublished
+		}
+		db.setChunks(doc, chunkDocument(doc, chunking))
+	}
+
+	if err := db.loadHistory(); err != nil {
+		return nil, err
+	}
+	if err := db.baselineHistory(); err != nil {
+		return nil, err
+	}
+
+	if embedder != nil {
+		if err := db.loadEmbeddings(); err != nil {
+			return nil, err
+		}
+		db.backfillEmbeddings()
+	}
 
 	return db, nil
 }
 
 func (r *RAGDatabase) loadDocuments() error {
 	filePath := filepath.Join(r.dataPath, "documents.json")
-	
+
 	// If file doesn't exist, initialize with seed data
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
 		return r.seedDocuments()
@@ -70,7 +170,7 @@ func (r *RAGDatabase) saveDocuments() error {
 		return err
 	}
 
-	return os.WriteFile(filePath, data, 0644)
+	return writeFileAtomic(filePath, data)
 }
 
 func (r *RAGDatabase) seedDocuments() error {
@@ -160,124 +260,440 @@ func (r *RAGDatabase) seedDocuments() error {
 		},
 	}
 
+	// The seed material is written from 2023 figures; dating it then
+	// rather than at install time lets it age like any other document
+	published := time.Date(2024, time.January, 15, 0, 0, 0, 0, time.UTC)
+	for i := range r.documents {
+		r.documents[i].Status = StatusPublished
+		r.documents[i].PublishedAt = &published
+	}
+
 	return r.saveDocuments()
 }
 
-// Search finds relevant documents based on query
-func (r *RAGDatabase) Search(query string, limit int) []RAGDocument {
+// Ranking fuses BM25 and vector rankings with reciprocal rank fusion:
+// each list contributes 1/(rrfK + rank) per document. Vector matches below
+// minSimilarity are left out so unrelated documents aren't returned.
+const (
+	rrfK          = 60
+	minSimilarity = 0.3
+)
+
+// SearchResult is a retrieved chunk with its document and relevance score
+type SearchResult struct {
+	Document RAGDocument `json:"document"`
+	Chunk    RAGChunk    `json:"chunk"`
+	Score    float64     `json:"score"`
+}
+
+// SearchFilter narrows a search by document metadata before ranking.
+// Empty fields match every document.
+type SearchFilter struct {
+	// Categories matches documents in any of the categories
+	Categories []string
+	// Location matches documents whose location contains it, ignoring case
+	Location string
+	// Tags matches documents with any of the tags
+	Tags []string
+	// Statuses matches documents in any of the review statuses. Empty
+	// means published only, as chat sees them.
+	Statuses []string
+	// Role leaves out the record documents it can't read. Empty means
+	// every document, for callers inside the service.
+	Role string
+}
+
+func (f SearchFilter) matches(doc RAGDocument) bool {
+	if len(f.Statuses) == 0 && doc.Status != StatusPublished ||
+		len(f.Statuses) > 0 && !contains(f.Statuses, doc.Status) {
+		return false
+	}
+	if f.Role != "" && !doc.VisibleTo(f.Role) {
+		return false
+	}
+	if len(f.Categories) > 0 && !contains(f.Categories, doc.Category) {
+		return false
+	}
+	if f.Location != "" && !strings.Contains(strings.ToLower(doc.Location), strings.ToLower(f.Location)) {
+		return false
+	}
+	if len(f.Tags) > 0 {
+		for _, tag := range doc.Tags {
+			for _, want := range f.Tags {
+				if strings.EqualFold(tag, want) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Search finds relevant documents based on query, best first
+func (r *RAGDatabase) Search(ctx context.Context, query string, limit int) []RAGDocument {
 	if limit <= 0 {
 		limit = 5
 	}
-
-	queryLower := strings.ToLower(query)
+	// Several chunks of one document can match; fetch extra so limit
+	// distinct documents remain
+	scored := r.SearchScored(ctx, query, limit*4, SearchFilter{})
+	seen := make(map[string]bool)
 	results := []RAGDocument{}
-	scores := make(map[int]float64)
+	for _, result := range scored {
+		if seen[result.Document.ID] {
+			continue
+		}
+		seen[result.Document.ID] = true
+		results = append(results, result.Document)
+		if len(results) == limit {
+			break
+		}
+	}
+	return results
+}
 
-	for i, doc := range r.documents {
-		score := 0.0
+// SearchScored ranks the chunks of documents matching filter against
+// query. Chunks are ranked by BM25 and, with an embedder, by cosine
+// similarity, and the rankings are fused. If the query can't be embedded
+// only BM25 is used. Documents outside their effective dates are left out,
+// and with a recency weight the matched chunks are also ranked by age, so
+// recency reorders close results without outweighing relevance.
+func (r *RAGDatabase) SearchScored(ctx context.Context, query string, limit int, filter SearchFilter) []SearchResult {
+	return r.searchScored(ctx, query, query, limit, filter)
+}
 
-		// Simple keyword matching (in production, use proper vector embeddings)
-		contentLower := strings.ToLower(doc.Content)
-		titleLower := strings.ToLower(doc.Title)
+// searchScored is SearchScored with embedQuery sent to the embedder in
+// place of query, so chat can keep personal information from an embedding
+// provider while BM25 still matches the words typed
+func (r *RAGDatabase) searchScored(ctx context.Context, query, embedQuery string, limit int, filter SearchFilter) []SearchResult {
+	if limit <= 0 {
+		limit = 5
+	}
 
-		// Check title matches
-		if strings.Contains(titleLower, queryLower) {
-			score += 3.0
+	// Embed before locking so a slow provider doesn't hold up writers
+	var queryVector []float64
+	if r.embedder != nil {
+		vectors, err := r.embedder.Embed(ctx, []string{embedQuery}, EmbedQuery)
+		if err != nil {
+			slog.WarnContext(ctx, "query embedding failed, using keyword search only", "error", err)
+		} else {
+			queryVector = vectors[0]
 		}
+	}
 
-		// Check content matches
-		queryWords := strings.Fields(queryLower)
-		for _, word := range queryWords {
-			if strings.Contains(contentLower, word) {
-				score += 1.0
-			}
-		}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-		// Check tag matches
-		for _, tag := range doc.Tags {
-			if strings.Contains(queryLower, strings.ToLower(tag)) {
-				score += 2.0
+	now := time.Now()
+	candidates := make(map[string]bool)
+	for _, doc := range r.documents {
+		if filter.matches(doc) && doc.active(now) {
+			for _, chunk := range r.chunks[doc.ID] {
+				candidates[chunk.ID] = true
 			}
 		}
+	}
 
-		// Check location matches
-		if strings.Contains(queryLower, "olathe") && strings.Contains(strings.ToLower(doc.Location), "olathe") {
-			score += 1.5
-		}
-
-		if score > 0 {
-			scores[i] = score
+	fused := make(map[string]float64)
+	lexical := r.index.Score(query, candidates)
+	addRanking(fused, lexical, 1)
+
+	if queryVector != nil {
+		semantic := make(map[string]float64)
+		for _, doc := range r.documents {
+			for _, chunk := range r.chunks[doc.ID] {
+				if !candidates[chunk.ID] || chunk.Embedding == nil {
+					continue
+				}
+				if similarity := cosine(queryVector, chunk.Embedding); similarity >= minSimilarity {
+					semantic[chunk.ID] = similarity
+				}
+			}
 		}
+		addRanking(fused, semantic, 1)
 	}
 
-	// Sort by score (simple implementation)
-	type scoredDoc struct {
-		doc   RAGDocument
-		score float64
-	}
-	scoredDocs := []scoredDoc{}
-	for i, score := range scores {
-		scoredDocs = append(scoredDocs, scoredDoc{r.documents[i], score})
+	// Only chunks that matched are ranked by age, so a new document can't
+	// be retrieved for being new
+	if r.freshness.RecencyWeight > 0 && r.freshness.HalfLife > 0 {
+		recent := make(map[string]float64, len(fused))
+		for _, doc := range r.documents {
+			for _, chunk := range r.chunks[doc.ID] {
+				if _, ok := fused[chunk.ID]; ok {
+					recent[chunk.ID] = r.freshness.recency(doc, now)
+				}
+			}
+		}
+		addRanking(fused, recent, r.freshness.RecencyWeight)
 	}
 
-	// Simple sort (bubble sort for small datasets)
-	for i := 0; i < len(scoredDocs)-1; i++ {
-		for j := i + 1; j < len(scoredDocs); j++ {
-			if scoredDocs[i].score < scoredDocs[j].score {
-				scoredDocs[i], scoredDocs[j] = scoredDocs[j], scoredDocs[i]
+	results := []SearchResult{}
+	for _, doc := range r.documents {
+		for _, chunk := range r.chunks[doc.ID] {
+			if score, ok := fused[chunk.ID]; ok {
+				results = append(results, SearchResult{Document: doc.clone(), Chunk: chunk, Score: score})
 			}
 		}
 	}
-
-	// Return top results
-	for i := 0; i < limit && i < len(scoredDocs); i++ {
-		results = append(results, scoredDocs[i].doc)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
 	}
 
+	metrics.ObserveRAGSearch(len(results))
+
 	return results
 }
 
-// AddDocument adds a new document to the RAG database
-func (r *RAGDatabase) AddDocument(doc RAGDocument) error {
-	r.documents = append(r.documents, doc)
-	return r.saveDocuments()
+// addRanking adds the reciprocal rank of each entry in scores to fused,
+// scaled by weight
+func addRanking(fused map[string]float64, scores map[string]float64, weight float64) {
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	for rank, id := range ids {
+		fused[id] += weight / float64(rrfK+rank+1)
+	}
+}
+
+// AddDocument chunks, embeds and adds a new document to the RAG database,
+// returning it as stored. It starts as a draft, or pending review if
+// doc.Status asks for it.
+func (r *RAGDatabase) AddDocument(ctx context.Context, doc RAGDocument) (RAGDocument, error) {
+	doc.ContentHash = ContentHash(doc.Content)
+	doc.Status = editedStatus(doc.Status)
+	chunks := r.prepareChunks(ctx, doc, nil)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.commit(func() {
+		r.documents = append(r.documents, doc)
+		r.setChunks(doc, chunks)
+		r.recordVersion(ctx, VersionCreated, doc)
+	})
+	return doc.clone(), err
 }
 
 // GetAllDocuments returns all documents
 func (r *RAGDatabase) GetAllDocuments() []RAGDocument {
-	return r.documents
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	documents := make([]RAGDocument, len(r.documents))
+	for i, doc := range r.documents {
+		documents[i] = doc.clone()
+	}
+	return documents
+}
+
+// Count returns the number of documents in the store
+func (r *RAGDatabase) Count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.documents)
 }
 
-// GetDocumentByID returns a document by ID
+// GetDocumentByID returns a copy of a document, or nil if there's none
+// with that ID
 func (r *RAGDatabase) GetDocumentByID(id string) *RAGDocument {
-	for i := range r.documents {
-		if r.documents[i].ID == id {
-			return &r.documents[i]
-		}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i := r.indexOf(id)
+	if i < 0 {
+		return nil
 	}
-	return nil
+	doc := r.documents[i].clone()
+	return &doc
 }
 
-// UpdateDocument updates an existing document
-func (r *RAGDatabase) UpdateDocument(id string, doc RAGDocument) error {
+// GetChunks returns a document's chunks in order
+func (r *RAGDatabase) GetChunks(id string) []RAGChunk {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]RAGChunk(nil), r.chunks[id]...)
+}
+
+// indexOf returns the position of a document in r.documents, or -1. The
+// caller must hold r.mu.
+func (r *RAGDatabase) indexOf(id string) int {
 	for i := range r.documents {
 		if r.documents[i].ID == id {
-			r.documents[i] = doc
-			r.documents[i].ID = id // Ensure ID doesn't change
-			return r.saveDocuments()
+			return i
 		}
 	}
-	return nil // Document not found
+	return -1
 }
 
-// DeleteDocument deletes a document by ID
-func (r *RAGDatabase) DeleteDocument(id string) error {
-	for i, doc := range r.documents {
-		if doc.ID == id {
-			r.documents = append(r.documents[:i], r.documents[i+1:]...)
-			return r.saveDocuments()
+// prepareChunks chunks and embeds doc, reusing vectors from previous. It
+// runs without the lock, as embedding may call out to a provider.
+func (r *RAGDatabase) prepareChunks(ctx context.Context, doc RAGDocument, previous []RAGChunk) []RAGChunk {
+	chunks := chunkDocument(doc, r.chunking)
+	r.embedChunks(ctx, doc, chunks, previous)
+	return chunks
+}
+
+// setChunks replaces doc's chunks in the store and the keyword index. The
+// caller must hold r.mu.
+func (r *RAGDatabase) setChunks(doc RAGDocument, chunks []RAGChunk) {
+	for _, chunk := range r.chunks[doc.ID] {
+		r.index.Remove(chunk.ID)
+	}
+	if chunks == nil {
+		delete(r.chunks, doc.ID)
+		return
+	}
+	for _, chunk := range chunks {
+		r.index.Add(chunk.ID, indexText(doc, chunk))
+	}
+	r.chunks[doc.ID] = chunks
+}
+
+// commit applies change, saves documents.json and appends the versions
+// change recorded to the history. If either write fails the change is
+// rolled back, so the store never serves documents that aren't on disk or
+// changes that aren't logged. The caller must hold r.mu for writing.
+func (r *RAGDatabase) commit(change func()) error {
+	documents := append([]RAGDocument(nil), r.documents...)
+	chunks := make(map[string][]RAGChunk, len(r.chunks))
+	for id, docChunks := range r.chunks {
+		chunks[id] = docChunks
+	}
+	rollback := func() {
+		r.documents = documents
+		r.chunks = chunks
+		r.index = newBM25Index()
+		for _, doc := range r.documents {
+			for _, chunk := range r.chunks[doc.ID] {
+				r.index.Add(chunk.ID, indexText(doc, chunk))
+			}
 		}
 	}
-	return nil // Document not found
+
+	change()
+
+	if err := r.saveDocuments(); err != nil {
+		r.pending = nil
+		rollback()
+		return err
+	}
+	if err := r.flushHistory(); err != nil {
+		rollback()
+		if saveErr := r.saveDocuments(); saveErr != nil {
+			slog.Error("failed to restore RAG documents after history write failed", "error", saveErr)
+		}
+		return err
+	}
+
+	// Vectors are recomputed at startup when missing, so losing them
+	// doesn't undo the change
+	if err := r.saveEmbeddings(); err != nil {
+		slog.Warn("failed to save RAG embeddings", "error", err)
+	}
+	return nil
 }
 
+// UpdateDocument updates and re-chunks an existing document, returning it
+// as stored. Chunks whose text is unchanged keep their embeddings. The
+// edit goes back through review like a new document, so a published
+// document stops being retrieved until it's approved again.
+func (r *RAGDatabase) UpdateDocument(ctx context.Context, id string, doc RAGDocument) (RAGDocument, error) {
+	r.mu.RLock()
+	i := r.indexOf(id)
+	var existing RAGDocument
+	var previous []RAGChunk
+	if i >= 0 {
+		existing = r.documents[i]
+		previous = append(previous, r.chunks[id]...)
+	}
+	r.mu.RUnlock()
+	if i < 0 {
+		return RAGDocument{}, ErrDocumentNotFound
+	}
+	if existing.Record != nil {
+		return RAGDocument{}, ErrRecordDocument
+	}
+
+	doc.ID = id // Ensure ID doesn't change
+	doc.ContentHash = ContentHash(doc.Content)
+	doc.Status = editedStatus(doc.Status)
+	doc.Review = existing.Review
+	doc.PublishedAt = existing.PublishedAt
+	if doc.Source == nil {
+		doc.Source = existing.Source
+	}
+	chunks := r.prepareChunks(ctx, doc, previous)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// The document may have been deleted while it was being embedded
+	if i = r.indexOf(id); i < 0 {
+		return RAGDocument{}, ErrDocumentNotFound
+	}
+	err := r.commit(func() {
+		r.documents[i] = doc
+		r.setChunks(doc, chunks)
+		r.recordVersion(ctx, VersionUpdated, doc)
+	})
+	return doc.clone(), err
+}
+
+// DeleteDocument deletes a document by ID. Its history is kept, so it can
+// be rolled back.
+func (r *RAGDatabase) DeleteDocument(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(id)
+	if i < 0 {
+		return ErrDocumentNotFound
+	}
+	doc := r.documents[i]
+	if doc.Record != nil {
+		return ErrRecordDocument
+	}
+	err := r.commit(func() {
+		r.documents = append(r.documents[:i:i], r.documents[i+1:]...)
+		r.setChunks(doc, nil)
+		r.recordVersion(ctx, VersionDeleted, doc)
+	})
+	if err != nil {
+		return err
+	}
+	r.removeOriginal(doc.Source)
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file beside path and renames
+// it into place, so a crash mid-write leaves the old file intact
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
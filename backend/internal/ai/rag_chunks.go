@@ -0,0 +1,247 @@
+package ai
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChunkConfig controls how documents are split for retrieval. Sizes are in
+// estimated tokens.
+type ChunkConfig struct {
+	Tokens  int
+	Overlap int
+}
+
+// RAGChunk is a retrievable piece of a document
+type RAGChunk struct {
+	ID         string `json:"id"`
+	DocumentID string `json:"document_id"`
+	// Index is the chunk's position in the document, from 0
+	Index     int       `json:"index"`
+	Text      string    `json:"text"`
+	Embedding []float64 `json:"-"`
+}
+
+// Retrieval for chat fetches ragSearchChunks chunks and packs them into at
+// most maxContextDocs documents
+const (
+	ragSearchChunks = 20
+	maxContextDocs  = 5
+)
+
+// contextDoc is a document with the chunks of it retrieved for a question
+type contextDoc struct {
+	Document RAGDocument
+	// Chunks are in document order
+	Chunks []RAGChunk
+	// Score is the best score among the chunks
+	Score float64
+}
+
+// packContext groups ranked chunks by document, best first, taking chunks
+// until budget tokens are used. A chunk that doesn't fit is skipped so a
+// smaller, lower-ranked one can still be used. The top chunk is always
+// included, cut to the budget if needed.
+func packContext(results []SearchResult, budget int) []contextDoc {
+	var docs []contextDoc
+	byID := make(map[string]int)
+	used := 0
+	for _, result := range results {
+		chunk := result.Chunk
+		cost := estimateTokens(chunk.Text)
+
+		i, ok := byID[result.Document.ID]
+		if !ok {
+			if len(docs) == maxContextDocs {
+				continue
+			}
+			doc := result.Document
+			// Each document's title and metadata are sent once
+			cost += estimateTokens(doc.Title + doc.Category + doc.Location)
+		}
+
+		if used+cost > budget {
+			if len(docs) > 0 {
+				continue
+			}
+			chunk.Text = truncateTokens(chunk.Text, budget-(cost-estimateTokens(chunk.Text)))
+			cost = budget
+		}
+		used += cost
+
+		if !ok {
+			i = len(docs)
+			byID[result.Document.ID] = i
+			docs = append(docs, contextDoc{Document: result.Document, Score: result.Score})
+		}
+		docs[i].Chunks = append(docs[i].Chunks, chunk)
+	}
+
+	for _, doc := range docs {
+		sort.Slice(doc.Chunks, func(a, b int) bool { return doc.Chunks[a].Index < doc.Chunks[b].Index })
+	}
+	return docs
+}
+
+// joinChunks reassembles chunks in document order. Adjacent chunks lose
+// the overlap they share; gaps between non-adjacent chunks are marked.
+func joinChunks(chunks []RAGChunk) string {
+	var b strings.Builder
+	for i, chunk := range chunks {
+		text := chunk.Text
+		if i > 0 {
+			if chunk.Index == chunks[i-1].Index+1 {
+				text = trimOverlap(chunks[i-1].Text, text)
+				b.WriteString(" ")
+			} else {
+				b.WriteString(" ... ")
+			}
+		}
+		b.WriteString(text)
+	}
+	return b.String()
+}
+
+// trimOverlap drops the start of next that repeats the end of prev
+func trimOverlap(prev, next string) string {
+	for i := 0; i < len(prev); i++ {
+		if prev[i] != ' ' {
+			continue
+		}
+		suffix := prev[i+1:]
+		if strings.HasPrefix(next, suffix) && (len(next) == len(suffix) || next[len(suffix)] == ' ') {
+			return strings.TrimSpace(next[len(suffix):])
+		}
+	}
+	return next
+}
+
+// truncateTokens cuts text to about tokens tokens at a word boundary
+func truncateTokens(text string, tokens int) string {
+	limit := tokens * 4
+	if limit <= 0 {
+		return ""
+	}
+	if len(text) <= limit {
+		return text
+	}
+	if cut := strings.LastIndex(text[:limit], " "); cut > 0 {
+		limit = cut
+	}
+	return text[:limit] + "..."
+}
+
+// chunkDocument splits doc's content into overlapping chunks. Documents
+// that fit in one chunk are kept whole.
+func chunkDocument(doc RAGDocument, config ChunkConfig) []RAGChunk {
+	texts := chunkText(doc.Content, config)
+	chunks := make([]RAGChunk, len(texts))
+	for i, text := range texts {
+		chunks[i] = RAGChunk{
+			ID:         fmt.Sprintf("%s#%d", doc.ID, i),
+			DocumentID: doc.ID,
+			Index:      i,
+			Text:       text,
+		}
+	}
+	return chunks
+}
+
+// chunkText packs paragraphs, then sentences, then words into chunks of at
+// most config.Tokens, starting each chunk with the last config.Overlap
+// tokens of the one before so facts spanning a boundary aren't lost
+func chunkText(text string, config ChunkConfig) []string {
+	text = strings.TrimSpace(text)
+	if config.Tokens <= 0 || estimateTokens(text) <= config.Tokens {
+		return []string{text}
+	}
+	overlap := config.Overlap
+	if overlap >= config.Tokens/2 {
+		overlap = config.Tokens / 2
+	}
+
+	var chunks []string
+	var current []string
+	size := 0
+	for _, unit := range splitUnits(text, config.Tokens) {
+		cost := estimateTokens(unit)
+		if size+cost > config.Tokens && len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, " "))
+
+			// Carry trailing units into the next chunk as overlap
+			var carried []string
+			carriedSize := 0
+			for i := len(current) - 1; i >= 0; i-- {
+				unitSize := estimateTokens(current[i])
+				if carriedSize+unitSize > overlap {
+					break
+				}
+				carried = append([]string{current[i]}, carried...)
+				carriedSize += unitSize
+			}
+			current, size = carried, carriedSize
+		}
+		current = append(current, unit)
+		size += cost
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, strings.Join(current, " "))
+	}
+	return chunks
+}
+
+// splitUnits breaks text into paragraphs, splitting any paragraph over
+// maxTokens into sentences and any sentence still over it into words
+func splitUnits(text string, maxTokens int) []string {
+	var units []string
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		paragraph = strings.Join(strings.Fields(paragraph), " ")
+		if paragraph == "" {
+			continue
+		}
+		if estimateTokens(paragraph) <= maxTokens {
+			units = append(units, paragraph)
+			continue
+		}
+
+		for _, sentence := range splitSentences(paragraph) {
+			if estimateTokens(sentence) <= maxTokens {
+				units = append(units, sentence)
+				continue
+			}
+			words := strings.Fields(sentence)
+			var piece []string
+			for _, word := range words {
+				if estimateTokens(strings.Join(append(piece, word), " ")) > maxTokens && len(piece) > 0 {
+					units = append(units, strings.Join(piece, " "))
+					piece = nil
+				}
+				piece = append(piece, word)
+			}
+			if len(piece) > 0 {
+				units = append(units, strings.Join(piece, " "))
+			}
+		}
+	}
+	return units
+}
+
+// splitSentences splits after ., ! or ? followed by a space
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i := 0; i < len(text)-1; i++ {
+		switch text[i] {
+		case '.', '!', '?':
+			if text[i+1] == ' ' {
+				sentences = append(sentences, text[start:i+1])
+				start = i + 2
+			}
+		}
+	}
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	return sentences
+}
@@ -0,0 +1,177 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// storedEmbeddings is the embeddings.json format. Vectors are kept out of
+// documents.json so the documents stay readable and the API doesn't return
+// them.
+type storedEmbeddings struct {
+	// Embedder is the Name of the embedder that produced the vectors
+	Embedder string `json:"embedder"`
+	// Vectors is keyed by embeddingKey, so chunks whose text hasn't
+	// changed keep their vectors when a document is edited or re-chunked
+	Vectors map[string][]float64 `json:"vectors"`
+}
+
+func (r *RAGDatabase) embeddingsPath() string {
+	return filepath.Join(r.dataPath, "embeddings.json")
+}
+
+// loadEmbeddings attaches stored vectors to chunks. Vectors from a
+// different embedder are discarded and recomputed.
+func (r *RAGDatabase) loadEmbeddings() error {
+	data, err := os.ReadFile(r.embeddingsPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var stored storedEmbeddings
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+	if stored.Embedder != r.embedder.Name() {
+		slog.Info("embedding provider changed, re-embedding RAG documents", "from", stored.Embedder, "to", r.embedder.Name())
+		return nil
+	}
+
+	for _, doc := range r.documents {
+		chunks := r.chunks[doc.ID]
+		for i := range chunks {
+			chunks[i].Embedding = stored.Vectors[embeddingKey(doc, chunks[i])]
+		}
+	}
+	return nil
+}
+
+func (r *RAGDatabase) saveEmbeddings() error {
+	if r.embedder == nil {
+		return nil
+	}
+
+	stored := storedEmbeddings{
+		Embedder: r.embedder.Name(),
+		Vectors:  make(map[string][]float64, len(r.chunks)),
+	}
+	for _, doc := range r.documents {
+		for _, chunk := range r.chunks[doc.ID] {
+			if chunk.Embedding != nil {
+				stored.Vectors[embeddingKey(doc, chunk)] = chunk.Embedding
+			}
+		}
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(r.embeddingsPath(), data)
+}
+
+// backfillEmbeddings embeds chunks that have no vector yet, e.g. seed
+// data or documents whose embedding failed when they were saved. Failures
+// are logged; those chunks are still found by keyword search.
+func (r *RAGDatabase) backfillEmbeddings() {
+	var missing []*RAGChunk
+	var texts []string
+	for _, doc := range r.documents {
+		chunks := r.chunks[doc.ID]
+		for i := range chunks {
+			if chunks[i].Embedding == nil {
+				missing = append(missing, &chunks[i])
+				texts = append(texts, embeddingText(doc, chunks[i]))
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	vectors, err := r.embedder.Embed(ctx, texts, EmbedDocument)
+	if err != nil {
+		slog.Warn("failed to embed RAG chunks, using keyword search for them", "chunks", len(missing), "error", err)
+		return
+	}
+	for i, chunk := range missing {
+		chunk.Embedding = vectors[i]
+	}
+
+	if err := r.saveEmbeddings(); err != nil {
+		slog.Warn("failed to save RAG embeddings", "error", err)
+		return
+	}
+	slog.Info("embedded RAG chunks", "chunks", len(missing), "embedder", r.embedder.Name())
+}
+
+// embedChunks sets the vectors of doc's chunks, reusing any the previous
+// version of the document had for identical text. On failure the chunks
+// are saved without vectors and embedded on the next startup.
+func (r *RAGDatabase) embedChunks(ctx context.Context, doc RAGDocument, chunks, previous []RAGChunk) {
+	if r.embedder == nil {
+		return
+	}
+
+	known := make(map[string][]float64, len(previous))
+	for _, chunk := range previous {
+		if chunk.Embedding != nil {
+			known[embeddingKey(doc, chunk)] = chunk.Embedding
+		}
+	}
+
+	var missing []int
+	var texts []string
+	for i := range chunks {
+		if vector, ok := known[embeddingKey(doc, chunks[i])]; ok {
+			chunks[i].Embedding = vector
+			continue
+		}
+		missing = append(missing, i)
+		texts = append(texts, embeddingText(doc, chunks[i]))
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	vectors, err := r.embedder.Embed(ctx, texts, EmbedDocument)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to embed RAG document", "id", doc.ID, "chunks", len(missing), "error", err)
+		return
+	}
+	for j, i := range missing {
+		chunks[i].Embedding = vectors[j]
+	}
+}
+
+// embeddingText is the text embedded for a chunk. The title and metadata
+// are repeated in every chunk so each one makes sense on its own.
+func embeddingText(doc RAGDocument, chunk RAGChunk) string {
+	parts := []string{doc.Title, chunk.Text}
+	if doc.Location != "" {
+		parts = append(parts, "Location: "+doc.Location)
+	}
+	if len(doc.Tags) > 0 {
+		parts = append(parts, "Tags: "+strings.Join(doc.Tags, ", "))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// embeddingKey identifies a chunk's vector by the text it was computed from
+func embeddingKey(doc RAGDocument, chunk RAGChunk) string {
+	sum := sha256.Sum256([]byte(embeddingText(doc, chunk)))
+	return hex.EncodeToString(sum[:])
+}
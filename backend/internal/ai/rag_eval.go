@@ -0,0 +1,186 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EvalQuery is a labelled retrieval query: the documents a good search
+// should return for it
+type EvalQuery struct {
+	Query    string   `json:"query"`
+	Relevant []string `json:"relevant"`
+}
+
+// EvalResult is how one query fared
+type EvalResult struct {
+	Query          string   `json:"query"`
+	Relevant       []string `json:"relevant"`
+	Retrieved      []string `json:"retrieved"`
+	Recall         float64  `json:"recall"`
+	ReciprocalRank float64  `json:"reciprocal_rank"`
+	NDCG           float64  `json:"ndcg"`
+}
+
+// EvalReport is the mean of each metric over a query set, with the
+// per-query results
+type EvalReport struct {
+	K       int          `json:"k"`
+	Recall  float64      `json:"recall_at_k"`
+	MRR     float64      `json:"mrr"`
+	NDCG    float64      `json:"ndcg_at_k"`
+	Queries []EvalResult `json:"queries"`
+}
+
+// LoadEvalQueries reads a JSONL file of labelled queries, one
+// {"query": ..., "relevant": [document IDs]} object per line
+func LoadEvalQueries(path string) ([]EvalQuery, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var queries []EvalQuery
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var q EvalQuery
+		if err := json.Unmarshal(text, &q); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if strings.TrimSpace(q.Query) == "" || len(q.Relevant) == 0 {
+			return nil, fmt.Errorf("%s:%d: query and relevant are required", path, line)
+		}
+		queries = append(queries, q)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(queries) == 0 {
+		return nil, errors.New(path + ": no queries")
+	}
+	return queries, nil
+}
+
+// OpenEvalStore opens a copy of the document store at dataPath with the
+// retrieval settings in config, so configurations can be compared without
+// touching the live store. Stored embeddings are copied too and reused
+// where the embedder matches. The returned function removes the copy.
+func OpenEvalStore(dataPath string, config *Config) (*RAGDatabase, func(), error) {
+	dir, err := os.MkdirTemp("", "rag-eval-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	for _, name := range []string{"documents.json", "embeddings.json"} {
+		data, err := os.ReadFile(filepath.Join(dataPath, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, name), data, 0644)
+		}
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+	}
+
+	rag, err := OpenRAGDatabase(config, dir)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return rag, cleanup, nil
+}
+
+// Evaluate runs each query through the same search chat uses and scores
+// the top k documents against the labels
+func (r *RAGDatabase) Evaluate(ctx context.Context, queries []EvalQuery, k int) EvalReport {
+	report := EvalReport{K: k, Queries: make([]EvalResult, 0, len(queries))}
+	for _, q := range queries {
+		retrieved := []string{}
+		for _, doc := range r.Search(ctx, q.Query, k) {
+			retrieved = append(retrieved, doc.ID)
+		}
+		result := scoreRetrieval(retrieved, q.Relevant, k)
+		result.Query = q.Query
+		result.Relevant = q.Relevant
+		report.Queries = append(report.Queries, result)
+
+		report.Recall += result.Recall
+		report.MRR += result.ReciprocalRank
+		report.NDCG += result.NDCG
+	}
+	if n := float64(len(queries)); n > 0 {
+		report.Recall /= n
+		report.MRR /= n
+		report.NDCG /= n
+	}
+	return report
+}
+
+// MissingDocuments lists labelled document IDs that aren't in the store,
+// which can never be retrieved and usually mean stale labels
+func (r *RAGDatabase) MissingDocuments(queries []EvalQuery) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	missing := []string{}
+	for _, q := range queries {
+		for _, id := range q.Relevant {
+			if !seen[id] && r.indexOf(id) < 0 {
+				missing = append(missing, id)
+			}
+			seen[id] = true
+		}
+	}
+	return missing
+}
+
+// scoreRetrieval scores the top k of a ranked list of document IDs against
+// the relevant ones. Relevance is binary: nDCG gains 1 for each relevant
+// document, discounted by log2 of its rank, against the best possible top k.
+func scoreRetrieval(retrieved, relevant []string, k int) EvalResult {
+	result := EvalResult{Retrieved: retrieved}
+	if len(relevant) == 0 {
+		return result
+	}
+
+	var hits int
+	var dcg float64
+	for i, id := range retrieved {
+		if !contains(relevant, id) {
+			continue
+		}
+		hits++
+		dcg += 1 / math.Log2(float64(i+2))
+		if result.ReciprocalRank == 0 {
+			result.ReciprocalRank = 1 / float64(i+1)
+		}
+	}
+
+	var ideal float64
+	for i := 0; i < len(relevant) && i < k; i++ {
+		ideal += 1 / math.Log2(float64(i+2))
+	}
+	result.Recall = float64(hits) / float64(len(relevant))
+	if ideal > 0 {
+		result.NDCG = dcg / ideal
+	}
+	return result
+}
@@ -0,0 +1,277 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DocumentSource records the uploaded file a document was extracted from
+type DocumentSource struct {
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"`
+	// SHA256 of the file, which also names the stored original
+	SHA256 string `json:"sha256"`
+	// Row is the CSV row the document came from
+	Row        int       `json:"row,omitempty"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// Upload statuses
+const (
+	UploadIngested  = "ingested"
+	UploadDuplicate = "duplicate"
+	UploadFailed    = "failed"
+)
+
+// UploadedFile is a file submitted for ingestion
+type UploadedFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// IngestOptions fills document fields the file doesn't provide
+type IngestOptions struct {
+	// Title overrides the title found in the file
+	Title    string
+	Category string
+	Location string
+	Tags     []string
+	// CSV maps columns to fields for .csv files
+	CSV CSVMapping
+	// Submit sends the documents for review instead of leaving them drafts
+	Submit bool
+}
+
+// IngestResult is the outcome for one uploaded file
+type IngestResult struct {
+	File   string `json:"file"`
+	Status string `json:"status"`
+	// Documents are the IDs created from the file
+	Documents []string `json:"documents,omitempty"`
+	// Duplicates are existing documents with the same content, which were
+	// not added again
+	Duplicates []string `json:"duplicates,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// ContentHash identifies document content regardless of case and spacing
+func ContentHash(content string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(content), " "))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetDocumentByContentHash returns a copy of a document with the given
+// ContentHash, or nil
+func (r *RAGDatabase) GetDocumentByContentHash(hash string) *RAGDocument {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if i := r.indexOfContentHash(hash); i >= 0 {
+		doc := r.documents[i].clone()
+		return &doc
+	}
+	return nil
+}
+
+// indexOfContentHash is indexOf by ContentHash. The caller must hold r.mu.
+func (r *RAGDatabase) indexOfContentHash(hash string) int {
+	for i := range r.documents {
+		if r.documents[i].ContentHash == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r *RAGDatabase) filesPath() string {
+	return filepath.Join(r.dataPath, "files")
+}
+
+// OriginalPath is where the uploaded file behind source is kept
+func (r *RAGDatabase) OriginalPath(source *DocumentSource) string {
+	return filepath.Join(r.filesPath(), source.SHA256+strings.ToLower(filepath.Ext(source.FileName)))
+}
+
+// saveOriginal stores an uploaded file by its hash, so uploading the same
+// file twice keeps one copy. The caller must hold r.mu for writing so a
+// concurrent delete can't remove the file before its document is added.
+func (r *RAGDatabase) saveOriginal(file UploadedFile) (*DocumentSource, error) {
+	sum := sha256.Sum256(file.Data)
+	source := &DocumentSource{
+		FileName:    filepath.Base(file.Name),
+		ContentType: file.ContentType,
+		Size:        int64(len(file.Data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		UploadedAt:  time.Now().UTC(),
+	}
+
+	if err := os.MkdirAll(r.filesPath(), 0755); err != nil {
+		return nil, err
+	}
+	path := r.OriginalPath(source)
+	if _, err := os.Stat(path); err == nil {
+		return source, nil
+	}
+	if err := writeFileAtomic(path, file.Data); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// removeOriginal deletes a stored file once no document refers to it. The
+// caller must hold r.mu for writing.
+func (r *RAGDatabase) removeOriginal(source *DocumentSource) {
+	if source == nil {
+		return
+	}
+	for _, doc := range r.documents {
+		if doc.Source != nil && doc.Source.SHA256 == source.SHA256 {
+			return
+		}
+	}
+	if err := os.Remove(r.OriginalPath(source)); err != nil && !os.IsNotExist(err) {
+		slog.Warn("failed to remove uploaded RAG file", "path", r.OriginalPath(source), "error", err)
+	}
+}
+
+// IngestFile extracts documents from an uploaded file and adds the ones
+// whose content isn't already in the store. CSV files give one document
+// per row; other formats give one document, chunked like any other.
+func (r *RAGDatabase) IngestFile(ctx context.Context, file UploadedFile, options IngestOptions) IngestResult {
+	result := IngestResult{File: file.Name}
+	// The client gets a short message; the error, which can hold paths
+	// and parser internals, is only logged
+	fail := func(message string, err error) IngestResult {
+		slog.WarnContext(ctx, "failed to ingest uploaded file", "file", file.Name, "error", err)
+		result.Status = UploadFailed
+		result.Error = message
+		return result
+	}
+	parseFailure := func(err error) IngestResult {
+		switch {
+		case errors.Is(err, ErrUnsupportedFormat):
+			return fail("unsupported format", err)
+		case errors.Is(err, errPDFTooLarge):
+			return fail("file is too large", err)
+		}
+		return fail("could not parse file", err)
+	}
+
+	var docs []RAGDocument
+	var rows []int
+	if strings.EqualFold(filepath.Ext(file.Name), ".csv") {
+		records, err := ParseCSVDocuments(file.Data, options.CSV)
+		if err != nil {
+			return parseFailure(err)
+		}
+		for _, record := range records {
+			doc := RAGDocument{
+				Title:    record.Title,
+				Content:  record.Content,
+				Category: record.Category,
+				Location: record.Location,
+				Tags:     record.Tags,
+			}
+			if doc.Title == "" {
+				doc.Title = fmt.Sprintf("%s row %d", file.Name, record.Row)
+			}
+			docs = append(docs, doc)
+			rows = append(rows, record.Row)
+		}
+		if len(docs) == 0 {
+			return fail("no rows with content", errors.New("CSV rows have no content"))
+		}
+	} else {
+		extracted, err := ExtractText(file.Name, file.Data)
+		if err != nil {
+			return parseFailure(err)
+		}
+		title := options.Title
+		if title == "" {
+			title = extracted.Title
+		}
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(file.Name), filepath.Ext(file.Name))
+		}
+		docs = append(docs, RAGDocument{Title: title, Content: extracted.Text})
+		rows = append(rows, 0)
+	}
+
+	var source *DocumentSource
+	for i, doc := range docs {
+		doc.ContentHash = ContentHash(doc.Content)
+		// Check before embedding to save the work, and again under the lock
+		// in case a concurrent upload added the same content
+		if existing := r.GetDocumentByContentHash(doc.ContentHash); existing != nil {
+			result.Duplicates = append(result.Duplicates, existing.ID)
+			continue
+		}
+
+		doc.ID = "rag-" + uuid.New().String()
+		if doc.Category == "" {
+			doc.Category = options.Category
+		}
+		if doc.Location == "" {
+			doc.Location = options.Location
+		}
+		doc.Tags = append(append([]string{}, options.Tags...), doc.Tags...)
+		doc.Status = StatusDraft
+		if options.Submit {
+			doc.Status = StatusPendingReview
+		}
+		chunks := r.prepareChunks(ctx, doc, nil)
+
+		duplicate, err := func() (string, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			if j := r.indexOfContentHash(doc.ContentHash); j >= 0 {
+				return r.documents[j].ID, nil
+			}
+			// Keep the original once something from it is added
+			if source == nil {
+				saved, err := r.saveOriginal(file)
+				if err != nil {
+					return "", fmt.Errorf("failed to store file: %w", err)
+				}
+				source = saved
+			}
+			docSource := *source
+			docSource.Row = rows[i]
+			doc.Source = &docSource
+
+			return "", r.commit(func() {
+				r.documents = append(r.documents, doc)
+				r.setChunks(doc, chunks)
+				r.recordVersion(ctx, VersionCreated, doc)
+			})
+		}()
+		if err != nil {
+			return fail("could not save file", err)
+		}
+		if duplicate != "" {
+			result.Duplicates = append(result.Duplicates, duplicate)
+			continue
+		}
+		result.Documents = append(result.Documents, doc.ID)
+	}
+
+	result.Status = UploadIngested
+	if len(result.Documents) == 0 {
+		result.Status = UploadDuplicate
+	}
+	return result
+}
@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// FreshnessConfig weights retrieval toward recent documents and decides
+// when a document is due for review
+type FreshnessConfig struct {
+	// RecencyWeight is the weight of the ranking by document age in the
+	// fusion, against 1 for each relevance ranking, from 0 (age ignored)
+	// to 1
+	RecencyWeight float64
+	// HalfLife is the age at which a document counts as half as fresh
+	HalfLife time.Duration
+	// StaleAfter is how long after publishing a document is reported as
+	// due for review
+	StaleAfter time.Duration
+}
+
+// active reports whether doc is in effect at now: past its effective date
+// and not yet expired
+func (d RAGDocument) active(now time.Time) bool {
+	if d.EffectiveFrom != nil && now.Before(*d.EffectiveFrom) {
+		return false
+	}
+	return d.ExpiresAt == nil || now.Before(*d.ExpiresAt)
+}
+
+// datedAt is when a document's content dates from: when it was published,
+// or failing that when it took effect
+func (d RAGDocument) datedAt() *time.Time {
+	if d.PublishedAt != nil {
+		return d.PublishedAt
+	}
+	return d.EffectiveFrom
+}
+
+// recency is how fresh doc is at now: 1 when new, halving every
+// half-life. Undated documents are treated as a half-life old, so they
+// neither beat fresh documents nor sink below old ones.
+func (f FreshnessConfig) recency(doc RAGDocument, now time.Time) float64 {
+	dated := doc.datedAt()
+	if dated == nil {
+		return 0.5
+	}
+	age := now.Sub(*dated)
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, float64(age)/float64(f.HalfLife))
+}
+
+// Reasons a document is reported as stale
+const (
+	StaleExpired     = "expired"
+	StaleExpiring    = "expiring"
+	StaleNotReviewed = "not_reviewed"
+	StaleUndated     = "undated"
+)
+
+// StaleDocument is a document due for review, with why
+type StaleDocument struct {
+	Document RAGDocument `json:"document"`
+	Reasons  []string    `json:"reasons"`
+}
+
+// StaleDocuments lists documents that have expired, expire within the
+// given window, were last published more than StaleAfter ago, or are
+// published with no date, most overdue first and undated last. Archived documents and
+// documents generated from database records are left out, as nothing in
+// them needs a reviewer.
+func (r *RAGDatabase) StaleDocuments(within time.Duration) []StaleDocument {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	stale := []StaleDocument{}
+	for _, doc := range r.documents {
+		if doc.Status == StatusArchived || doc.Record != nil {
+			continue
+		}
+
+		var reasons []string
+		switch {
+		case doc.ExpiresAt != nil && !now.Before(*doc.ExpiresAt):
+			reasons = append(reasons, StaleExpired)
+		case doc.ExpiresAt != nil && doc.ExpiresAt.Sub(now) <= within:
+			reasons = append(reasons, StaleExpiring)
+		}
+		if doc.Status == StatusPublished {
+			switch {
+			case doc.PublishedAt == nil:
+				reasons = append(reasons, StaleUndated)
+			case r.freshness.StaleAfter > 0 && now.Sub(*doc.PublishedAt) > r.freshness.StaleAfter:
+				reasons = append(reasons, StaleNotReviewed)
+			}
+		}
+		if len(reasons) > 0 {
+			stale = append(stale, StaleDocument{Document: doc.clone(), Reasons: reasons})
+		}
+	}
+
+	// By due date, so expired documents come first, then those expiring
+	// or overdue for review; undated documents have none and come last
+	due := func(doc RAGDocument) (time.Time, bool) {
+		if doc.ExpiresAt != nil {
+			return *doc.ExpiresAt, true
+		}
+		if doc.PublishedAt != nil {
+			return doc.PublishedAt.Add(r.freshness.StaleAfter), true
+		}
+		return time.Time{}, false
+	}
+	sort.SliceStable(stale, func(i, j int) bool {
+		a, aDated := due(stale[i].Document)
+		b, bDated := due(stale[j].Document)
+		if aDated != bDated {
+			return aDated
+		}
+		return a.Before(b)
+	})
+	return stale
+}
+
+// cloneTime copies a time pointer so a clone shares no memory with the
+// store
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	copied := *t
+	return &copied
+}
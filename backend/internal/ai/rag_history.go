@@ -0,0 +1,446 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Version actions
+const (
+	VersionCreated  = "create"
+	VersionUpdated  = "update"
+	VersionDeleted  = "delete"
+	VersionRestored = "rollback"
+)
+
+// Authors recorded for changes made by the server itself
+const (
+	AuthorSystem     = "system"
+	AuthorRecordSync = "record-sync"
+	authorAnonymous  = "anonymous"
+)
+
+// ErrVersionNotFound is returned for a version a document never had
+var ErrVersionNotFound = errors.New("version not found")
+
+// DocumentVersion is a document as it stood after one change. Versions
+// are numbered from 1 per document and never rewritten.
+type DocumentVersion struct {
+	DocumentID string    `json:"document_id"`
+	Version    int       `json:"version"`
+	Action     string    `json:"action"`
+	Author     string    `json:"author"`
+	Timestamp  time.Time `json:"timestamp"`
+	// RestoredFrom is the version a rollback copied
+	RestoredFrom int `json:"restored_from,omitempty"`
+	// Comment is the reviewer's comment on an approval or rejection
+	Comment string `json:"comment,omitempty"`
+	// Document is the content after the change, or for a delete the
+	// content that was deleted
+	Document RAGDocument `json:"document"`
+}
+
+type authorKey struct{}
+
+// WithAuthor attaches the user making a change to ctx, for the history
+func WithAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorKey{}, author)
+}
+
+func authorFrom(ctx context.Context) string {
+	if author, _ := ctx.Value(authorKey{}).(string); author != "" {
+		return author
+	}
+	return authorAnonymous
+}
+
+func (r *RAGDatabase) historyPath() string {
+	return filepath.Join(r.dataPath, "history.jsonl")
+}
+
+// loadHistory reads the version log. A line cut short by a crash mid-write
+// is skipped.
+func (r *RAGDatabase) loadHistory() error {
+	data, err := os.ReadFile(r.historyPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var version DocumentVersion
+		if err := json.Unmarshal(scanner.Bytes(), &version); err != nil {
+			slog.Warn("skipping unreadable RAG history entry", "line", line, "error", err)
+			continue
+		}
+		r.history[version.DocumentID] = append(r.history[version.DocumentID], version)
+	}
+	return scanner.Err()
+}
+
+// baselineHistory gives documents that predate the history, such as the
+// seed documents, a first version so they can be rolled back to
+func (r *RAGDatabase) baselineHistory() error {
+	for _, doc := range r.documents {
+		if len(r.history[doc.ID]) == 0 {
+			r.recordVersion(WithAuthor(context.Background(), AuthorSystem), VersionCreated, doc)
+		}
+	}
+	if len(r.pending) == 0 {
+		return nil
+	}
+	return r.flushHistory()
+}
+
+// recordVersion queues a version of doc to be written by commit. The
+// caller must hold r.mu for writing.
+func (r *RAGDatabase) recordVersion(ctx context.Context, action string, doc RAGDocument) {
+	r.pending = append(r.pending, DocumentVersion{
+		DocumentID: doc.ID,
+		Action:     action,
+		Author:     authorFrom(ctx),
+		Timestamp:  time.Now().UTC(),
+		Document:   doc.clone(),
+	})
+}
+
+// flushHistory numbers the queued versions and appends them to the log.
+// Nothing is kept in memory unless the write succeeds. The caller must
+// hold r.mu for writing.
+func (r *RAGDatabase) flushHistory() error {
+	pending := r.pending
+	r.pending = nil
+
+	next := make(map[string]int)
+	var buf bytes.Buffer
+	for i := range pending {
+		id := pending[i].DocumentID
+		if _, ok := next[id]; !ok {
+			next[id] = len(r.history[id]) + 1
+		}
+		pending[i].Version = next[id]
+		next[id]++
+
+		line, err := json.Marshal(pending[i])
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	file, err := os.OpenFile(r.historyPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	for _, version := range pending {
+		r.history[version.DocumentID] = append(r.history[version.DocumentID], version)
+	}
+	return nil
+}
+
+// History returns every version of a document, oldest first, including
+// documents that have since been deleted
+func (r *RAGDatabase) History(id string) []DocumentVersion {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := make([]DocumentVersion, len(r.history[id]))
+	for i, version := range r.history[id] {
+		version.Document = version.Document.clone()
+		versions[i] = version
+	}
+	return versions
+}
+
+// GetVersion returns one version of a document
+func (r *RAGDatabase) GetVersion(id string, version int) (*DocumentVersion, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.version(id, version)
+}
+
+// version looks up a version, returning a copy. The caller must hold r.mu.
+func (r *RAGDatabase) version(id string, version int) (*DocumentVersion, error) {
+	versions := r.history[id]
+	if len(versions) == 0 {
+		return nil, ErrDocumentNotFound
+	}
+	if version < 1 || version > len(versions) {
+		return nil, ErrVersionNotFound
+	}
+	v := versions[version-1]
+	v.Document = v.Document.clone()
+	return &v, nil
+}
+
+// RollbackDocument restores a document to the content it had at version,
+// recorded as a new version. A deleted document is added back. Like an
+// edit, the restored content is a draft, or pending review when status asks
+// for it, so only an approval can publish it.
+func (r *RAGDatabase) RollbackDocument(ctx context.Context, id string, version int, status string) (RAGDocument, error) {
+	r.mu.RLock()
+	target, err := r.version(id, version)
+	var previous []RAGChunk
+	var existing *RAGDocument
+	if err == nil {
+		previous = append(previous, r.chunks[id]...)
+		if i := r.indexOf(id); i >= 0 {
+			current := r.documents[i]
+			existing = &current
+		}
+	}
+	r.mu.RUnlock()
+	if err != nil {
+		return RAGDocument{}, err
+	}
+	if target.Document.Record != nil {
+		return RAGDocument{}, ErrRecordDocument
+	}
+
+	doc := target.Document
+	doc.ContentHash = ContentHash(doc.Content)
+	doc.Status = editedStatus(status)
+	// The latest decision and publish date stay with the document
+	if existing != nil {
+		doc.Review = existing.Review
+		doc.PublishedAt = existing.PublishedAt
+	}
+	chunks := r.prepareChunks(ctx, doc, previous)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err = r.commit(func() {
+		if i := r.indexOf(id); i >= 0 {
+			r.documents[i] = doc
+		} else {
+			r.documents = append(r.documents, doc)
+		}
+		r.setChunks(doc, chunks)
+		r.recordVersion(ctx, VersionRestored, doc)
+		r.pending[len(r.pending)-1].RestoredFrom = version
+	})
+	return doc.clone(), err
+}
+
+// FieldChange is a metadata field that differs between two versions
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// VersionDiff compares two versions of a document
+type VersionDiff struct {
+	DocumentID string `json:"document_id"`
+	From       int    `json:"from"`
+	To         int    `json:"to"`
+	// Fields lists changed title, category, location and tags
+	Fields map[string]FieldChange `json:"fields"`
+	// Content is a unified diff of the content, empty when it's unchanged
+	Content string `json:"content"`
+}
+
+// DiffVersions compares versions from and to of a document
+func (r *RAGDatabase) DiffVersions(id string, from, to int) (*VersionDiff, error) {
+	r.mu.RLock()
+	a, err := r.version(id, from)
+	if err != nil {
+		r.mu.RUnlock()
+		return nil, err
+	}
+	b, err := r.version(id, to)
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &VersionDiff{DocumentID: id, From: from, To: to, Fields: map[string]FieldChange{}}
+	for _, field := range []struct {
+		name string
+		a, b string
+	}{
+		{"title", a.Document.Title, b.Document.Title},
+		{"category", a.Document.Category, b.Document.Category},
+		{"location", a.Document.Location, b.Document.Location},
+	} {
+		if field.a != field.b {
+			diff.Fields[field.name] = FieldChange{From: field.a, To: field.b}
+		}
+	}
+	if strings.Join(a.Document.Tags, "\x00") != strings.Join(b.Document.Tags, "\x00") {
+		diff.Fields["tags"] = FieldChange{From: a.Document.Tags, To: b.Document.Tags}
+	}
+	diff.Content = unifiedDiff(a.Document.Content, b.Document.Content,
+		fmt.Sprintf("version %d", from), fmt.Sprintf("version %d", to))
+	return diff, nil
+}
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// maxDiffCells bounds the line-matching table. Past it, the differing
+// middle of the texts is shown as removed and re-added in full.
+const maxDiffCells = 4 << 20
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	text string
+	// a and b are the 0-based line numbers in each text before this line
+	a, b int
+}
+
+// unifiedDiff returns the line differences between a and b in unified
+// format, or "" if there are none
+func unifiedDiff(a, b, aName, bName string) string {
+	if a == b {
+		return ""
+	}
+	ops := diffLines(strings.Split(a, "\n"), strings.Split(b, "\n"))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	for start := 0; start < len(ops); {
+		// Find the next change and extend the hunk while changes are
+		// close enough for their context to overlap
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first; i < len(ops) && i <= last+2*diffContext; i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			}
+		}
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(ops))
+
+		aLen, bLen := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+		aStart, bStart := ops[from].a, ops[from].b
+		if aLen > 0 {
+			aStart++
+		}
+		if bLen > 0 {
+			bStart++
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+		for _, op := range ops[from:to] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// diffLines matches the lines of a and b by longest common subsequence
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	var ops []diffOp
+	ai, bi := 0, 0
+	emit := func(kind byte, text string) {
+		ops = append(ops, diffOp{kind: kind, text: text, a: ai, b: bi})
+		if kind != '+' {
+			ai++
+		}
+		if kind != '-' {
+			bi++
+		}
+	}
+
+	for _, line := range a[:prefix] {
+		emit(' ', line)
+	}
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, line := range midA {
+			emit('-', line)
+		}
+		for _, line := range midB {
+			emit('+', line)
+		}
+	} else {
+		// lcs[i][j] is the common subsequence length of midA[i:], midB[j:]
+		n, m := len(midA), len(midB)
+		lcs := make([][]int, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && midA[i] == midB[j]:
+				emit(' ', midA[i])
+				i++
+				j++
+			case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+				emit('-', midA[i])
+				i++
+			default:
+				emit('+', midB[j])
+				j++
+			}
+		}
+	}
+	for _, line := range a[len(a)-suffix:] {
+		emit(' ', line)
+	}
+	return ops
+}
@@ -0,0 +1,134 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Document review statuses. Documents start as drafts, are submitted for
+// review, and are retrieved for chat only once a reviewer publishes them.
+const (
+	StatusDraft         = "draft"
+	StatusPendingReview = "pending_review"
+	StatusPublished     = "published"
+	StatusArchived      = "archived"
+)
+
+// Review actions, recorded in the history like edits
+const (
+	VersionSubmitted = "submit"
+	VersionApproved  = "approve"
+	VersionRejected  = "reject"
+	VersionArchived  = "archive"
+)
+
+// RoleReviewer is the session role allowed to approve and reject documents
+const RoleReviewer = "reviewer"
+
+var (
+	// ErrInvalidTransition is returned for a review action the document's
+	// status doesn't allow
+	ErrInvalidTransition = errors.New("invalid review action for document status")
+	// ErrSelfReview is returned when a reviewer decides on a document they
+	// submitted themselves
+	ErrSelfReview = errors.New("documents must be reviewed by someone other than the submitter")
+	// ErrCommentRequired is returned when rejecting without saying why
+	ErrCommentRequired = errors.New("a comment is required to reject a document")
+)
+
+// DocumentReview is a reviewer's decision on a document
+type DocumentReview struct {
+	Reviewer  string    `json:"reviewer"`
+	Decision  string    `json:"decision"`
+	Comment   string    `json:"comment,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// reviewTransitions lists the statuses each action applies to and the
+// status it leaves the document in
+var reviewTransitions = map[string]struct {
+	from []string
+	to   string
+}{
+	VersionSubmitted: {from: []string{StatusDraft}, to: StatusPendingReview},
+	VersionApproved:  {from: []string{StatusPendingReview}, to: StatusPublished},
+	VersionRejected:  {from: []string{StatusPendingReview}, to: StatusDraft},
+	VersionArchived:  {from: []string{StatusDraft, StatusPendingReview, StatusPublished}, to: StatusArchived},
+}
+
+// editedStatus is the status of a created or edited document: pending
+// review if that was asked for, otherwise a draft
+func editedStatus(requested string) string {
+	if requested == StatusPendingReview {
+		return StatusPendingReview
+	}
+	return StatusDraft
+}
+
+// ReviewDocument applies a review action to a document: submit, approve,
+// reject or archive. Approvals and rejections are recorded on the document
+// with the comment, and every action is recorded in its history.
+func (r *RAGDatabase) ReviewDocument(ctx context.Context, id, action, comment string) (RAGDocument, error) {
+	transition, ok := reviewTransitions[action]
+	if !ok {
+		return RAGDocument{}, fmt.Errorf("unknown review action %q", action)
+	}
+	if action == VersionRejected && comment == "" {
+		return RAGDocument{}, ErrCommentRequired
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := r.indexOf(id)
+	if i < 0 {
+		return RAGDocument{}, ErrDocumentNotFound
+	}
+	doc := r.documents[i].clone()
+	if doc.Record != nil {
+		return RAGDocument{}, ErrRecordDocument
+	}
+	if !contains(transition.from, doc.Status) {
+		return RAGDocument{}, fmt.Errorf("%w: can't %s a document that is %s", ErrInvalidTransition, action, doc.Status)
+	}
+
+	author := authorFrom(ctx)
+	if action == VersionApproved || action == VersionRejected {
+		if author == r.submitter(id) {
+			return RAGDocument{}, ErrSelfReview
+		}
+		doc.Review = &DocumentReview{
+			Reviewer:  author,
+			Decision:  action,
+			Comment:   comment,
+			Timestamp: time.Now().UTC(),
+		}
+	}
+	doc.Status = transition.to
+	if doc.Status == StatusPublished {
+		now := time.Now().UTC()
+		doc.PublishedAt = &now
+	}
+
+	err := r.commit(func() {
+		r.documents[i] = doc
+		r.recordVersion(ctx, action, doc)
+		r.pending[len(r.pending)-1].Comment = comment
+	})
+	return doc.clone(), err
+}
+
+// submitter returns who put a document up for review most recently,
+// whether by submitting it or by saving it as pending review. The caller
+// must hold r.mu.
+func (r *RAGDatabase) submitter(id string) string {
+	versions := r.history[id]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].Document.Status == StatusPendingReview {
+			return versions[i].Author
+		}
+	}
+	return ""
+}
@@ -0,0 +1,470 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"serpico/backend/internal/database"
+)
+
+// Tables mirrored into the knowledge base
+const (
+	RecordCases       = "cases"
+	RecordPerps       = "perps"
+	RecordEmergencies = "emergencies"
+)
+
+var recordTables = []string{RecordCases, RecordPerps, RecordEmergencies}
+
+// RecordRef points a generated document at the database row it mirrors
+type RecordRef struct {
+	Table string `json:"table"`
+	// ID is the row's primary key, empty for a summary of the whole table
+	ID string `json:"id,omitempty"`
+}
+
+// RecordsVisible reports whether role may read documents generated from
+// individual rows, which hold perps' whereabouts and case details. The
+// table summaries are public.
+func RecordsVisible(role string) bool {
+	return role == ChatRolePolice || role == ChatRoleAdmin
+}
+
+// VisibleTo reports whether role may read the document
+func (d RAGDocument) VisibleTo(role string) bool {
+	return d.Record == nil || d.Record.ID == "" || RecordsVisible(role)
+}
+
+// recordDocumentID is the stable document ID for a row, so regenerating a
+// row's document replaces it rather than adding another
+func recordDocumentID(table, id string) string {
+	return "db-" + table + "-" + id
+}
+
+// RecordSync keeps documents generated from the cases, perps and
+// emergencies tables in step with the database. Handlers that write a row
+// call Notify; Run reconciles every table at startup and then on an
+// interval, which also catches rows changed outside the API.
+type RecordSync struct {
+	rag      *RAGDatabase
+	db       *database.Database
+	interval time.Duration
+	// mu runs one sync at a time, so a reconcile that read the tables
+	// before a write can't commit after that write's own sync
+	mu sync.Mutex
+}
+
+// NewRecordSync creates a sync between db and rag. A zero interval only
+// reconciles once, at startup.
+func NewRecordSync(rag *RAGDatabase, db *database.Database, interval time.Duration) *RecordSync {
+	return &RecordSync{rag: rag, db: db, interval: interval}
+}
+
+// Run reconciles now and then every interval until ctx is done
+func (s *RecordSync) Run(ctx context.Context) {
+	s.reconcileAndLog(ctx)
+	if s.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reconcileAndLog(ctx)
+		}
+	}
+}
+
+func (s *RecordSync) reconcileAndLog(ctx context.Context) {
+	if err := s.Reconcile(ctx); err != nil {
+		slog.Warn("failed to sync database records into RAG", "error", err)
+	}
+}
+
+// Reconcile regenerates the documents for every row, replacing the ones
+// whose row changed and removing the ones whose row is gone
+func (s *RecordSync) Reconcile(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx = WithAuthor(ctx, AuthorRecordSync)
+	for _, table := range recordTables {
+		docs, err := s.tableDocuments(table)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		current := make(map[string]bool, len(docs))
+		for _, doc := range docs {
+			current[doc.ID] = true
+		}
+
+		result, err := s.rag.syncRecords(ctx, table, docs, func(doc RAGDocument) bool {
+			return !current[doc.ID]
+		})
+		if err != nil {
+			return fmt.Errorf("failed to sync %s: %w", table, err)
+		}
+		if result.changed() {
+			slog.Info("synced database records into RAG", "table", table,
+				"added", result.Added, "updated", result.Updated, "removed", result.Removed)
+		}
+	}
+	return nil
+}
+
+// SyncRecord regenerates the document for one row after a write, or
+// removes it if the row is gone
+func (s *RecordSync) SyncRecord(ctx context.Context, table, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx = WithAuthor(ctx, AuthorRecordSync)
+	doc, err := s.rowDocument(table, id)
+	if err != nil {
+		return err
+	}
+	var docs []RAGDocument
+	if doc != nil {
+		docs = append(docs, *doc)
+	}
+	// The table summary counts every row, so it changes with any of them
+	if table == RecordCases {
+		summary, err := s.caseSummary()
+		if err != nil {
+			return err
+		}
+		docs = append(docs, summary)
+	}
+
+	docID := recordDocumentID(table, id)
+	_, err = s.rag.syncRecords(ctx, table, docs, func(existing RAGDocument) bool {
+		return doc == nil && existing.ID == docID
+	})
+	return err
+}
+
+// Notify syncs a row in the background, so the request that wrote it
+// doesn't wait on embedding. Failures are logged and left for the next
+// reconcile.
+func (s *RecordSync) Notify(table, id string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := s.SyncRecord(ctx, table, id); err != nil {
+			slog.Warn("failed to sync database record into RAG", "table", table, "id", id, "error", err)
+		}
+	}()
+}
+
+// tableDocuments generates the documents for every row of table
+func (s *RecordSync) tableDocuments(table string) ([]RAGDocument, error) {
+	var docs []RAGDocument
+	switch table {
+	case RecordCases:
+		cases, err := s.db.AllCases()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cases {
+			docs = append(docs, caseDocument(c))
+		}
+		summary, err := s.caseSummary()
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, summary)
+	case RecordPerps:
+		perps, err := s.db.AllPerps()
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range perps {
+			docs = append(docs, perpDocument(p))
+		}
+	case RecordEmergencies:
+		emergencies, err := s.db.AllEmergencies()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range emergencies {
+			docs = append(docs, emergencyDocument(e))
+		}
+	default:
+		return nil, fmt.Errorf("unknown record table %q", table)
+	}
+	return docs, nil
+}
+
+// rowDocument generates the document for one row, or returns nil if the
+// row doesn't exist
+func (s *RecordSync) rowDocument(table, id string) (*RAGDocument, error) {
+	var doc RAGDocument
+	switch table {
+	case RecordCases:
+		c, err := s.db.GetCase(id)
+		if err != nil || c == nil {
+			return nil, err
+		}
+		doc = caseDocument(*c)
+	case RecordPerps:
+		p, err := s.db.GetPerp(id)
+		if err != nil || p == nil {
+			return nil, err
+		}
+		doc = perpDocument(*p)
+	case RecordEmergencies:
+		e, err := s.db.GetEmergency(id)
+		if err != nil || e == nil {
+			return nil, err
+		}
+		doc = emergencyDocument(*e)
+	default:
+		return nil, fmt.Errorf("unknown record table %q", table)
+	}
+	return &doc, nil
+}
+
+// recordFields writes label and value pairs as "Label: value" lines,
+// skipping empty values
+func recordFields(pairs ...string) string {
+	var lines []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if value := strings.TrimSpace(pairs[i+1]); value != "" {
+			lines = append(lines, pairs[i]+": "+value)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// recordDateLayouts are the date formats found in the tables: plain dates,
+// RFC 3339 from the API and SQLite's CURRENT_TIMESTAMP
+var recordDateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05"}
+
+// recordDate parses a date column, returning nil if it's empty or in
+// another format
+func recordDate(value string) *time.Time {
+	for _, layout := range recordDateLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+func recordDocument(table, id, title, content, category, location, date string) RAGDocument {
+	return RAGDocument{
+		ID:       recordDocumentID(table, id),
+		Title:    title,
+		Content:  content,
+		Category: category,
+		Location: location,
+		Tags:     []string{table, id},
+		Record:   &RecordRef{Table: table, ID: id},
+		// Records are already the department's data, so there's nothing to
+		// review
+		Status:      StatusPublished,
+		PublishedAt: recordDate(date),
+	}
+}
+
+func caseDocument(c database.Case) RAGDocument {
+	solved := "No"
+	if c.Solved {
+		solved = "Yes"
+	}
+	return recordDocument(RecordCases, c.ID,
+		fmt.Sprintf("Case %s: %s at %s", c.ID, c.Type, c.Location),
+		recordFields(
+			"Case ID", c.ID,
+			"Type", c.Type,
+			"Location", c.Location,
+			"Date", c.Date,
+			"Status", c.Status,
+			"Solved", solved,
+			"Description", c.Description,
+		),
+		"crime_stats", c.Location, c.Date)
+}
+
+func perpDocument(p database.Perp) RAGDocument {
+	return recordDocument(RecordPerps, p.ID,
+		fmt.Sprintf("Perp %s (%s)", p.Alias, p.ID),
+		recordFields(
+			"Perp ID", p.ID,
+			"Alias", p.Alias,
+			"Location", p.Location,
+			"Last seen", p.LastSeen,
+			"Status", p.Status,
+		),
+		"perps", p.Location, p.LastSeen)
+}
+
+func emergencyDocument(e database.Emergency) RAGDocument {
+	return recordDocument(RecordEmergencies, e.ID,
+		fmt.Sprintf("Emergency %s: %s at %s", e.ID, e.Type, e.Location),
+		recordFields(
+			"Emergency ID", e.ID,
+			"Type", e.Type,
+			"Location", e.Location,
+			"Priority", e.Priority,
+			"Category", e.Category,
+			"Status", e.Status,
+			"Reported", e.CreatedAt,
+		),
+		"crime_stats", e.Location, e.CreatedAt)
+}
+
+// caseSummary generates the document of case totals, so questions about
+// overall numbers are answered from the records
+func (s *RecordSync) caseSummary() (RAGDocument, error) {
+	stats, err := s.db.CrimeStatsByArea("")
+	if err != nil {
+		return RAGDocument{}, err
+	}
+	latest, err := s.db.LatestCaseDate()
+	if err != nil {
+		return RAGDocument{}, err
+	}
+
+	types := make([]string, 0, len(stats.ByType))
+	for caseType := range stats.ByType {
+		types = append(types, caseType)
+	}
+	sort.Strings(types)
+	var byType []string
+	for _, caseType := range types {
+		byType = append(byType, fmt.Sprintf("%s %d", caseType, stats.ByType[caseType]))
+	}
+	solveRate := ""
+	if stats.Total > 0 {
+		solveRate = fmt.Sprintf("%d%%", stats.Solved*100/stats.Total)
+	}
+
+	doc := recordDocument(RecordCases, "summary", "Case totals from department records",
+		recordFields(
+			"Total cases", fmt.Sprint(stats.Total),
+			"Solved", fmt.Sprint(stats.Solved),
+			"Open or unsolved", fmt.Sprint(stats.Open),
+			"Solve rate", solveRate,
+			"Cases by type", strings.Join(byType, ", "),
+		),
+		"crime_stats", "", latest)
+	doc.Tags = []string{RecordCases, "statistics"}
+	doc.Record.ID = ""
+	return doc, nil
+}
+
+// recordSyncResult counts the documents a sync changed
+type recordSyncResult struct {
+	Added, Updated, Removed int
+}
+
+func (r recordSyncResult) changed() bool {
+	return r.Added+r.Updated+r.Removed > 0
+}
+
+// syncRecords adds or replaces the documents generated from table's rows
+// and removes that table's documents for which stale returns true, in one
+// save. Documents that haven't changed aren't re-embedded or saved.
+func (r *RAGDatabase) syncRecords(ctx context.Context, table string, docs []RAGDocument, stale func(RAGDocument) bool) (recordSyncResult, error) {
+	var changed []RAGDocument
+	var previous [][]RAGChunk
+	var removed []string
+
+	r.mu.RLock()
+	for _, doc := range docs {
+		doc.ContentHash = ContentHash(doc.Content)
+		if i := r.indexOf(doc.ID); i >= 0 {
+			if sameRecordDocument(r.documents[i], doc) {
+				continue
+			}
+			previous = append(previous, append([]RAGChunk(nil), r.chunks[doc.ID]...))
+		} else {
+			previous = append(previous, nil)
+		}
+		changed = append(changed, doc)
+	}
+	for _, doc := range r.documents {
+		if doc.Record != nil && doc.Record.Table == table && stale(doc) {
+			removed = append(removed, doc.ID)
+		}
+	}
+	r.mu.RUnlock()
+
+	if len(changed) == 0 && len(removed) == 0 {
+		return recordSyncResult{}, nil
+	}
+
+	chunks := make([][]RAGChunk, len(changed))
+	for i, doc := range changed {
+		chunks[i] = r.prepareChunks(ctx, doc, previous[i])
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var result recordSyncResult
+	err := r.commit(func() {
+		for i, doc := range changed {
+			if j := r.indexOf(doc.ID); j >= 0 {
+				r.documents[j] = doc
+				r.recordVersion(ctx, VersionUpdated, doc)
+				result.Updated++
+			} else {
+				r.documents = append(r.documents, doc)
+				r.recordVersion(ctx, VersionCreated, doc)
+				result.Added++
+			}
+			r.setChunks(doc, chunks[i])
+		}
+		for _, id := range removed {
+			if j := r.indexOf(id); j >= 0 {
+				doc := r.documents[j]
+				r.documents = append(r.documents[:j:j], r.documents[j+1:]...)
+				r.setChunks(doc, nil)
+				r.recordVersion(ctx, VersionDeleted, doc)
+				result.Removed++
+			}
+		}
+	})
+	if err != nil {
+		return recordSyncResult{}, err
+	}
+	return result, nil
+}
+
+// sameRecordDocument reports whether regenerating a document changed
+// anything that's stored or indexed
+func sameRecordDocument(a, b RAGDocument) bool {
+	if a.Title != b.Title || a.Content != b.Content || a.Category != b.Category || a.Location != b.Location || a.Status != b.Status {
+		return false
+	}
+	if len(a.Tags) != len(b.Tags) {
+		return false
+	}
+	for i := range a.Tags {
+		if a.Tags[i] != b.Tags[i] {
+			return false
+		}
+	}
+	if !sameTime(a.PublishedAt, b.PublishedAt) {
+		return false
+	}
+	return a.Record != nil && b.Record != nil && *a.Record == *b.Record
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,395 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Import modes
+const (
+	// ImportMerge adds the imported documents to the store
+	ImportMerge = "merge"
+	// ImportReplace makes the store match the import, deleting documents
+	// it doesn't contain
+	ImportReplace = "replace"
+)
+
+// How a merge treats an imported document whose ID is already in use by
+// a different document
+const (
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+	ConflictRename    = "rename"
+)
+
+// maxImportLine bounds one JSONL line, which with embeddings can run to
+// megabytes for a long document
+const maxImportLine = 64 << 20
+
+// sourceHash is a file's SHA-256 as stored, which names its original on
+// disk, so anything else could point outside the files directory
+var sourceHash = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+var (
+	// ErrInvalidImport is returned when an import has invalid lines, in
+	// which case nothing is imported
+	ErrInvalidImport = errors.New("import has invalid documents")
+	// ErrImportOptions is returned for an unknown mode or conflict handling
+	ErrImportOptions = errors.New("invalid import options")
+)
+
+// ExportedDocument is one line of a JSONL export: the document as stored,
+// with its chunk vectors when they're exported
+type ExportedDocument struct {
+	RAGDocument
+	// Embedder names the embedder that computed Embeddings. Vectors are
+	// only reused by a store with the same embedder.
+	Embedder string `json:"embedder,omitempty"`
+	// Embeddings holds chunk vectors keyed by a hash of the text they
+	// were computed from, so they still match if chunking differs
+	Embeddings map[string][]float64 `json:"embeddings,omitempty"`
+}
+
+// ExportOptions selects what an export includes
+type ExportOptions struct {
+	// Embeddings includes chunk vectors
+	Embeddings bool
+	// Records includes documents generated from database records, which
+	// the target's own sync otherwise provides
+	Records bool
+	// Statuses limits the export to documents in these review statuses;
+	// empty exports every status
+	Statuses []string
+}
+
+// Export writes the store's documents as JSONL, one ExportedDocument per
+// line, returning how many it wrote
+func (r *RAGDatabase) Export(w io.Writer, options ExportOptions) (int, error) {
+	// Copy under the lock, then write without it so a slow client doesn't
+	// hold up writers
+	r.mu.RLock()
+	var docs []ExportedDocument
+	for _, doc := range r.documents {
+		if doc.Record != nil && !options.Records {
+			continue
+		}
+		if len(options.Statuses) > 0 && !contains(options.Statuses, doc.Status) {
+			continue
+		}
+		exported := ExportedDocument{RAGDocument: doc.clone()}
+		if options.Embeddings && r.embedder != nil {
+			for _, chunk := range r.chunks[doc.ID] {
+				if chunk.Embedding == nil {
+					continue
+				}
+				if exported.Embeddings == nil {
+					exported.Embedder = r.embedder.Name()
+					exported.Embeddings = make(map[string][]float64)
+				}
+				exported.Embeddings[embeddingKey(doc, chunk)] = chunk.Embedding
+			}
+		}
+		docs = append(docs, exported)
+	}
+	r.mu.RUnlock()
+
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	for i, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			return i, err
+		}
+	}
+	return len(docs), buf.Flush()
+}
+
+// ImportOptions controls how an import is applied
+type ImportOptions struct {
+	// Mode is merge (default) or replace
+	Mode string
+	// OnConflict is skip (default), overwrite or rename, for merges. A
+	// replace always overwrites.
+	OnConflict string
+	// DryRun validates the import and reports what it would change
+	// without changing anything
+	DryRun bool
+}
+
+// ImportError is an invalid line in an import
+type ImportError struct {
+	Line  int    `json:"line"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportReport lists what an import changed, or for a dry run would
+// change, by document ID
+type ImportReport struct {
+	Mode       string   `json:"mode"`
+	OnConflict string   `json:"on_conflict"`
+	DryRun     bool     `json:"dry_run"`
+	Created    []string `json:"created"`
+	Updated    []string `json:"updated"`
+	Unchanged  []string `json:"unchanged"`
+	Deleted    []string `json:"deleted"`
+	// Skipped are conflicting documents kept as they were, and imported
+	// record documents, which only a sync may write
+	Skipped []string `json:"skipped"`
+	// Renamed maps the imported ID of each renamed document to its new ID
+	Renamed map[string]string `json:"renamed,omitempty"`
+	Errors  []ImportError     `json:"errors"`
+}
+
+// Import reads a JSONL export and applies it to the store in one commit,
+// so it's saved and logged in the history as a whole or not at all.
+// Imported documents keep their review status, and their vectors are
+// reused when they were exported with the store's embedder. If any line is
+// invalid nothing is imported, and the report lists the errors.
+func (r *RAGDatabase) Import(ctx context.Context, input io.Reader, options ImportOptions) (ImportReport, error) {
+	if options.Mode == "" {
+		options.Mode = ImportMerge
+	}
+	if options.OnConflict == "" {
+		options.OnConflict = ConflictSkip
+	}
+	report := ImportReport{
+		Mode:       options.Mode,
+		OnConflict: options.OnConflict,
+		DryRun:     options.DryRun,
+		Created:    []string{},
+		Updated:    []string{},
+		Unchanged:  []string{},
+		Deleted:    []string{},
+		Skipped:    []string{},
+		Errors:     []ImportError{},
+	}
+	if options.Mode != ImportMerge && options.Mode != ImportReplace {
+		return report, fmt.Errorf("%w: unknown mode %q", ErrImportOptions, options.Mode)
+	}
+	if !contains([]string{ConflictSkip, ConflictOverwrite, ConflictRename}, options.OnConflict) {
+		return report, fmt.Errorf("%w: unknown conflict handling %q", ErrImportOptions, options.OnConflict)
+	}
+
+	imported, err := parseImport(input, &report)
+	if err != nil {
+		return report, err
+	}
+	if len(report.Errors) > 0 {
+		return report, ErrInvalidImport
+	}
+
+	// Plan against the current documents. Like record syncs, the plan is
+	// applied by ID after embedding, so a document edited meanwhile is
+	// overwritten rather than duplicated.
+	var changed []ExportedDocument
+	var previous [][]RAGChunk
+	var removed []string
+	r.mu.RLock()
+	keep := make(map[string]bool)
+	for _, doc := range imported {
+		keep[doc.ID] = true
+		i := r.indexOf(doc.ID)
+		switch {
+		case i < 0:
+			report.Created = append(report.Created, doc.ID)
+		case r.documents[i].Record != nil:
+			report.Skipped = append(report.Skipped, doc.ID)
+			continue
+		case sameImportedDocument(r.documents[i], doc.RAGDocument):
+			report.Unchanged = append(report.Unchanged, doc.ID)
+			continue
+		case options.Mode == ImportReplace || options.OnConflict == ConflictOverwrite:
+			report.Updated = append(report.Updated, doc.ID)
+			previous = append(previous, append([]RAGChunk(nil), r.chunks[doc.ID]...))
+			changed = append(changed, doc)
+			continue
+		case options.OnConflict == ConflictRename:
+			newID := "rag-" + uuid.New().String()
+			if report.Renamed == nil {
+				report.Renamed = make(map[string]string)
+			}
+			report.Renamed[doc.ID] = newID
+			report.Created = append(report.Created, newID)
+			doc.ID = newID
+		default:
+			report.Skipped = append(report.Skipped, doc.ID)
+			continue
+		}
+		previous = append(previous, nil)
+		changed = append(changed, doc)
+	}
+	if options.Mode == ImportReplace {
+		for _, doc := range r.documents {
+			if doc.Record == nil && !keep[doc.ID] {
+				removed = append(removed, doc.ID)
+			}
+		}
+		report.Deleted = append(report.Deleted, removed...)
+	}
+	r.mu.RUnlock()
+
+	if options.DryRun || len(changed) == 0 && len(removed) == 0 {
+		return report, nil
+	}
+
+	chunks := make([][]RAGChunk, len(changed))
+	for i, doc := range changed {
+		chunks[i] = r.importChunks(ctx, doc, previous[i])
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted []RAGDocument
+	err = r.commit(func() {
+		for i, exported := range changed {
+			doc := exported.RAGDocument
+			if j := r.indexOf(doc.ID); j >= 0 {
+				r.documents[j] = doc
+				r.recordVersion(ctx, VersionUpdated, doc)
+			} else {
+				r.documents = append(r.documents, doc)
+				r.recordVersion(ctx, VersionCreated, doc)
+			}
+			r.setChunks(doc, chunks[i])
+		}
+		for _, id := range removed {
+			if j := r.indexOf(id); j >= 0 {
+				doc := r.documents[j]
+				r.documents = append(r.documents[:j:j], r.documents[j+1:]...)
+				r.setChunks(doc, nil)
+				r.recordVersion(ctx, VersionDeleted, doc)
+				deleted = append(deleted, doc)
+			}
+		}
+	})
+	if err != nil {
+		return report, err
+	}
+	for _, doc := range deleted {
+		r.removeOriginal(doc.Source)
+	}
+	return report, nil
+}
+
+// parseImport reads and validates the JSONL lines of an import, adding
+// invalid lines and skipped record documents to report
+func parseImport(input io.Reader, report *ImportReport) ([]ExportedDocument, error) {
+	var docs []ExportedDocument
+	lines := make(map[string]int)
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxImportLine)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		fail := func(id, message string) {
+			report.Errors = append(report.Errors, ImportError{Line: line, ID: id, Error: message})
+		}
+
+		var doc ExportedDocument
+		if err := json.Unmarshal(text, &doc); err != nil {
+			fail("", err.Error())
+			continue
+		}
+		if doc.Record != nil {
+			report.Skipped = append(report.Skipped, doc.ID)
+			continue
+		}
+		if doc.ID != "" {
+			if first, ok := lines[doc.ID]; ok {
+				fail(doc.ID, fmt.Sprintf("duplicate ID, first used on line %d", first))
+				continue
+			}
+			lines[doc.ID] = line
+		}
+
+		if message := validateImported(&doc.RAGDocument); message != "" {
+			fail(doc.ID, message)
+			continue
+		}
+		// Documents written by hand may leave the ID to the store
+		if doc.ID == "" {
+			doc.ID = "rag-" + uuid.New().String()
+		}
+		docs = append(docs, doc)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// validateImported checks an imported document and fills the fields the
+// store sets itself, returning what's wrong with it, if anything. A
+// document without a status is imported as a draft.
+func validateImported(doc *RAGDocument) string {
+	var missing []string
+	for _, field := range []struct{ name, value string }{
+		{"title", doc.Title}, {"content", doc.Content}, {"category", doc.Category},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return "missing " + strings.Join(missing, ", ")
+	}
+	if doc.Status == "" {
+		doc.Status = StatusDraft
+	}
+	if !contains([]string{StatusDraft, StatusPendingReview, StatusPublished, StatusArchived}, doc.Status) {
+		return fmt.Sprintf("unknown status %q", doc.Status)
+	}
+	if doc.EffectiveFrom != nil && doc.ExpiresAt != nil && !doc.ExpiresAt.After(*doc.EffectiveFrom) {
+		return "expires_at must be after effective_from"
+	}
+	if doc.Source != nil {
+		if !sourceHash.MatchString(doc.Source.SHA256) {
+			return "source sha256 must be 64 lowercase hex characters"
+		}
+		doc.Source.FileName = filepath.Base(doc.Source.FileName)
+	}
+	if doc.Tags == nil {
+		doc.Tags = []string{}
+	}
+	doc.ContentHash = ContentHash(doc.Content)
+	return ""
+}
+
+// importChunks chunks and embeds an imported document, reusing its
+// exported vectors when they came from this store's embedder and the
+// vectors of the document it replaces
+func (r *RAGDatabase) importChunks(ctx context.Context, doc ExportedDocument, previous []RAGChunk) []RAGChunk {
+	chunks := chunkDocument(doc.RAGDocument, r.chunking)
+	if r.embedder == nil {
+		return chunks
+	}
+	if doc.Embedder == r.embedder.Name() {
+		for i := range chunks {
+			if vector, ok := doc.Embeddings[embeddingKey(doc.RAGDocument, chunks[i])]; ok {
+				chunks[i].Embedding = vector
+			}
+		}
+	}
+	r.embedChunks(ctx, doc.RAGDocument, chunks, append(previous, chunks...))
+	return chunks
+}
+
+// sameImportedDocument reports whether importing doc over existing would
+// change anything
+func sameImportedDocument(existing, doc RAGDocument) bool {
+	a, errA := json.Marshal(existing)
+	b, errB := json.Marshal(doc)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
@@ -1,85 +1,294 @@
 package ai
 
 import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"serpico/backend/internal/metrics"
+)
+
+// Built-in screener rules and training examples, copied to the screener
+// directory on first run so they can be tuned there
+//
+//go:embed screener/*
+var defaultScreener embed.FS
+
+const (
+	screenerRulesFile    = "screener.json"
+	screenerExamplesFile = "examples.jsonl"
+	screenerRejectedFile = "rejected.jsonl"
 )
 
-// PromptScreener filters out unwanted prompts before calling the AI API
+// maxLoggedPrompt caps how much of a rejected prompt is logged
+const maxLoggedPrompt = 1000
+
+// ScreenerConfig is the screener's rules file. Phrases match whole words,
+// ignoring case and word endings, so "case" matches "cases" but "hi"
+// doesn't match "this"; end a phrase with * to match any word starting
+// with its last word.
+type ScreenerConfig struct {
+	MinLength int `json:"min_length"`
+	// Keywords mark a prompt as on-topic
+	Keywords []string `json:"keywords"`
+	// BlockedPatterns are small talk, rejected unless the prompt is
+	// otherwise on-topic
+	BlockedPatterns []string `json:"blocked_patterns"`
+	// QuestionWords let a prompt without keywords through when it starts
+	// with one and the classifier can't tell
+	QuestionWords []string `json:"question_words"`
+	// JibberishPatterns are keyboard runs rejected wherever they appear,
+	// even inside a word
+	JibberishPatterns []string `json:"jibberish_patterns"`
+	Classifier        struct {
+		Enabled bool `json:"enabled"`
+		// Threshold is the on-topic probability a prompt without keywords
+		// needs to pass
+		Threshold float64 `json:"threshold"`
+	} `json:"classifier"`
+	// LogRejected appends rejected prompts to rejected.jsonl for tuning
+	LogRejected bool `json:"log_rejected"`
+}
+
+// ScreenDecision is the screener's verdict on a prompt, with how it got
+// there
+type ScreenDecision struct {
+	Allowed bool `json:"allowed"`
+	// Code is a stable rejection reason, also used for metrics
+	Code   string `json:"code,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// OnTopic is how the prompt was judged on-topic: keyword, classifier
+	// or question
+	OnTopic string `json:"on_topic,omitempty"`
+	// Score is the classifier's on-topic probability, when it was asked
+	Score *float64 `json:"score,omitempty"`
+	// Injection lists the prompt injection rules a rejected prompt matched
+	Injection []InjectionMatch `json:"injection,omitempty"`
+}
+
+// RejectedPrompt is an entry in the rejected prompt log
+type RejectedPrompt struct {
+	Timestamp time.Time `json:"timestamp"`
+	Mode      string    `json:"mode"`
+	Role      string    `json:"role,omitempty"`
+	// Prompt is redacted under the role's PII log policy
+	Prompt string `json:"prompt"`
+	ScreenDecision
+}
+
+// PromptScreener filters out unwanted prompts before calling the AI API.
+// Its rules and classifier are loaded from a directory and can be
+// reloaded while serving.
 type PromptScreener struct {
-	blockedPatterns []string
-	contextKeywords []string
+	mu         sync.RWMutex
+	config     ScreenerConfig
+	keywords   []screenPhrase
+	blocked    []screenPhrase
+	question   map[string]bool
+	classifier *naiveBayes
+	examples   int
+	dataPath   string
+	// pii redacts the rejected log; nil logs prompts as they are
+	pii *PIIGuard
+	// logMu serializes appends to the rejected log
+	logMu sync.Mutex
 }
 
-func NewPromptScreener() *PromptScreener {
-	return &PromptScreener{
-		blockedPatterns: []string{
-			"hello", "hi", "hey", "what's up", "how are you", "how's it going",
-			"tell me a joke", "sing a song", "what's the weather",
-			"random", "nonsense", "asdf", "qwerty", "test test test",
-		},
-		contextKeywords: []string{
-			"olathe", "police", "officer", "perp", "suspect", "case", "crime",
-			"pursuit", "arrest", "emergency", "dispatch", "patrol", "investigation",
-			"criminal", "robbery", "assault", "murder", "theft", "burglary",
-			"location", "address", "vehicle", "strategy", "history", "data",
-			"help", "assist", "recommend", "suggest", "query", "search", "find",
-		},
+func NewPromptScreener(dataPath string, pii *PIIGuard) (*PromptScreener, error) {
+	s := &PromptScreener{dataPath: dataPath, pii: pii}
+	if err := os.MkdirAll(dataPath, 0755); err != nil {
+		return nil, err
+	}
+	if err := s.seed(); err != nil {
+		return nil, fmt.Errorf("failed to seed screener rules: %w", err)
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// seed writes any built-in file that doesn't exist yet, leaving edited
+// files alone
+func (s *PromptScreener) seed() error {
+	entries, err := defaultScreener.ReadDir("screener")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		filePath := filepath.Join(s.dataPath, entry.Name())
+		if _, err := os.Stat(filePath); err == nil {
+			continue
+		}
+		data, err := defaultScreener.ReadFile("screener/" + entry.Name())
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filePath, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reload reads the rules and retrains the classifier from the examples.
+// On error the current rules stay in place.
+func (s *PromptScreener) Reload() error {
+	data, err := os.ReadFile(filepath.Join(s.dataPath, screenerRulesFile))
+	if err != nil {
+		return err
+	}
+	var config ScreenerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", screenerRulesFile, err)
+	}
+
+	var classifier *naiveBayes
+	var examples []ScreenerExample
+	if config.Classifier.Enabled {
+		data, err := os.ReadFile(filepath.Join(s.dataPath, screenerExamplesFile))
+		if err != nil {
+			return err
+		}
+		if examples, err = readScreenerExamples(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", screenerExamplesFile, err)
+		}
+		if classifier = trainNaiveBayes(examples); classifier == nil {
+			return fmt.Errorf("%s needs both %s and %s examples", screenerExamplesFile, LabelOnTopic, LabelOffTopic)
+		}
 	}
+
+	question := make(map[string]bool, len(config.QuestionWords))
+	for _, word := range config.QuestionWords {
+		question[strings.ToLower(word)] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config = config
+	s.keywords = compilePhrases(config.Keywords)
+	s.blocked = compilePhrases(config.BlockedPatterns)
+	s.question = question
+	s.classifier = classifier
+	s.examples = len(examples)
+	return nil
 }
 
-// ScreenPrompt checks if the prompt should be processed
+// Config returns the loaded rules and how many examples the classifier
+// was trained on
+func (s *PromptScreener) Config() (ScreenerConfig, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config, s.examples
+}
+
+// ScreenPrompt checks if the prompt should be processed under a chat
+// mode's rules, recording rejections in metrics and the rejected log
 // Returns: (shouldProcess, reason)
-func (s *PromptScreener) ScreenPrompt(prompt string) (bool, string) {
+func (s *PromptScreener) ScreenPrompt(prompt, role string, mode ChatMode) (bool, string) {
+	decision := s.Screen(prompt, mode.Screener)
+	if decision.Allowed {
+		return true, ""
+	}
+
+	metrics.ObserveScreenerRejection(decision.Code)
+	for _, match := range decision.Injection {
+		metrics.ObservePromptInjection(InjectionSourceUser, match.Rule)
+	}
+	s.logRejected(prompt, role, mode.Name, decision)
+	return false, decision.Reason
+}
+
+// Screen decides whether to process a prompt, applying a chat mode's
+// rules on top of the defaults. It has no side effects, so it can be used
+// to try out rules.
+func (s *PromptScreener) Screen(prompt string, rules ScreenerRules) ScreenDecision {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	promptLower := strings.ToLower(strings.TrimSpace(prompt))
+	words := screenWords(promptLower)
+	reject := func(code, reason string, decision ScreenDecision) ScreenDecision {
+		decision.Allowed = false
+		decision.Code = code
+		decision.Reason = reason
+		return decision
+	}
 
 	// Check for empty or very short prompts
-	if len(promptLower) < 3 {
-		return false, "Prompt too short"
+	if len([]rune(promptLower)) < s.config.MinLength {
+		return reject("too_short", "Prompt too short", ScreenDecision{})
 	}
 
-	// Check for blocked patterns (chitchat, jibberish)
-	for _, pattern := range s.blockedPatterns {
-		if strings.Contains(promptLower, pattern) {
-			// Check if it's just chitchat without context
-			if !s.hasContextKeywords(promptLower) {
-				return false, "Contains blocked pattern: " + pattern
-			}
-		}
+	// Attempts to override the assistant's instructions are refused
+	// whatever they're about
+	if matches := DetectInjection(prompt); len(matches) > 0 {
+		return reject("prompt_injection", "Attempts to override the assistant's instructions", ScreenDecision{Injection: matches})
+	}
+
+	// A mode's deny rules reject a prompt however on-topic it is
+	if phrase, ok := matchPhrases(words, compilePhrases(rules.Deny)); ok {
+		return reject("denied", "Not handled in this mode: "+phrase, ScreenDecision{})
 	}
 
-	// Check for context relevance
-	if !s.hasContextKeywords(promptLower) {
-		// Check if it's a valid question format
-		if !s.isValidQuestion(promptLower) {
-			return false, "No relevant context keywords found"
+	decision := s.onTopic(words, rules)
+
+	// Check for blocked patterns (chitchat) without other context
+	if decision.OnTopic == "" {
+		blocked := append(append([]screenPhrase(nil), s.blocked...), compilePhrases(rules.BlockedPatterns)...)
+		if phrase, ok := matchPhrases(words, blocked); ok {
+			return reject("blocked_pattern", "Contains blocked pattern: "+phrase, decision)
 		}
+		return reject("off_topic", "No relevant context found", decision)
 	}
 
 	// Check for jibberish (repeated characters, random strings)
 	if s.isJibberish(promptLower) {
-		return false, "Detected jibberish"
+		return reject("jibberish", "Detected jibberish", decision)
 	}
 
-	return true, ""
+	decision.Allowed = true
+	return decision
 }
 
-func (s *PromptScreener) hasContextKeywords(prompt string) bool {
-	for _, keyword := range s.contextKeywords {
-		if strings.Contains(prompt, keyword) {
-			return true
-		}
+// onTopic judges whether a prompt is about something the assistant
+// handles: by keywords first, then the classifier, and failing both by
+// whether it's phrased as a question. The caller must hold s.mu.
+func (s *PromptScreener) onTopic(words []screenWord, rules ScreenerRules) ScreenDecision {
+	if _, ok := matchPhrases(words, s.keywords); ok {
+		return ScreenDecision{OnTopic: "keyword"}
+	}
+	if _, ok := matchPhrases(words, compilePhrases(rules.Keywords)); ok {
+		return ScreenDecision{OnTopic: "keyword"}
 	}
-	return false
-}
 
-func (s *PromptScreener) isValidQuestion(prompt string) bool {
-	questionWords := []string{"what", "where", "when", "who", "why", "how", "which", "can", "could", "should", "is", "are", "do", "does", "did"}
-	for _, word := range questionWords {
-		if strings.HasPrefix(prompt, word+" ") {
-			return true
+	if s.classifier != nil {
+		// A greeting says nothing about the question that follows it
+		if score, ok := s.classifier.onTopic(withoutPhrases(words, s.blocked)); ok {
+			threshold := s.config.Classifier.Threshold
+			if rules.ClassifierThreshold > 0 {
+				threshold = rules.ClassifierThreshold
+			}
+			decision := ScreenDecision{Score: &score}
+			if score >= threshold {
+				decision.OnTopic = "classifier"
+			}
+			return decision
 		}
 	}
-	return false
+
+	if len(words) > 1 && s.question[words[0].raw] {
+		return ScreenDecision{OnTopic: "question"}
+	}
+	return ScreenDecision{}
 }
 
 func (s *PromptScreener) isJibberish(prompt string) bool {
@@ -99,9 +308,8 @@ func (s *PromptScreener) isJibberish(prompt string) bool {
 	}
 
 	// Check for random character sequences
-	randomPatterns := []string{"asdf", "qwerty", "zxcv", "hjkl", "12345", "abcde"}
-	for _, pattern := range randomPatterns {
-		if strings.Contains(prompt, pattern) {
+	for _, pattern := range s.config.JibberishPatterns {
+		if strings.Contains(prompt, strings.ToLower(pattern)) {
 			return true
 		}
 	}
@@ -109,3 +317,145 @@ func (s *PromptScreener) isJibberish(prompt string) bool {
 	return false
 }
 
+// logRejected appends a rejected prompt to the log, if it's enabled.
+// Failures are logged and otherwise ignored, as they shouldn't fail chat.
+func (s *PromptScreener) logRejected(prompt, role, mode string, decision ScreenDecision) {
+	s.mu.RLock()
+	enabled := s.config.LogRejected
+	s.mu.RUnlock()
+	if !enabled {
+		return
+	}
+
+	if s.pii != nil {
+		prompt = s.pii.Redact(role, PIIUseLog, prompt)
+	}
+	if runes := []rune(prompt); len(runes) > maxLoggedPrompt {
+		prompt = string(runes[:maxLoggedPrompt])
+	}
+	line, err := json.Marshal(RejectedPrompt{
+		Timestamp:      time.Now().UTC(),
+		Mode:           mode,
+		Role:           role,
+		Prompt:         prompt,
+		ScreenDecision: decision,
+	})
+	if err != nil {
+		slog.Warn("failed to encode rejected prompt", "error", err)
+		return
+	}
+
+	s.logMu.Lock()
+	defer s.logMu.Unlock()
+	if err := appendLogLine(filepath.Join(s.dataPath, screenerRejectedFile), line); err != nil {
+		slog.Warn("failed to write rejected prompt log", "error", err)
+	}
+}
+
+// Rejected returns the most recent rejected prompts, newest first
+func (s *PromptScreener) Rejected(limit int) ([]RejectedPrompt, error) {
+	s.logMu.Lock()
+	lines, err := tailLogLines(filepath.Join(s.dataPath, screenerRejectedFile), limit)
+	s.logMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	rejected := []RejectedPrompt{}
+	for i := len(lines) - 1; i >= 0; i-- {
+		var entry RejectedPrompt
+		// Skip a line cut short by a crash mid-write
+		if err := json.Unmarshal(lines[i], &entry); err == nil {
+			rejected = append(rejected, entry)
+		}
+	}
+	return rejected, nil
+}
+
+// screenWord is a prompt word as typed and stemmed
+type screenWord struct {
+	raw  string
+	stem string
+}
+
+func screenWords(text string) []screenWord {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	words := make([]screenWord, len(fields))
+	for i, field := range fields {
+		words[i] = screenWord{raw: field, stem: stem(field)}
+	}
+	return words
+}
+
+// screenPhrase is a rule phrase compiled for matching
+type screenPhrase struct {
+	text  string
+	words []screenWord
+	// prefix matches the last word as a prefix, for phrases ending in *
+	prefix bool
+}
+
+func compilePhrases(phrases []string) []screenPhrase {
+	compiled := make([]screenPhrase, 0, len(phrases))
+	for _, text := range phrases {
+		phrase := screenPhrase{text: text}
+		trimmed := strings.TrimSpace(text)
+		if strings.HasSuffix(trimmed, "*") {
+			phrase.prefix = true
+			trimmed = strings.TrimSuffix(trimmed, "*")
+		}
+		if phrase.words = screenWords(trimmed); len(phrase.words) > 0 {
+			compiled = append(compiled, phrase)
+		}
+	}
+	return compiled
+}
+
+// matchPhrases returns the first phrase found in words
+func matchPhrases(words []screenWord, phrases []screenPhrase) (string, bool) {
+	for _, phrase := range phrases {
+		n := len(phrase.words)
+		for start := 0; start+n <= len(words); start++ {
+			if phrase.matchesAt(words[start : start+n]) {
+				return phrase.text, true
+			}
+		}
+	}
+	return "", false
+}
+
+// withoutPhrases returns words with any occurrence of phrases removed
+func withoutPhrases(words []screenWord, phrases []screenPhrase) []screenWord {
+	kept := make([]screenWord, 0, len(words))
+	for start := 0; start < len(words); {
+		skip := 0
+		for _, phrase := range phrases {
+			n := len(phrase.words)
+			if n > skip && start+n <= len(words) && phrase.matchesAt(words[start:start+n]) {
+				skip = n
+			}
+		}
+		if skip == 0 {
+			kept = append(kept, words[start])
+			skip = 1
+		}
+		start += skip
+	}
+	return kept
+}
+
+func (p screenPhrase) matchesAt(words []screenWord) bool {
+	last := len(p.words) - 1
+	for i, want := range p.words {
+		if i == last && p.prefix {
+			if !strings.HasPrefix(words[i].raw, want.raw) {
+				return false
+			}
+		} else if words[i].stem != want.stem {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Labels for screener training examples
+const (
+	LabelOnTopic  = "on_topic"
+	LabelOffTopic = "off_topic"
+)
+
+// ScreenerExample is a labelled prompt the classifier learns from
+type ScreenerExample struct {
+	Text  string `json:"text"`
+	Label string `json:"label"`
+}
+
+// naiveBayes is a multinomial naive Bayes classifier over stemmed words,
+// telling on-topic prompts from off-topic ones. It's small enough to
+// retrain from the examples file at every load.
+type naiveBayes struct {
+	// counts holds how often each word appears in each class, and totals
+	// the number of words per class
+	counts map[string][2]int
+	totals [2]int
+	// docs is the number of examples per class, for the priors
+	docs [2]int
+}
+
+// readScreenerExamples reads labelled examples, one JSON object per line
+func readScreenerExamples(r io.Reader) ([]ScreenerExample, error) {
+	var examples []ScreenerExample
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var example ScreenerExample
+		if err := json.Unmarshal(text, &example); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if example.Label != LabelOnTopic && example.Label != LabelOffTopic {
+			return nil, fmt.Errorf("line %d: label must be %s or %s", line, LabelOnTopic, LabelOffTopic)
+		}
+		examples = append(examples, example)
+	}
+	return examples, scanner.Err()
+}
+
+// trainNaiveBayes fits a classifier to examples. It returns nil unless
+// both classes have examples.
+func trainNaiveBayes(examples []ScreenerExample) *naiveBayes {
+	nb := &naiveBayes{counts: make(map[string][2]int)}
+	for _, example := range examples {
+		class := 0
+		if example.Label == LabelOnTopic {
+			class = 1
+		}
+		nb.docs[class]++
+		for _, word := range screenWords(example.Text) {
+			counts := nb.counts[word.stem]
+			counts[class]++
+			nb.counts[word.stem] = counts
+			nb.totals[class]++
+		}
+	}
+	if nb.docs[0] == 0 || nb.docs[1] == 0 {
+		return nil
+	}
+	return nb
+}
+
+// onTopic returns the probability that words are on-topic. ok is false
+// when none of the words were seen in training, as the answer would only
+// be the prior.
+func (nb *naiveBayes) onTopic(words []screenWord) (probability float64, ok bool) {
+	vocabulary := float64(len(nb.counts))
+	var logProb [2]float64
+	for class := range logProb {
+		logProb[class] = math.Log(float64(nb.docs[class]) / float64(nb.docs[0]+nb.docs[1]))
+	}
+	for _, word := range words {
+		counts, seen := nb.counts[word.stem]
+		if !seen {
+			continue
+		}
+		ok = true
+		for class := range logProb {
+			// Laplace smoothing, so a word seen in one class only doesn't
+			// rule out the other
+			logProb[class] += math.Log((float64(counts[class]) + 1) / (float64(nb.totals[class]) + vocabulary))
+		}
+	}
+	return 1 / (1 + math.Exp(logProb[0]-logProb[1])), ok
+}
@@ -1,83 +1,500 @@
 package ai
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
+
+	"serpico/backend/internal/database"
 )
 
 // AIService coordinates all AI functionality
 type AIService struct {
-	config      *Config
-	gemini      *GeminiClient
-	rag         *RAGDatabase
-	webSearch   *WebSearchTool
-	screener    *PromptScreener
+	config    *Config
+	llm       LLMProvider
+	rag       *RAGDatabase
+	webSearch *WebSearchTool
+	screener  *PromptScreener
+	pii       *PIIGuard
+	output    *OutputGuard
+	tools     *ToolRegistry
+	modes     *ChatModeStore
 }
 
-func NewAIService(config *Config) (*AIService, error) {
-	gemini := NewGeminiClient(config.GeminiAPIKey, config.GeminiModel)
-	
-	rag, err := NewRAGDatabase(config.RAGDataPath)
+// NewAIService builds the service. tools may be nil to disable function
+// calling, and db nil to skip checking answers for perp and officer
+// details.
+func NewAIService(config *Config, tools *ToolRegistry, db *database.Database) (*AIService, error) {
+	llm, err := NewProvider(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize RAG database: %w", err)
+		return nil, fmt.Errorf("failed to initialize LLM provider: %w", err)
+	}
+
+	rag, err := OpenRAGDatabase(config, config.RAGDataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	modes, err := NewChatModeStore(config.ChatModesPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat modes: %w", err)
+	}
+
+	pii, err := NewPIIGuard(config.PIIPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load PII policy: %w", err)
 	}
 
 	webSearch := NewWebSearchTool(config.EnableWebSearch)
-	screener := NewPromptScreener()
+	screener, err := NewPromptScreener(config.ScreenerPath, pii)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load prompt screener: %w", err)
+	}
+
+	output, err := NewOutputGuard(config.SafetyPath, rag, db, pii)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load output safety rules: %w", err)
+	}
 
 	return &AIService{
 		config:    config,
-		gemini:    gemini,
+		llm:       llm,
 		rag:       rag,
 		webSearch: webSearch,
 		screener:  screener,
+		pii:       pii,
+		output:    output,
+		tools:     tools,
+		modes:     modes,
 	}, nil
 }
 
+// OpenRAGDatabase opens the document store at dataPath with the chunking,
+// freshness and embedding settings in config
+func OpenRAGDatabase(config *Config, dataPath string) (*RAGDatabase, error) {
+	embedder, err := NewEmbedder(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize embedding provider: %w", err)
+	}
+
+	rag, err := NewRAGDatabase(dataPath, ChunkConfig{
+		Tokens:  config.RAGChunkTokens,
+		Overlap: config.RAGChunkOverlap,
+	}, FreshnessConfig{
+		RecencyWeight: config.RAGRecencyWeight,
+		HalfLife:      config.RAGRecencyHalfLife,
+		StaleAfter:    config.RAGStaleAfter,
+	}, embedder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize RAG database: %w", err)
+	}
+	return rag, nil
+}
+
+// toolsPrompt is added to the system prompt when tools are offered
+const toolsPrompt = `You can call functions to look up live department data such as cases, perps, on-duty officers, active emergencies and crime statistics. Use them when the question needs current records rather than general guidance.`
+
+// maxToolSteps bounds how many rounds of tool calls one chat turn may make
+const maxToolSteps = 4
+
+// ChatInput is a single chat turn with optional prior conversation
+type ChatInput struct {
+	Message string
+	Context string
+	// Mode names the chat mode; resolve it with ChatModeStore.Resolve first
+	Mode string
+	// Role decides which tools the model may call; empty means civilian
+	Role string
+	// History holds earlier turns, oldest first, excluding Message
+	History []Message
+}
+
+// ChatResult is the outcome of a chat turn
+type ChatResult struct {
+	Content string
+	// Filtered is set when the screener rejected the message
+	Filtered bool
+	Provider string
+	Model    string
+	Usage    Usage
+	// Sources is the context the model was given, with Citations mapping
+	// the answer's inline markers to it
+	Sources   []Source
+	Citations []Citation
+	// NoContext is set when neither knowledge base documents nor database
+	// lookups backed the answer. Web results alone don't count as they
+	// aren't department records.
+	NoContext bool
+	// ToolCalls lists the functions run to produce the answer
+	ToolCalls []ToolCall
+	// Safety is set when the output safety filter blocked or amended the
+	// answer
+	Safety *SafetyReview
+}
+
+// chatTurn is a screened message with its retrieved context, ready to send
+type chatTurn struct {
+	request    GenerateRequest
+	ragResults []contextDoc
+	sources    []Source
+	// tools the mode may call, nil for all
+	tools []string
+	// vault holds the placeholders for personal information in request
+	vault *piiVault
+}
+
 // ProcessChat handles a chat message and returns AI response
-func (s *AIService) ProcessChat(userMessage string, context string) (string, error) {
+func (s *AIService) ProcessChat(ctx context.Context, input ChatInput) (*ChatResult, error) {
+	ctx = withChatRole(ctx, input.Role)
+	turn, filtered := s.prepareChat(ctx, input)
+	if filtered != nil {
+		return filtered, nil
+	}
+
+	// Step 4: Generate response using the configured LLM provider(s)
+	response, toolCalls, err := s.runAgent(ctx, input.Role, turn, func(req GenerateRequest) (*GenerateResponse, error) {
+		return s.llm.Generate(ctx, req)
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "LLM generation failed", "provider", s.llm.Name(), "error", err)
+		// Fallback response
+		result := s.fallbackResult(input.Message, turn)
+		s.reviewAnswer(ctx, input, result)
+		return result, nil
+	}
+
+	result := newChatResult(response, turn, toolCalls)
+	s.reviewAnswer(ctx, input, result)
+	return result, nil
+}
+
+// ProcessChatStream is ProcessChat with the answer relayed to onChunk as it
+// is generated. It stops with the context's error if ctx is cancelled.
+// Answers for roles the output safety filter restricts are held back and
+// sent in one chunk once checked, as a blocked answer can't be unsent;
+// other roles get any cautions and disclaimers in a last chunk.
+func (s *AIService) ProcessChatStream(ctx context.Context, input ChatInput, onChunk StreamFunc) (*ChatResult, error) {
+	ctx = withChatRole(ctx, input.Role)
+	turn, filtered := s.prepareChat(ctx, input)
+	if filtered != nil {
+		return filtered, onChunk(filtered.Content)
+	}
+
+	held := s.output.Restricts(input.Role)
+	send := onChunk
+	if held {
+		send = func(string) error { return ctx.Err() }
+	}
+	restorer := turn.vault.restorer(send)
+	streamed := false
+	response, toolCalls, err := s.runAgent(ctx, input.Role, turn, func(req GenerateRequest) (*GenerateResponse, error) {
+		return GenerateStream(ctx, s.llm, req, func(chunk string) error {
+			streamed = true
+			return restorer.write(chunk)
+		})
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		slog.ErrorContext(ctx, "LLM generation failed", "provider", s.llm.Name(), "error", err)
+		// A partial answer can't be replaced with the fallback
+		if streamed {
+			return nil, err
+		}
+		result := s.fallbackResult(input.Message, turn)
+		s.reviewAnswer(ctx, input, result)
+		return result, onChunk(result.Content)
+	}
+	if err := restorer.flush(); err != nil {
+		return nil, err
+	}
+
+	result := newChatResult(response, turn, toolCalls)
+	appended := s.reviewAnswer(ctx, input, result)
+	switch {
+	case held:
+		err = onChunk(result.Content)
+	case appended != "":
+		err = onChunk(appended)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// reviewAnswer runs a generated answer through the output safety filter,
+// replacing or amending result's content. A blocked answer loses its
+// sources and tool calls too, as they can name what was withheld. It
+// returns the text appended to the answer.
+func (s *AIService) reviewAnswer(ctx context.Context, input ChatInput, result *ChatResult) string {
+	review := s.output.ReviewAnswer(ctx, input.Role, input.Mode, input.Message, result.Content)
+	if review.Action == SafetyAllowed {
+		return ""
+	}
+	result.Safety = &review
+	result.Content = review.Content
+	if review.Action == SafetyBlocked {
+		result.Sources = nil
+		result.Citations = nil
+		result.ToolCalls = nil
+	}
+	return review.Appended
+}
+
+// runAgent generates an answer, running any tools the model calls and
+// feeding their results back until it answers in text. After maxToolSteps
+// rounds it makes one last request without tools to force an answer.
+func (s *AIService) runAgent(ctx context.Context, role string, turn *chatTurn, generate func(GenerateRequest) (*GenerateResponse, error)) (*GenerateResponse, []ToolCall, error) {
+	if role == "" {
+		role = ChatRoleCivilian
+	}
+	req := turn.request
+	if s.config.EnableTools {
+		req.Tools = s.tools.For(role, turn.tools)
+	}
+	if len(req.Tools) > 0 {
+		req.SystemPrompt += "\n\n" + toolsPrompt
+	}
+
+	var usage Usage
+	var toolCalls []ToolCall
+	piiNoted := false
+	for step := 0; ; step++ {
+		if step == maxToolSteps {
+			req.Tools = nil
+		}
+		// Tool results can bring the first placeholders
+		if turn.vault.used && !piiNoted {
+			req.SystemPrompt += "\n\n" + piiPrompt
+			piiNoted = true
+		}
+
+		response, err := generate(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		usage.PromptTokens += response.Usage.PromptTokens
+		usage.CompletionTokens += response.Usage.CompletionTokens
+
+		if len(response.ToolCalls) == 0 || len(req.Tools) == 0 {
+			response.Usage = usage
+			return response, toolCalls, nil
+		}
+
+		req.Messages = append(req.Messages, Message{
+			Role:      RoleAssistant,
+			Content:   response.Text,
+			ToolCalls: response.ToolCalls,
+		})
+		for _, call := range response.ToolCalls {
+			// Lookups run on the real values, and what they return is
+			// protected like the rest of the conversation
+			call.Arguments = turn.vault.restoreArguments(call.Arguments)
+			slog.DebugContext(ctx, "running tool", "tool", call.Name, "role", role)
+			result := s.tools.Execute(ctx, role, turn.tools, call)
+			result.Content = turn.vault.protect(result.Content)
+			req.Messages = append(req.Messages, result)
+			toolCalls = append(toolCalls, call)
+		}
+	}
+}
+
+// prepareChat screens the message and gathers context. It returns a
+// result instead of a turn when the message was filtered.
+func (s *AIService) prepareChat(ctx context.Context, input ChatInput) (*chatTurn, *ChatResult) {
+	userMessage := input.Message
+	mode, ok := s.modes.Get(input.Mode)
+	if !ok {
+		mode, _ = s.modes.Get(DefaultChatMode)
+	}
+
 	// Step 1: Screen the prompt
-	shouldProcess, reason := s.screener.ScreenPrompt(userMessage)
+	shouldProcess, reason := s.screener.ScreenPrompt(userMessage, input.Role, mode)
 	if !shouldProcess {
-		return fmt.Sprintf("I'm here to help with Olathe PD related questions. Your message was filtered: %s. Please ask about crime data, pursuit strategies, case information, or officer assistance.", reason), nil
+		return nil, &ChatResult{
+			Content:  fmt.Sprintf("I'm here to help with Olathe PD related questions. Your message was filtered: %s. Please ask about crime data, pursuit strategies, case information, or officer assistance.", reason),
+			Filtered: true,
+		}
 	}
 
-	// Step 2: Search RAG database
-	ragResults := s.rag.Search(userMessage+" "+context, 5)
-	log.Printf("RAG search returned %d results", len(ragResults))
+	// Everything from here on may reach a provider, so personal
+	// information is swapped for placeholders first
+	vault := s.pii.newVault(input.Role)
+
+	// Step 2: Search RAG database, including the previous question so
+	// follow-ups like "what about last year?" still retrieve context
+	history, summary := trimHistory(withoutInjectedHistory(ctx, input.History), s.config.HistoryTokenBudget)
+	ragQuery := userMessage + " " + input.Context
+	if prev := lastUserMessage(history); prev != "" {
+		ragQuery += " " + prev
+	}
+	// Keyword search is local, so only the embedded query is protected
+	scored := s.rag.searchScored(ctx, ragQuery, vault.protect(ragQuery), ragSearchChunks, SearchFilter{Categories: mode.RAGCategories, Role: defaultRole(input.Role)})
+	ragResults := packContext(withoutInjectedChunks(ctx, scored), s.config.RAGContextTokenBudget)
+	slog.DebugContext(ctx, "RAG search complete", "mode", mode.Name, "chunks", len(scored), "documents", len(ragResults))
 
 	// Step 3: Perform web search if enabled
 	var webResult string
 	if s.config.EnableWebSearch {
-		result, err := s.webSearch.Search(userMessage)
+		result, err := s.webSearch.Search(vault.protect(userMessage))
 		if err != nil {
-			log.Printf("Web search error: %v", err)
+			slog.WarnContext(ctx, "web search failed", "error", err)
 			webResult = ""
+		} else if matches := screenInjection(result, InjectionSourceWeb); len(matches) > 0 {
+			slog.WarnContext(ctx, "dropped web result with prompt injection", "rule", matches[0].Rule)
 		} else {
 			webResult = result
 		}
 	}
 
-	// Step 4: Generate response using Gemini
-	response, err := s.gemini.GenerateResponse(userMessage, ragResults, webResult)
-	if err != nil {
-		log.Printf("Gemini API error: %v", err)
-		// Fallback response
-		return s.generateFallbackResponse(userMessage, ragResults), nil
+	sources := sourcesFor(ragResults, webResult)
+	system := mode.SystemPrompt + "\n\n" + untrustedPrompt
+	if len(sources) > 0 {
+		system += "\n\n" + citationPrompt
 	}
+	messages := make([]Message, 0, len(history)+1)
+	messages = append(messages, vault.protectMessages(history)...)
+	messages = append(messages, Message{Role: RoleUser, Content: vault.protect(buildPrompt(userMessage, summary, ragResults, webResult))})
 
-	return response, nil
+	return &chatTurn{
+		request: GenerateRequest{
+			SystemPrompt: system,
+			Messages:     messages,
+		},
+		ragResults: ragResults,
+		sources:    sources,
+		tools:      mode.Tools,
+		vault:      vault,
+	}, nil
+}
+
+func newChatResult(response *GenerateResponse, turn *chatTurn, toolCalls []ToolCall) *ChatResult {
+	sources := append([]Source(nil), turn.sources...)
+	content := turn.vault.restore(response.Text)
+	return &ChatResult{
+		Content:   content,
+		Provider:  response.Provider,
+		Model:     response.Model,
+		Usage:     response.Usage,
+		Sources:   sources,
+		Citations: resolveCitations(content, sources),
+		NoContext: len(turn.ragResults) == 0 && len(toolCalls) == 0,
+		ToolCalls: toolCalls,
+	}
+}
+
+func lastUserMessage(history []Message) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == RoleUser {
+			return history[i].Content
+		}
+	}
+	return ""
 }
 
-func (s *AIService) generateFallbackResponse(query string, ragDocs []RAGDocument) string {
+// buildPrompt wraps the user's question with retrieved context and the
+// summary of earlier questions trimmed from the history. Web results are
+// numbered after the documents so they can be cited too. Each untrusted
+// part goes inside the tags untrustedPrompt describes.
+func buildPrompt(userMessage, summary string, ragDocs []contextDoc, webSearchResult string) string {
+	webResults := "None"
+	if webSearchResult != "" {
+		webResults = fmt.Sprintf("<web_results>\n[%d] %s\n</web_results>", len(ragDocs)+1, escapeUntrusted(webSearchResult))
+	}
+	// The summary quotes earlier questions word for word, so it's no more
+	// trusted than the question
+	earlier := ""
+	if summary != "" {
+		earlier = fmt.Sprintf("<conversation_summary>\n%s\n</conversation_summary>\n\n", escapeUntrusted(strings.TrimSpace(summary)))
+	}
+	return earlier + fmt.Sprintf(`Context from knowledge base:
+%s
+
+Web search results:
+%s
+
+User question:
+<user_question>
+%s
+</user_question>`, buildContext(ragDocs, webSearchResult), webResults, escapeUntrusted(userMessage))
+}
+
+// buildContext lists the packed documents, each with its retrieved chunks
+// in document order, inside <document> tags
+func buildContext(ragDocs []contextDoc, webSearch string) string {
+	if len(ragDocs) == 0 && webSearch == "" {
+		return "No relevant context found."
+	}
+
+	var context strings.Builder
+	context.WriteString("Relevant information:\n\n")
+
+	for i, packed := range ragDocs {
+		doc := packed.Document
+		context.WriteString("<document>\n")
+		context.WriteString(fmt.Sprintf("[%d] %s\n", i+1, escapeUntrusted(doc.Title)))
+		context.WriteString(fmt.Sprintf("Category: %s\n", escapeUntrusted(doc.Category)))
+		if doc.Location != "" {
+			context.WriteString(fmt.Sprintf("Location: %s\n", escapeUntrusted(doc.Location)))
+		}
+		context.WriteString(fmt.Sprintf("Content: %s\n", escapeUntrusted(joinChunks(packed.Chunks))))
+		context.WriteString("</document>\n\n")
+	}
+
+	return context.String()
+}
+
+func (s *AIService) fallbackResult(query string, turn *chatTurn) *ChatResult {
+	result := &ChatResult{
+		Content:   s.generateFallbackResponse(query, turn.ragResults),
+		Provider:  "fallback",
+		NoContext: len(turn.ragResults) == 0,
+	}
+	// The fallback quotes only the top document
+	if len(turn.ragResults) > 0 {
+		source := turn.sources[0]
+		source.Cited = true
+		result.Sources = []Source{source}
+	}
+	return result
+}
+
+func (s *AIService) generateFallbackResponse(query string, ragDocs []contextDoc) string {
 	if len(ragDocs) > 0 {
-		return fmt.Sprintf("Based on Olathe PD records: %s\n\nFor more information, please consult the case files or contact dispatch.", ragDocs[0].Content)
+		return fmt.Sprintf("Based on Olathe PD records: %s\n\nFor more information, please consult the case files or contact dispatch.", joinChunks(ragDocs[0].Chunks))
 	}
 	return "I'm having trouble processing your request right now. Please try rephrasing your question about Olathe PD operations, crime data, or pursuit strategies."
 }
 
+// CheckProvider verifies the LLM provider is reachable
+func (s *AIService) CheckProvider(ctx context.Context) error {
+	return s.llm.Ping(ctx)
+}
+
+// Modes returns the chat mode store
+func (s *AIService) Modes() *ChatModeStore {
+	return s.modes
+}
+
+// Screener returns the prompt screener
+func (s *AIService) Screener() *PromptScreener {
+	return s.screener
+}
+
+// PII returns the PII guard
+func (s *AIService) PII() *PIIGuard {
+	return s.pii
+}
+
+// Output returns the output safety filter
+func (s *AIService) Output() *OutputGuard {
+	return s.output
+}
+
 // GetRAGDatabase returns the RAG database for direct access
 func (s *AIService) GetRAGDatabase() *RAGDatabase {
 	return s.rag
 }
-
@@ -0,0 +1,224 @@
+package ai
+
+import "strings"
+
+// stem reduces an English word to its Porter stem, e.g. "robberies" and
+// "robbery" both become "robberi", so inflections match each other.
+// word must be lowercase.
+func stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	w := []byte(word)
+	w = stemStep1a(w)
+	w = stemStep1b(w)
+	w = stemStep1c(w)
+	w = stemStep2(w)
+	w = stemStep3(w)
+	w = stemStep4(w)
+	w = stemStep5(w)
+	return string(w)
+}
+
+// isConsonant reports whether w[i] is a consonant in Porter's sense: a
+// letter other than a vowel, and "y" only when it follows a vowel
+func isConsonant(w []byte, i int) bool {
+	switch w[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !isConsonant(w, i-1)
+	}
+	return true
+}
+
+// measure counts the vowel-consonant sequences in w, Porter's m
+func measure(w []byte) int {
+	m := 0
+	i := 0
+	for i < len(w) && isConsonant(w, i) {
+		i++
+	}
+	for i < len(w) {
+		for i < len(w) && !isConsonant(w, i) {
+			i++
+		}
+		if i == len(w) {
+			break
+		}
+		for i < len(w) && isConsonant(w, i) {
+			i++
+		}
+		m++
+	}
+	return m
+}
+
+func hasVowel(w []byte) bool {
+	for i := range w {
+		if !isConsonant(w, i) {
+			return true
+		}
+	}
+	return false
+}
+
+func endsDoubleConsonant(w []byte) bool {
+	n := len(w)
+	return n >= 2 && w[n-1] == w[n-2] && isConsonant(w, n-1)
+}
+
+// endsCVC reports a consonant-vowel-consonant ending where the last
+// consonant isn't w, x or y, as in "hop"
+func endsCVC(w []byte) bool {
+	n := len(w)
+	if n < 3 || !isConsonant(w, n-3) || isConsonant(w, n-2) || !isConsonant(w, n-1) {
+		return false
+	}
+	c := w[n-1]
+	return c != 'w' && c != 'x' && c != 'y'
+}
+
+func hasSuffix(w []byte, suffix string) bool {
+	return strings.HasSuffix(string(w), suffix)
+}
+
+// replaceSuffix swaps suffix for replacement when the remaining stem has a
+// measure above minMeasure. It reports whether the suffix matched at all.
+func replaceSuffix(w *[]byte, suffix, replacement string, minMeasure int) bool {
+	if !hasSuffix(*w, suffix) {
+		return false
+	}
+	stem := (*w)[:len(*w)-len(suffix)]
+	if measure(stem) > minMeasure {
+		*w = append(stem[:len(stem):len(stem)], replacement...)
+	}
+	return true
+}
+
+func stemStep1a(w []byte) []byte {
+	switch {
+	case hasSuffix(w, "sses"):
+		return w[:len(w)-2]
+	case hasSuffix(w, "ies"):
+		return w[:len(w)-2]
+	case hasSuffix(w, "ss"):
+		return w
+	case hasSuffix(w, "s"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+func stemStep1b(w []byte) []byte {
+	if hasSuffix(w, "eed") {
+		if measure(w[:len(w)-3]) > 0 {
+			return w[:len(w)-1]
+		}
+		return w
+	}
+
+	var stem []byte
+	switch {
+	case hasSuffix(w, "ed") && hasVowel(w[:len(w)-2]):
+		stem = w[:len(w)-2]
+	case hasSuffix(w, "ing") && hasVowel(w[:len(w)-3]):
+		stem = w[:len(w)-3]
+	default:
+		return w
+	}
+
+	switch {
+	case hasSuffix(stem, "at"), hasSuffix(stem, "bl"), hasSuffix(stem, "iz"):
+		return append(stem[:len(stem):len(stem)], 'e')
+	case endsDoubleConsonant(stem):
+		last := stem[len(stem)-1]
+		if last != 'l' && last != 's' && last != 'z' {
+			return stem[:len(stem)-1]
+		}
+	case measure(stem) == 1 && endsCVC(stem):
+		return append(stem[:len(stem):len(stem)], 'e')
+	}
+	return stem
+}
+
+func stemStep1c(w []byte) []byte {
+	if hasSuffix(w, "y") && hasVowel(w[:len(w)-1]) {
+		out := append([]byte{}, w...)
+		out[len(out)-1] = 'i'
+		return out
+	}
+	return w
+}
+
+var stemStep2Suffixes = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"abli", "able"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+}
+
+func stemStep2(w []byte) []byte {
+	for _, s := range stemStep2Suffixes {
+		if replaceSuffix(&w, s[0], s[1], 0) {
+			break
+		}
+	}
+	return w
+}
+
+var stemStep3Suffixes = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+func stemStep3(w []byte) []byte {
+	for _, s := range stemStep3Suffixes {
+		if replaceSuffix(&w, s[0], s[1], 0) {
+			break
+		}
+	}
+	return w
+}
+
+var stemStep4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+func stemStep4(w []byte) []byte {
+	// Longest match first, as "ement" must win over "ment" and "ent"
+	best := ""
+	for _, suffix := range stemStep4Suffixes {
+		if len(suffix) > len(best) && hasSuffix(w, suffix) {
+			best = suffix
+		}
+	}
+	if best == "" {
+		return w
+	}
+
+	stem := w[:len(w)-len(best)]
+	if measure(stem) <= 1 {
+		return w
+	}
+	if best == "ion" && !(hasSuffix(stem, "s") || hasSuffix(stem, "t")) {
+		return w
+	}
+	return stem
+}
+
+func stemStep5(w []byte) []byte {
+	if hasSuffix(w, "e") {
+		stem := w[:len(w)-1]
+		m := measure(stem)
+		if m > 1 || (m == 1 && !endsCVC(stem)) {
+			w = stem
+		}
+	}
+	if measure(w) > 1 && endsDoubleConsonant(w) && hasSuffix(w, "l") {
+		w = w[:len(w)-1]
+	}
+	return w
+}
@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// StreamFunc receives generated text as it arrives. Returning an error
+// stops generation, e.g. when the client has disconnected.
+type StreamFunc func(chunk string) error
+
+// StreamingProvider is an LLMProvider that can emit text incrementally
+type StreamingProvider interface {
+	LLMProvider
+	GenerateStream(ctx context.Context, req GenerateRequest, onChunk StreamFunc) (*GenerateResponse, error)
+}
+
+// GenerateStream streams from p when it supports streaming, and otherwise
+// delivers the complete answer as a single chunk
+func GenerateStream(ctx context.Context, p LLMProvider, req GenerateRequest, onChunk StreamFunc) (*GenerateResponse, error) {
+	if sp, ok := p.(StreamingProvider); ok {
+		return sp.GenerateStream(ctx, req, onChunk)
+	}
+
+	resp, err := p.Generate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := onChunk(resp.Text); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GenerateStream tries each provider in order, but only falls back while
+// nothing has been streamed yet; a partial answer can't be retracted
+func (f *FallbackProvider) GenerateStream(ctx context.Context, req GenerateRequest, onChunk StreamFunc) (*GenerateResponse, error) {
+	var errs []error
+	for _, p := range f.providers {
+		streamed := false
+		resp, err := GenerateStream(ctx, p, req, func(chunk string) error {
+			streamed = true
+			return onChunk(chunk)
+		})
+		if err == nil {
+			return resp, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+
+		if streamed || ctx.Err() != nil {
+			break
+		}
+		slog.WarnContext(ctx, "LLM provider failed, trying next", "provider", p.Name(), "error", err)
+	}
+	return nil, fmt.Errorf("all LLM providers failed: %w", errors.Join(errs...))
+}
+
+// errStopSSE ends readSSE early without reporting an error
+var errStopSSE = errors.New("stop")
+
+// readSSE calls fn with the data of each server-sent event in r
+func readSSE(r io.Reader, fn func(data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var data bytes.Buffer
+	flush := func() error {
+		if data.Len() == 0 {
+			return nil
+		}
+		err := fn(data.Bytes())
+		data.Reset()
+		return err
+	}
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		switch {
+		case len(line) == 0:
+			if err := flush(); err != nil {
+				return stopOrErr(err)
+			}
+		case bytes.HasPrefix(line, []byte("data:")):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(bytes.TrimSpace(line[len("data:"):]))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return stopOrErr(flush())
+}
+
+func stopOrErr(err error) error {
+	if err == errStopSSE {
+		return nil
+	}
+	return err
+}
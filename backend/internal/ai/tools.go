@@ -0,0 +1,119 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// Roles a chat can run as. Anonymous chats are treated as civilians.
+const (
+	ChatRoleCivilian = "civilian"
+	ChatRolePolice   = "police"
+	ChatRoleAdmin    = "admin"
+)
+
+// ToolHandler runs a tool with the model's JSON arguments. The result is
+// marshalled to JSON and returned to the model.
+type ToolHandler func(ctx context.Context, args json.RawMessage) (interface{}, error)
+
+// ChatTool is a function the chat model may call
+type ChatTool struct {
+	Spec ToolSpec
+	// Roles that may call the tool; empty means everyone
+	Roles   []string
+	Handler ToolHandler
+}
+
+func (t ChatTool) allows(role string) bool {
+	return len(t.Roles) == 0 || contains(t.Roles, role)
+}
+
+// ToolRegistry holds the tools available to the chat agent
+type ToolRegistry struct {
+	tools map[string]ChatTool
+	order []string
+}
+
+func NewToolRegistry(tools ...ChatTool) *ToolRegistry {
+	r := &ToolRegistry{tools: make(map[string]ChatTool)}
+	for _, tool := range tools {
+		r.Register(tool)
+	}
+	return r
+}
+
+// Register adds a tool, replacing any with the same name
+func (r *ToolRegistry) Register(tool ChatTool) {
+	if _, ok := r.tools[tool.Spec.Name]; !ok {
+		r.order = append(r.order, tool.Spec.Name)
+	}
+	r.tools[tool.Spec.Name] = tool
+}
+
+// For returns the specs of the tools role may call. A non-nil allowed list
+// further limits them to the named tools.
+func (r *ToolRegistry) For(role string, allowed []string) []ToolSpec {
+	if r == nil {
+		return nil
+	}
+	var specs []ToolSpec
+	for _, name := range r.order {
+		if tool := r.tools[name]; r.permitted(tool, role, allowed) {
+			specs = append(specs, tool.Spec)
+		}
+	}
+	return specs
+}
+
+func (r *ToolRegistry) permitted(tool ChatTool, role string, allowed []string) bool {
+	return tool.allows(role) && (allowed == nil || contains(allowed, tool.Spec.Name))
+}
+
+// Execute runs call as role, limited to allowed as in For, and returns the
+// tool message to send back to the model. Failures are reported to the
+// model rather than the caller so it can recover or answer without the
+// data.
+func (r *ToolRegistry) Execute(ctx context.Context, role string, allowed []string, call ToolCall) Message {
+	result := Message{Role: RoleTool, ToolCallID: call.ID, Name: call.Name}
+
+	// The model only sees permitted tools, but it can still name others
+	tool, ok := r.tools[call.Name]
+	if !ok || !r.permitted(tool, role, allowed) {
+		slog.WarnContext(ctx, "model called unavailable tool", "tool", call.Name, "role", role)
+		result.Content = toolError(fmt.Errorf("tool %q is not available", call.Name))
+		return result
+	}
+
+	output, err := tool.Handler(ctx, call.Arguments)
+	if err != nil {
+		slog.WarnContext(ctx, "tool call failed", "tool", call.Name, "error", err)
+		result.Content = toolError(err)
+		return result
+	}
+
+	data, err := json.Marshal(output)
+	if err != nil {
+		result.Content = toolError(err)
+		return result
+	}
+	result.Content = string(data)
+	return result
+}
+
+func toolError(err error) string {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(data)
+}
+
+// decodeArgs unmarshals tool arguments, treating empty arguments as {}
+func decodeArgs(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
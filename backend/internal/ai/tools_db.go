@@ -0,0 +1,165 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"serpico/backend/internal/database"
+)
+
+// publicOfficer is what civilians are told of an on-duty officer: enough
+// to recognize a unit nearby, without the officer's name or position
+type publicOfficer struct {
+	Rank          string `json:"rank"`
+	VehicleNumber string `json:"vehicle_number"`
+	Area          string `json:"area"`
+	Status        string `json:"status"`
+}
+
+// DatabaseTools exposes read-only department data to the chat model.
+// Case files and perp records are limited to police and admins, and only
+// they see officers' names and positions.
+func DatabaseTools(db *database.Database) []ChatTool {
+	return []ChatTool{
+		{
+			Spec: ToolSpec{
+				Name:        "search_cases",
+				Description: "Search case records by keyword (crime type, location or description), optionally filtered by status.",
+				Parameters: objectSchema(map[string]interface{}{
+					"query":  stringParam("Keyword to match against case type, location and description"),
+					"status": stringParam("Only return cases with this status, e.g. Open, Closed, Under Investigation"),
+					"limit":  integerParam("Maximum number of cases to return (default 10)"),
+				}, "query"),
+			},
+			Roles: []string{ChatRolePolice, ChatRoleAdmin},
+			Handler: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+				var params struct {
+					Query  string `json:"query"`
+					Status string `json:"status"`
+					Limit  int    `json:"limit"`
+				}
+				if err := decodeArgs(args, &params); err != nil {
+					return nil, err
+				}
+				return db.SearchCases(params.Query, params.Status, params.Limit)
+			},
+		},
+		{
+			Spec: ToolSpec{
+				Name:        "get_perp",
+				Description: "Look up a known perpetrator by ID or alias, including last known location and status.",
+				Parameters: objectSchema(map[string]interface{}{
+					"id_or_alias": stringParam("Perp ID or alias"),
+				}, "id_or_alias"),
+			},
+			Roles: []string{ChatRolePolice, ChatRoleAdmin},
+			Handler: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+				var params struct {
+					IDOrAlias string `json:"id_or_alias"`
+				}
+				if err := decodeArgs(args, &params); err != nil {
+					return nil, err
+				}
+				if params.IDOrAlias == "" {
+					return nil, errors.New("id_or_alias is required")
+				}
+				perp, err := db.FindPerp(params.IDOrAlias)
+				if err != nil {
+					return nil, err
+				}
+				if perp == nil {
+					return map[string]string{"result": "no matching perp"}, nil
+				}
+				return perp, nil
+			},
+		},
+		{
+			Spec: ToolSpec{
+				Name:        "nearby_officers",
+				Description: "List on-duty officers, optionally those patrolling an area such as North Olathe.",
+				Parameters: objectSchema(map[string]interface{}{
+					"area":  stringParam("Area of the city, e.g. Downtown Olathe"),
+					"limit": integerParam("Maximum number of officers to return (default 5)"),
+				}),
+			},
+			// Civilians get units without names or positions
+			Handler: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+				var params struct {
+					Area  string `json:"area"`
+					Limit int    `json:"limit"`
+				}
+				if err := decodeArgs(args, &params); err != nil {
+					return nil, err
+				}
+				officers, err := db.OnDutyOfficers(params.Area, params.Limit)
+				if err != nil || contains([]string{ChatRolePolice, ChatRoleAdmin}, chatRoleFrom(ctx)) {
+					return officers, err
+				}
+				units := make([]publicOfficer, len(officers))
+				for i, o := range officers {
+					units[i] = publicOfficer{Rank: o.Rank, VehicleNumber: o.VehicleNumber, Area: o.Area, Status: o.Status}
+				}
+				return units, nil
+			},
+		},
+		{
+			Spec: ToolSpec{
+				Name:        "active_emergencies",
+				Description: "List currently active emergencies, optionally in an area or street.",
+				Parameters: objectSchema(map[string]interface{}{
+					"area": stringParam("Area or street name"),
+				}),
+			},
+			Handler: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+				var params struct {
+					Area string `json:"area"`
+				}
+				if err := decodeArgs(args, &params); err != nil {
+					return nil, err
+				}
+				return db.ActiveEmergencies(params.Area)
+			},
+		},
+		{
+			Spec: ToolSpec{
+				Name:        "crime_stats_by_area",
+				Description: "Count cases by crime type for an area, with solved and open totals.",
+				Parameters: objectSchema(map[string]interface{}{
+					"area": stringParam("Area or street name"),
+				}, "area"),
+			},
+			Handler: func(ctx context.Context, args json.RawMessage) (interface{}, error) {
+				var params struct {
+					Area string `json:"area"`
+				}
+				if err := decodeArgs(args, &params); err != nil {
+					return nil, err
+				}
+				if params.Area == "" {
+					return nil, errors.New("area is required")
+				}
+				return db.CrimeStatsByArea(params.Area)
+			},
+		},
+	}
+}
+
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringParam(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+func integerParam(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "integer", "description": description}
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"serpico/backend/internal/ai"
+	"serpico/backend/internal/database"
+	"serpico/backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type chatRequest struct {
+	Message        string `json:"message"`
+	Context        string `json:"context"`
+	Mode           string `json:"mode"`
+	ConversationID string `json:"conversation_id"`
+}
+
+func handleChat(c *gin.Context, db *database.Database, aiService *ai.AIService) {
+	input, conversation, ok := startChat(c, db, aiService)
+	if !ok {
+		return
+	}
+
+	// Process chat with AI service
+	result, err := aiService.ProcessChat(c.Request.Context(), input)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	response := gin.H{
+		"id":         uuid.New().String(),
+		"role":       "assistant",
+		"content":    result.Content,
+		"mode":       input.Mode,
+		"sources":    result.Sources,
+		"citations":  result.Citations,
+		"no_context": result.NoContext,
+		"safety":     safetySummary(result.Safety),
+		"timestamp":  time.Now().Format(time.RFC3339),
+	}
+
+	if conversation != nil {
+		if err := saveChatTurn(db, aiService, conversation, input, response["id"].(string), result); err != nil {
+			internalError(c, err)
+			return
+		}
+		response["conversation_id"] = conversation.ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{"response": response})
+}
+
+// handleChatStream answers like handleChat but relays the answer as
+// server-sent events: "start", then "token" events with text chunks, then
+// "done" with sources and usage, or "error". Generation stops when the
+// client disconnects.
+func handleChatStream(c *gin.Context, db *database.Database, aiService *ai.AIService) {
+	input, conversation, ok := startChat(c, db, aiService)
+	if !ok {
+		return
+	}
+
+	responseID := uuid.New().String()
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	start := gin.H{"id": responseID, "mode": input.Mode}
+	if conversation != nil {
+		start["conversation_id"] = conversation.ID
+	}
+	c.SSEvent("start", start)
+	c.Writer.Flush()
+
+	ctx := c.Request.Context()
+	result, err := aiService.ProcessChatStream(ctx, input, func(chunk string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.SSEvent("token", gin.H{"text": chunk})
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		// Nobody is listening once the client has gone
+		if ctx.Err() != nil {
+			return
+		}
+		middleware.LogInternalError(c, err)
+		c.SSEvent("error", middleware.ErrorResponse{
+			Error:     "An internal error occurred",
+			Code:      middleware.ErrCodeInternal,
+			RequestID: c.GetString("requestID"),
+		})
+		c.Writer.Flush()
+		return
+	}
+
+	if conversation != nil {
+		if err := saveChatTurn(db, aiService, conversation, input, responseID, result); err != nil {
+			middleware.LogInternalError(c, err)
+		}
+	}
+
+	c.SSEvent("done", gin.H{
+		"id":         responseID,
+		"role":       "assistant",
+		"content":    result.Content,
+		"filtered":   result.Filtered,
+		"sources":    result.Sources,
+		"citations":  result.Citations,
+		"no_context": result.NoContext,
+		"provider":   result.Provider,
+		"model":      result.Model,
+		"usage":      result.Usage,
+		"tool_calls": result.ToolCalls,
+		"safety":     safetySummary(result.Safety),
+		"timestamp":  time.Now().Format(time.RFC3339),
+	})
+	c.Writer.Flush()
+}
+
+// startChat binds the request, picks the chat mode and, for signed-in
+// users, loads or creates the conversation. It writes the error response
+// and returns false on failure.
+func startChat(c *gin.Context, db *database.Database, aiService *ai.AIService) (ai.ChatInput, *database.Conversation, bool) {
+	var req chatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return ai.ChatInput{}, nil, false
+	}
+
+	// Signed-in users get their turns stored; anonymous chat stays stateless
+	session := middleware.CurrentSession(c)
+	if req.ConversationID != "" && session == nil {
+		middleware.AbortWithError(c, http.StatusUnauthorized, middleware.ErrCodeUnauthorized, "Authentication required to continue a conversation")
+		return ai.ChatInput{}, nil, false
+	}
+
+	input := ai.ChatInput{Message: req.Message, Context: req.Context, Role: ai.ChatRoleCivilian}
+	if session != nil {
+		input.Role = session.Role
+	}
+	if !resolveChatMode(c, aiService, &req, &input) {
+		return ai.ChatInput{}, nil, false
+	}
+	if session == nil {
+		return input, nil, true
+	}
+
+	// A new conversation is titled after the message, so it's redacted as
+	// the message will be
+	title := aiService.PII().Redact(input.Role, ai.PIIUseStore, req.Message)
+	conversation, history, err := loadConversation(db, session.UserID, req.ConversationID, title)
+	if err == database.ErrConversationNotFound {
+		notFound(c, "Conversation not found")
+		return ai.ChatInput{}, nil, false
+	}
+	if err != nil {
+		internalError(c, err)
+		return ai.ChatInput{}, nil, false
+	}
+
+	input.History = history
+	return input, conversation, true
+}
+
+// resolveChatMode sets input.Mode from the request. Older clients send the
+// app module as context; that still selects the module's mode, falling back
+// to the default when the caller's role can't use it.
+func resolveChatMode(c *gin.Context, aiService *ai.AIService, req *chatRequest, input *ai.ChatInput) bool {
+	modes := aiService.Modes()
+	if req.Mode == "" {
+		if mode, ok := modes.Get(req.Context); ok {
+			input.Context = ""
+			if mode.Allows(input.Role) {
+				input.Mode = mode.Name
+				return true
+			}
+		}
+	}
+
+	mode, err := modes.Resolve(req.Mode, input.Role)
+	switch {
+	case errors.Is(err, ai.ErrUnknownChatMode):
+		middleware.AbortWithError(c, http.StatusBadRequest, middleware.ErrCodeBadRequest, "Unknown chat mode")
+		return false
+	case errors.Is(err, ai.ErrChatModeNotAllowed):
+		middleware.AbortWithError(c, http.StatusForbidden, middleware.ErrCodeForbidden, "Chat mode not available for your role")
+		return false
+	}
+
+	input.Mode = mode.Name
+	return true
+}
+
+// saveChatTurn stores the user's message and the assistant's answer, with
+// personal information redacted under the role's PII store policy. Turns
+// the screener refused aren't stored, so neither an injection nor an
+// off-topic prompt comes back as history.
+func saveChatTurn(db *database.Database, aiService *ai.AIService, conversation *database.Conversation, input ai.ChatInput, responseID string, result *ai.ChatResult) error {
+	if result.Filtered {
+		return nil
+	}
+	pii := aiService.PII()
+	return db.AppendConversationMessages(conversation.ID,
+		database.ConversationMessage{Role: ai.RoleUser, Content: pii.Redact(input.Role, ai.PIIUseStore, input.Message)},
+		database.ConversationMessage{ID: responseID, Role: ai.RoleAssistant, Content: pii.Redact(input.Role, ai.PIIUseStore, result.Content)},
+	)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+
+	"serpico/backend/internal/ai"
+	"serpico/backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chatModeSummary is what chat clients need to offer a mode
+type chatModeSummary struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// handleListChatModes lists the modes the caller's role may select
+func handleListChatModes(c *gin.Context, aiService *ai.AIService) {
+	role := ai.ChatRoleCivilian
+	if session := middleware.CurrentSession(c); session != nil {
+		role = session.Role
+	}
+
+	modes := []chatModeSummary{}
+	for _, mode := range aiService.Modes().List() {
+		if mode.Allows(role) {
+			modes = append(modes, chatModeSummary{Name: mode.Name, Title: mode.Title, Description: mode.Description})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"modes": modes, "default": ai.DefaultChatMode})
+}
+
+func handleAdminGetChatModes(c *gin.Context, aiService *ai.AIService) {
+	modes := aiService.Modes().List()
+	c.JSON(http.StatusOK, gin.H{"modes": modes, "total": len(modes)})
+}
+
+func handleAdminGetChatMode(c *gin.Context, aiService *ai.AIService) {
+	mode, ok := aiService.Modes().Get(c.Param("name"))
+	if !ok {
+		notFound(c, "Chat mode not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mode": mode})
+}
+
+// handleAdminSaveChatMode creates or replaces a mode's template
+func handleAdminSaveChatMode(c *gin.Context, aiService *ai.AIService) {
+	var mode ai.ChatMode
+	if err := c.ShouldBindJSON(&mode); err != nil {
+		badRequest(c, err)
+		return
+	}
+	mode.Name = c.Param("name")
+
+	if err := mode.Validate(); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if err := aiService.Modes().Save(mode); err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Chat mode saved successfully", "mode": mode})
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"serpico/backend/internal/ai"
+	"serpico/backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loadConversation returns the user's conversation and its history, or
+// starts a new conversation titled after the first message when id is empty
+func loadConversation(db *database.Database, userID, id, firstMessage string) (*database.Conversation, []ai.Message, error) {
+	if id == "" {
+		conv, err := db.CreateConversation(userID, conversationTitle(firstMessage))
+		return conv, nil, err
+	}
+
+	conv, err := db.GetConversation(userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stored, err := db.GetConversationMessages(conv.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	history := make([]ai.Message, len(stored))
+	for i, msg := range stored {
+		history[i] = ai.Message{Role: msg.Role, Content: msg.Content}
+	}
+	return conv, history, nil
+}
+
+func conversationTitle(message string) string {
+	const maxLen = 60
+	title := strings.Join(strings.Fields(message), " ")
+	if len(title) > maxLen {
+		title = strings.TrimSpace(title[:maxLen]) + "..."
+	}
+	if title == "" {
+		title = "New conversation"
+	}
+	return title
+}
+
+func handleListConversations(c *gin.Context, db *database.Database) {
+	conversations, err := db.ListConversations(c.GetString("userID"))
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversations": conversations, "total": len(conversations)})
+}
+
+func handleGetConversation(c *gin.Context, db *database.Database) {
+	conv, err := db.GetConversation(c.GetString("userID"), c.Param("id"))
+	if err == database.ErrConversationNotFound {
+		notFound(c, "Conversation not found")
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	messages, err := db.GetConversationMessages(conv.ID)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversation": conv, "messages": messages})
+}
+
+func handleDeleteConversation(c *gin.Context, db *database.Database) {
+	id := c.Param("id")
+	err := db.DeleteConversation(c.GetString("userID"), id)
+	if err == database.ErrConversationNotFound {
+		notFound(c, "Conversation not found")
+		return
+	}
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversation deleted successfully", "id": id})
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+
+	"serpico/backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// badRequest reports a malformed request body or query
+func badRequest(c *gin.Context, err error) {
+	middleware.AbortWithError(c, http.StatusBadRequest, middleware.ErrCodeBadRequest, err.Error())
+}
+
+// notFound reports a missing resource
+func notFound(c *gin.Context, message string) {
+	middleware.AbortWithError(c, http.StatusNotFound, middleware.ErrCodeNotFound, message)
+}
+
+// internalError logs err and returns a generic 500
+func internalError(c *gin.Context, err error) {
+	middleware.AbortWithInternalError(c, err)
+}
@@ -2,82 +2,103 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"serpico/backend/internal/ai"
 	"serpico/backend/internal/database"
+	"serpico/backend/internal/middleware"
 )
 
 // Mock login handler
-func handleLogin(c *gin.Context) {
+func handleLogin(c *gin.Context, db *database.Database) {
 	var req struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 
-	// Mock authentication - always succeeds
-	user := gin.H{
-		"id":    uuid.New().String(),
-		"email": req.Email,
-		"name":  "Demo User",
-		"role":  "police",
-		"rank":  "Officer",
+	// Mock authentication - always succeeds. Nothing is verified, so each
+	// login is a new user rather than whoever owns the email, and can't
+	// read anyone else's conversations.
+	session := database.Session{
+		UserID: uuid.New().String(),
+		Email:  req.Email,
+		Name:   "Demo User",
+		Role:   "police",
+		Rank:   "Officer",
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"user":  user,
-		"token": "mock_token_" + uuid.New().String(),
-	})
+	respondWithSession(c, db, "mock_token_", session)
 }
 
-func handleGoogleLogin(c *gin.Context) {
+func handleGoogleLogin(c *gin.Context, db *database.Database) {
 	// Mock Google login
-	user := gin.H{
-		"id":    uuid.New().String(),
-		"email": "user@gmail.com",
-		"name":  "Google User",
-		"role":  "police",
-		"rank":  "Officer",
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"user":  user,
-		"token": "mock_token_" + uuid.New().String(),
-	})
+	session := database.Session{
+		UserID: uuid.New().String(),
+		Email:  "user@gmail.com",
+		Name:   "Google User",
+		Role:   "police",
+		Rank:   "Officer",
+	}
+	respondWithSession(c, db, "mock_token_", session)
 }
 
-func handleAppleLogin(c *gin.Context) {
+func handleAppleLogin(c *gin.Context, db *database.Database) {
 	// Mock Apple login
-	user := gin.H{
-		"id":    uuid.New().String(),
-		"email": "user@icloud.com",
-		"name":  "Apple User",
-		"role":  "police",
-		"rank":  "Officer",
+	session := database.Session{
+		UserID: uuid.New().String(),
+		Email:  "user@icloud.com",
+		Name:   "Apple User",
+		Role:   "police",
+		Rank:   "Officer",
+	}
+	respondWithSession(c, db, "mock_token_", session)
+}
+
+// respondWithSession issues a token for session and returns the login response
+func respondWithSession(c *gin.Context, db *database.Database, tokenPrefix string, session database.Session) {
+	token := tokenPrefix + uuid.New().String()
+	if err := db.CreateSession(token, session); err != nil {
+		internalError(c, err)
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"user":  user,
-		"token": "mock_token_" + uuid.New().String(),
+		"user": gin.H{
+			"id":    session.UserID,
+			"email": session.Email,
+			"name":  session.Name,
+			"role":  session.Role,
+			"rank":  session.Rank,
+		},
+		"token": token,
 	})
 }
 
-func handleLogout(c *gin.Context) {
+func handleLogout(c *gin.Context, db *database.Database) {
+	if token := middleware.BearerToken(c); token != "" {
+		if err := db.DeleteSession(token); err != nil {
+			internalError(c, err)
+			return
+		}
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
 func handleGetUser(c *gin.Context, db *database.Database) {
 	// Get first user from database as demo
 	var id, email, name, role, rank string
-	err := db.SQLite.QueryRow("SELECT id, email, name, role, rank FROM users LIMIT 1").Scan(&id, &email, &name, &role, &rank)
+	err := db.QueryRow("SELECT id, email, name, role, rank FROM users LIMIT 1").Scan(&id, &email, &name, &role, &rank)
 	if err != nil {
 		// Fallback to mock data if no users in database
 		c.JSON(http.StatusOK, gin.H{
@@ -112,7 +133,7 @@ func handleUpdateUser(c *gin.Context) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 
@@ -128,9 +149,9 @@ func handleUpdateUser(c *gin.Context) {
 }
 
 func handleGetCases(c *gin.Context, db *database.Database) {
-	rows, err := db.SQLite.Query("SELECT id, type, location, date, status, description, solved FROM cases ORDER BY date DESC")
+	rows, err := db.Query("SELECT id, type, location, date, status, description, solved FROM cases ORDER BY date DESC")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -161,14 +182,14 @@ func handleGetCase(c *gin.Context, db *database.Database) {
 
 	var caseType, location, date, status, description string
 	var solved int
-	err := db.SQLite.QueryRow("SELECT type, location, date, status, description, solved FROM cases WHERE id = ?", id).
+	err := db.QueryRow("SELECT type, location, date, status, description, solved FROM cases WHERE id = ?", id).
 		Scan(&caseType, &location, &date, &status, &description, &solved)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Case not found"})
+			notFound(c, "Case not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 
@@ -183,7 +204,7 @@ func handleGetCase(c *gin.Context, db *database.Database) {
 	})
 }
 
-func handleCreateCase(c *gin.Context, db *database.Database) {
+func handleCreateCase(c *gin.Context, db *database.Database, records *ai.RecordSync) {
 	var req struct {
 		Type        string `json:"type"`
 		Location    string `json:"location"`
@@ -192,17 +213,18 @@ func handleCreateCase(c *gin.Context, db *database.Database) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 
 	id := "case-" + uuid.New().String()
-	_, err := db.SQLite.Exec("INSERT INTO cases (id, type, location, date, status, description, solved) VALUES (?, ?, ?, ?, ?, ?, ?)",
+	_, err := db.Exec("INSERT INTO cases (id, type, location, date, status, description, solved) VALUES (?, ?, ?, ?, ?, ?, ?)",
 		id, req.Type, req.Location, req.Date, "Open", req.Description, 0)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
+	records.Notify(ai.RecordCases, id)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"id":          id,
@@ -216,9 +238,9 @@ func handleCreateCase(c *gin.Context, db *database.Database) {
 }
 
 func handleGetPerps(c *gin.Context, db *database.Database) {
-	rows, err := db.SQLite.Query("SELECT id, alias, location, last_seen, status FROM perps ORDER BY last_seen DESC")
+	rows, err := db.Query("SELECT id, alias, location, last_seen, status FROM perps ORDER BY last_seen DESC")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -232,7 +254,7 @@ func handleGetPerps(c *gin.Context, db *database.Database) {
 
 		// Count related cases
 		var caseCount int
-		db.SQLite.QueryRow("SELECT COUNT(*) FROM cases WHERE location LIKE ?", "%"+location+"%").Scan(&caseCount)
+		db.QueryRow("SELECT COUNT(*) FROM cases WHERE location LIKE ?", "%"+location+"%").Scan(&caseCount)
 
 		perps = append(perps, gin.H{
 			"id":        id,
@@ -251,19 +273,19 @@ func handleGetPerp(c *gin.Context, db *database.Database) {
 	id := c.Param("id")
 
 	var alias, location, lastSeen, status string
-	err := db.SQLite.QueryRow("SELECT alias, location, last_seen, status FROM perps WHERE id = ?", id).
+	err := db.QueryRow("SELECT alias, location, last_seen, status FROM perps WHERE id = ?", id).
 		Scan(&alias, &location, &lastSeen, &status)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Perp not found"})
+			notFound(c, "Perp not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 
 	var caseCount int
-	db.SQLite.QueryRow("SELECT COUNT(*) FROM cases WHERE location LIKE ?", "%"+location+"%").Scan(&caseCount)
+	db.QueryRow("SELECT COUNT(*) FROM cases WHERE location LIKE ?", "%"+location+"%").Scan(&caseCount)
 
 	c.JSON(http.StatusOK, gin.H{
 		"id":        id,
@@ -276,9 +298,9 @@ func handleGetPerp(c *gin.Context, db *database.Database) {
 }
 
 func handleGetOfficers(c *gin.Context, db *database.Database) {
-	rows, err := db.SQLite.Query("SELECT id, name, rank, vehicle_plate, vehicle_number, current_location, status FROM officers WHERE status IN ('On Duty', 'On Patrol')")
+	rows, err := db.Query("SELECT id, name, rank, vehicle_plate, vehicle_number, current_location, status FROM officers WHERE status IN ('On Duty', 'On Patrol')")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -309,9 +331,9 @@ func handleGetNearbyOfficers(c *gin.Context, db *database.Database) {
 	// In real app, would filter by distance using lat/lng
 
 	// Get all officers (in real app, would filter by distance)
-	rows, err := db.SQLite.Query("SELECT id, name, rank, vehicle_plate, vehicle_number, current_location FROM officers WHERE status IN ('On Duty', 'On Patrol') LIMIT 5")
+	rows, err := db.Query("SELECT id, name, rank, vehicle_plate, vehicle_number, current_location FROM officers WHERE status IN ('On Duty', 'On Patrol') LIMIT 5")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -337,9 +359,9 @@ func handleGetNearbyOfficers(c *gin.Context, db *database.Database) {
 }
 
 func handleGetEmergencies(c *gin.Context, db *database.Database) {
-	rows, err := db.SQLite.Query("SELECT id, type, location, priority, category, assigned_officer_id, status, created_at FROM emergencies WHERE status = 'Active' ORDER BY created_at DESC")
+	rows, err := db.Query("SELECT id, type, location, priority, category, assigned_officer_id, status, created_at FROM emergencies WHERE status = 'Active' ORDER BY created_at DESC")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -364,7 +386,7 @@ func handleGetEmergencies(c *gin.Context, db *database.Database) {
 	c.JSON(http.StatusOK, gin.H{"emergencies": emergencies})
 }
 
-func handleCreateEmergency(c *gin.Context, db *database.Database) {
+func handleCreateEmergency(c *gin.Context, db *database.Database, records *ai.RecordSync) {
 	var req struct {
 		Type     string `json:"type"`
 		Location string `json:"location"`
@@ -373,18 +395,19 @@ func handleCreateEmergency(c *gin.Context, db *database.Database) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 
 	id := "emergency-" + uuid.New().String()
 	createdAt := time.Now().Format(time.RFC3339)
-	_, err := db.SQLite.Exec("INSERT INTO emergencies (id, type, location, priority, category, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+	_, err := db.Exec("INSERT INTO emergencies (id, type, location, priority, category, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
 		id, req.Type, req.Location, req.Priority, req.Category, "Active", createdAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
+	records.Notify(ai.RecordEmergencies, id)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"id":         id,
@@ -401,14 +424,14 @@ func handleGetEmergency(c *gin.Context, db *database.Database) {
 	id := c.Param("id")
 
 	var emergencyType, location, priority, category, status, createdAt string
-	err := db.SQLite.QueryRow("SELECT type, location, priority, category, status, created_at FROM emergencies WHERE id = ?", id).
+	err := db.QueryRow("SELECT type, location, priority, category, status, created_at FROM emergencies WHERE id = ?", id).
 		Scan(&emergencyType, &location, &priority, &category, &status, &createdAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Emergency not found"})
+			notFound(c, "Emergency not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 
@@ -423,43 +446,6 @@ func handleGetEmergency(c *gin.Context, db *database.Database) {
 	})
 }
 
-func handleChat(c *gin.Context, aiService interface{}) {
-	var req struct {
-		Message string `json:"message"`
-		Context string `json:"context"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Type assert to get AIService
-	ai, ok := aiService.(interface {
-		ProcessChat(userMessage string, context string) (string, error)
-	})
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI service not available"})
-		return
-	}
-
-	// Process chat with AI service
-	content, err := ai.ProcessChat(req.Message, req.Context)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	response := gin.H{
-		"id":        uuid.New().String(),
-		"role":      "assistant",
-		"content":   content,
-		"timestamp": time.Now().Format(time.RFC3339),
-	}
-
-	c.JSON(http.StatusOK, gin.H{"response": response})
-}
-
 func handleGetRouteRecommendations(c *gin.Context) {
 	from := c.Query("from")
 	to := c.Query("to")
@@ -496,9 +482,9 @@ func handleGetPursuitRecommendations(c *gin.Context) {
 
 // Admin handlers
 func handleAdminGetAllCases(c *gin.Context, db *database.Database) {
-	rows, err := db.SQLite.Query("SELECT id, type, date, location, status, description FROM cases ORDER BY date DESC")
+	rows, err := db.Query("SELECT id, type, date, location, status, description FROM cases ORDER BY date DESC")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -523,9 +509,9 @@ func handleAdminGetAllCases(c *gin.Context, db *database.Database) {
 }
 
 func handleAdminGetAllPerps(c *gin.Context, db *database.Database) {
-	rows, err := db.SQLite.Query("SELECT id, alias, last_seen, location, status FROM perps ORDER BY last_seen DESC")
+	rows, err := db.Query("SELECT id, alias, last_seen, location, status FROM perps ORDER BY last_seen DESC")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -549,9 +535,9 @@ func handleAdminGetAllPerps(c *gin.Context, db *database.Database) {
 }
 
 func handleAdminGetAllOfficers(c *gin.Context, db *database.Database) {
-	rows, err := db.SQLite.Query("SELECT id, name, rank, vehicle_plate, vehicle_number, current_location, status FROM officers ORDER BY name")
+	rows, err := db.Query("SELECT id, name, rank, vehicle_plate, vehicle_number, current_location, status FROM officers ORDER BY name")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -577,9 +563,9 @@ func handleAdminGetAllOfficers(c *gin.Context, db *database.Database) {
 }
 
 func handleAdminGetAllEmergencies(c *gin.Context, db *database.Database) {
-	rows, err := db.SQLite.Query("SELECT id, type, priority, location, category, assigned_officer_id, status, created_at FROM emergencies ORDER BY created_at DESC")
+	rows, err := db.Query("SELECT id, type, priority, location, category, assigned_officer_id, status, created_at FROM emergencies ORDER BY created_at DESC")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -606,9 +592,9 @@ func handleAdminGetAllEmergencies(c *gin.Context, db *database.Database) {
 }
 
 func handleAdminGetAllUsers(c *gin.Context, db *database.Database) {
-	rows, err := db.SQLite.Query("SELECT id, email, name, role, rank FROM users ORDER BY name")
+	rows, err := db.Query("SELECT id, email, name, role, rank FROM users ORDER BY name")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 	defer rows.Close()
@@ -632,36 +618,58 @@ func handleAdminGetAllUsers(c *gin.Context, db *database.Database) {
 }
 
 // Admin login handler
-func handleAdminLogin(c *gin.Context) {
+func handleAdminLogin(c *gin.Context, db *database.Database) {
 	var req struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 
-	// Single admin user
-	if req.Username == "g@transfdr" && req.Password == "eight88" {
+	// Single admin user, plus an optional knowledge base reviewer set by
+	// REVIEWER_USERNAME and REVIEWER_PASSWORD
+	var session database.Session
+	reviewer, reviewerPassword := os.Getenv("REVIEWER_USERNAME"), os.Getenv("REVIEWER_PASSWORD")
+	switch {
+	case req.Username == "g@transfdr" && req.Password == "eight88":
+		session = database.Session{
+			UserID: "admin",
+			Name:   req.Username,
+			Role:   "admin",
+		}
+	case reviewer != "" && reviewerPassword != "" && req.Username == reviewer && req.Password == reviewerPassword:
+		session = database.Session{
+			UserID: "reviewer-" + req.Username,
+			Name:   req.Username,
+			Role:   ai.RoleReviewer,
+		}
+	}
+
+	if session.UserID != "" {
+		token := "admin_token_" + uuid.New().String()
+		if err := db.CreateSession(token, session); err != nil {
+			internalError(c, err)
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
 			"user": gin.H{
 				"username": req.Username,
-				"role":     "admin",
+				"role":     session.Role,
 			},
-			"token": "admin_token_" + uuid.New().String(),
+			"token": token,
 		})
 	} else {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Invalid username or password",
-		})
+		middleware.AbortWithError(c, http.StatusUnauthorized, middleware.ErrCodeUnauthorized, "Invalid username or password")
 	}
 }
 
 // Admin create handlers
-func handleAdminCreateCase(c *gin.Context, db *database.Database) {
+func handleAdminCreateCase(c *gin.Context, db *database.Database, records *ai.RecordSync) {
 	var req struct {
 		Type        string `json:"type"`
 		Location    string `json:"location"`
@@ -671,7 +679,7 @@ func handleAdminCreateCase(c *gin.Context, db *database.Database) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 
@@ -685,12 +693,13 @@ func handleAdminCreateCase(c *gin.Context, db *database.Database) {
 		solved = 1
 	}
 
-	_, err := db.SQLite.Exec("INSERT INTO cases (id, type, location, date, status, description, solved) VALUES (?, ?, ?, ?, ?, ?, ?)",
+	_, err := db.Exec("INSERT INTO cases (id, type, location, date, status, description, solved) VALUES (?, ?, ?, ?, ?, ?, ?)",
 		id, req.Type, req.Location, req.Date, req.Status, req.Description, solved)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
+	records.Notify(ai.RecordCases, id)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"id":          id,
@@ -703,7 +712,7 @@ func handleAdminCreateCase(c *gin.Context, db *database.Database) {
 	})
 }
 
-func handleAdminCreatePerp(c *gin.Context, db *database.Database) {
+func handleAdminCreatePerp(c *gin.Context, db *database.Database, records *ai.RecordSync) {
 	var req struct {
 		Alias    string `json:"alias"`
 		Location string `json:"location"`
@@ -712,7 +721,7 @@ func handleAdminCreatePerp(c *gin.Context, db *database.Database) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 
@@ -721,12 +730,13 @@ func handleAdminCreatePerp(c *gin.Context, db *database.Database) {
 	}
 
 	id := "perp-" + uuid.New().String()
-	_, err := db.SQLite.Exec("INSERT INTO perps (id, alias, location, last_seen, status) VALUES (?, ?, ?, ?, ?)",
+	_, err := db.Exec("INSERT INTO perps (id, alias, location, last_seen, status) VALUES (?, ?, ?, ?, ?)",
 		id, req.Alias, req.Location, req.LastSeen, req.Status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
+	records.Notify(ai.RecordPerps, id)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"id":        id,
@@ -744,11 +754,12 @@ func handleAdminCreateOfficer(c *gin.Context, db *database.Database) {
 		VehiclePlate    string `json:"vehicle_plate"`
 		VehicleNumber   string `json:"vehicle_number"`
 		CurrentLocation string `json:"current_location"`
+		Area            string `json:"area"`
 		Status          string `json:"status"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 
@@ -757,10 +768,10 @@ func handleAdminCreateOfficer(c *gin.Context, db *database.Database) {
 	}
 
 	id := "officer-" + uuid.New().String()
-	_, err := db.SQLite.Exec("INSERT INTO officers (id, name, rank, vehicle_plate, vehicle_number, current_location, status) VALUES (?, ?, ?, ?, ?, ?, ?)",
-		id, req.Name, req.Rank, req.VehiclePlate, req.VehicleNumber, req.CurrentLocation, req.Status)
+	_, err := db.Exec("INSERT INTO officers (id, name, rank, vehicle_plate, vehicle_number, current_location, area, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		id, req.Name, req.Rank, req.VehiclePlate, req.VehicleNumber, req.CurrentLocation, req.Area, req.Status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
 
@@ -771,11 +782,12 @@ func handleAdminCreateOfficer(c *gin.Context, db *database.Database) {
 		"vehicle_plate":    req.VehiclePlate,
 		"vehicle_number":   req.VehicleNumber,
 		"current_location": req.CurrentLocation,
+		"area":             req.Area,
 		"status":           req.Status,
 	})
 }
 
-func handleAdminCreateEmergency(c *gin.Context, db *database.Database) {
+func handleAdminCreateEmergency(c *gin.Context, db *database.Database, records *ai.RecordSync) {
 	var req struct {
 		Type            string `json:"type"`
 		Location        string `json:"location"`
@@ -786,7 +798,7 @@ func handleAdminCreateEmergency(c *gin.Context, db *database.Database) {
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err)
 		return
 	}
 
@@ -798,12 +810,13 @@ func handleAdminCreateEmergency(c *gin.Context, db *database.Database) {
 	}
 
 	id := "emergency-" + uuid.New().String()
-	_, err := db.SQLite.Exec("INSERT INTO emergencies (id, type, location, priority, category, assigned_officer_id, status) VALUES (?, ?, ?, ?, ?, ?, ?)",
+	_, err := db.Exec("INSERT INTO emergencies (id, type, location, priority, category, assigned_officer_id, status) VALUES (?, ?, ?, ?, ?, ?, ?)",
 		id, req.Type, req.Location, req.Priority, req.Category, req.AssignedOfficerID, req.Status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err)
 		return
 	}
+	records.Notify(ai.RecordEmergencies, id)
 
 	c.JSON(http.StatusCreated, gin.H{
 		"id":                id,
@@ -816,55 +829,104 @@ func handleAdminCreateEmergency(c *gin.Context, db *database.Database) {
 	})
 }
 
-// RAG Management handlers
-func handleRAGGetDocuments(c *gin.Context, aiService interface{}) {
-	service, ok := aiService.(*ai.AIService)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI service type assertion failed"})
-		return
+// ragRole is the role knowledge base reads are filtered for. Anonymous
+// callers are civilians, as in chat.
+func ragRole(c *gin.Context) string {
+	if role := c.GetString("userRole"); role != "" {
+		return role
 	}
+	return ai.ChatRoleCivilian
+}
 
-	ragDB := service.GetRAGDatabase()
-	documents := ragDB.GetAllDocuments()
+// ragEditor reports whether the caller manages the knowledge base, and so
+// may read documents that aren't published
+func ragEditor(c *gin.Context) bool {
+	role := c.GetString("userRole")
+	return role == ai.ChatRoleAdmin || role == ai.RoleReviewer
+}
+
+// ragReadable reports whether the caller may read doc: editors read any
+// status, everyone else only published documents
+func ragReadable(c *gin.Context, doc *ai.RAGDocument) bool {
+	return doc.VisibleTo(ragRole(c)) && (doc.Status == ai.StatusPublished || ragEditor(c))
+}
+
+// RAG Management handlers
+func handleRAGGetDocuments(c *gin.Context, aiService *ai.AIService) {
+	ragDB := aiService.GetRAGDatabase()
+	documents := []ai.RAGDocument{}
+	for _, doc := range ragDB.GetAllDocuments() {
+		if ragReadable(c, &doc) {
+			documents = append(documents, doc)
+		}
+	}
+	// ?status=pending_review gives editors the review queue
+	if statuses := c.QueryArray("status"); len(statuses) > 0 && ragEditor(c) {
+		filtered := []ai.RAGDocument{}
+		for _, doc := range documents {
+			for _, status := range statuses {
+				if doc.Status == status {
+					filtered = append(filtered, doc)
+					break
+				}
+			}
+		}
+		documents = filtered
+	}
 	c.JSON(http.StatusOK, gin.H{"documents": documents, "total": len(documents)})
 }
 
-func handleRAGGetDocument(c *gin.Context, aiService interface{}) {
+func handleRAGGetDocument(c *gin.Context, aiService *ai.AIService) {
 	id := c.Param("id")
 	
-	service, ok := aiService.(*ai.AIService)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI service type assertion failed"})
+	ragDB := aiService.GetRAGDatabase()
+	doc := ragDB.GetDocumentByID(id)
+	if doc == nil || !ragReadable(c, doc) {
+		notFound(c, "Document not found")
 		return
 	}
 
-	ragDB := service.GetRAGDatabase()
-	doc := ragDB.GetDocumentByID(id)
-	if doc == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+	c.JSON(http.StatusOK, gin.H{"document": doc, "chunks": ragDB.GetChunks(id)})
+}
+
+// handleRAGSearch runs a retrieval query the way chat does, for checking
+// what the assistant would see
+func handleRAGSearch(c *gin.Context, aiService *ai.AIService) {
+	query := c.Query("q")
+	if strings.TrimSpace(query) == "" {
+		middleware.AbortWithError(c, http.StatusBadRequest, middleware.ErrCodeBadRequest, "q is required")
 		return
 	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
 
-	c.JSON(http.StatusOK, gin.H{"document": doc})
+	filter := ai.SearchFilter{
+		Categories: c.QueryArray("category"),
+		Location:   c.Query("location"),
+		Tags:       c.QueryArray("tag"),
+		Role:       ragRole(c),
+	}
+	// Editors can preview how unpublished documents would rank
+	if ragEditor(c) {
+		filter.Statuses = c.QueryArray("status")
+	}
+	results := aiService.GetRAGDatabase().SearchScored(c.Request.Context(), query, limit, filter)
+	c.JSON(http.StatusOK, gin.H{"results": results, "total": len(results)})
 }
 
-func handleRAGCreateDocument(c *gin.Context, aiService interface{}) {
+func handleRAGCreateDocument(c *gin.Context, aiService *ai.AIService) {
 	var req struct {
 		Title    string   `json:"title"`
 		Content  string   `json:"content"`
 		Category string   `json:"category"`
 		Location string   `json:"location,omitempty"`
 		Tags     []string `json:"tags"`
+		// Submit sends the document for review instead of saving a draft
+		Submit bool `json:"submit"`
+		documentDates
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	service, ok := aiService.(*ai.AIService)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI service type assertion failed"})
+		badRequest(c, err)
 		return
 	}
 
@@ -878,17 +940,25 @@ func handleRAGCreateDocument(c *gin.Context, aiService interface{}) {
 		Location: req.Location,
 		Tags:     req.Tags,
 	}
+	if req.Submit {
+		doc.Status = ai.StatusPendingReview
+	}
+	if err := req.documentDates.apply(&doc); err != nil {
+		badRequest(c, err)
+		return
+	}
 
-	ragDB := service.GetRAGDatabase()
-	if err := ragDB.AddDocument(doc); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	ragDB := aiService.GetRAGDatabase()
+	doc, err := ragDB.AddDocument(ragContext(c), doc)
+	if err != nil {
+		internalError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"document": doc})
 }
 
-func handleRAGUpdateDocument(c *gin.Context, aiService interface{}) {
+func handleRAGUpdateDocument(c *gin.Context, aiService *ai.AIService) {
 	id := c.Param("id")
 	var req struct {
 		Title    string   `json:"title"`
@@ -896,16 +966,13 @@ func handleRAGUpdateDocument(c *gin.Context, aiService interface{}) {
 		Category string   `json:"category"`
 		Location string   `json:"location,omitempty"`
 		Tags     []string `json:"tags"`
+		// Submit sends the document for review instead of saving a draft
+		Submit bool `json:"submit"`
+		documentDates
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-
-	service, ok := aiService.(*ai.AIService)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI service type assertion failed"})
+		badRequest(c, err)
 		return
 	}
 
@@ -917,28 +984,47 @@ func handleRAGUpdateDocument(c *gin.Context, aiService interface{}) {
 		Location: req.Location,
 		Tags:     req.Tags,
 	}
+	if req.Submit {
+		doc.Status = ai.StatusPendingReview
+	}
+	if err := req.documentDates.apply(&doc); err != nil {
+		badRequest(c, err)
+		return
+	}
 
-	ragDB := service.GetRAGDatabase()
-	if err := ragDB.UpdateDocument(id, doc); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	ragDB := aiService.GetRAGDatabase()
+	doc, err := ragDB.UpdateDocument(ragContext(c), id, doc)
+	if errors.Is(err, ai.ErrDocumentNotFound) {
+		notFound(c, "Document not found")
+		return
+	}
+	if errors.Is(err, ai.ErrRecordDocument) {
+		badRequest(c, err)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"document": doc})
 }
 
-func handleRAGDeleteDocument(c *gin.Context, aiService interface{}) {
+func handleRAGDeleteDocument(c *gin.Context, aiService *ai.AIService) {
 	id := c.Param("id")
 
-	service, ok := aiService.(*ai.AIService)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI service type assertion failed"})
+	ragDB := aiService.GetRAGDatabase()
+	err := ragDB.DeleteDocument(ragContext(c), id)
+	if errors.Is(err, ai.ErrDocumentNotFound) {
+		notFound(c, "Document not found")
 		return
 	}
-
-	ragDB := service.GetRAGDatabase()
-	if err := ragDB.DeleteDocument(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if errors.Is(err, ai.ErrRecordDocument) {
+		badRequest(c, err)
+		return
+	}
+	if err != nil {
+		internalError(c, err)
 		return
 	}
 
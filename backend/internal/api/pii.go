@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+
+	"serpico/backend/internal/ai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleAdminGetPIIPolicy returns the loaded PII policy
+func handleAdminGetPIIPolicy(c *gin.Context, aiService *ai.AIService) {
+	c.JSON(http.StatusOK, gin.H{"policy": aiService.PII().Config(), "types": ai.PIITypes})
+}
+
+// handleAdminReloadPIIPolicy rereads the policy file after it's been
+// edited
+func handleAdminReloadPIIPolicy(c *gin.Context, aiService *ai.AIService) {
+	if err := aiService.PII().Reload(); err != nil {
+		// The file is the admin's to fix, so say what's wrong with it
+		badRequest(c, err)
+		return
+	}
+	handleAdminGetPIIPolicy(c, aiService)
+}
+
+// handleAdminTestPIIPolicy shows the personal information found in some
+// text and what a role's policy sends to providers, stores and logs
+func handleAdminTestPIIPolicy(c *gin.Context, aiService *ai.AIService) {
+	var req struct {
+		Text string `json:"text" binding:"required"`
+		Role string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = ai.ChatRoleCivilian
+	}
+	c.JSON(http.StatusOK, aiService.PII().Test(req.Role, req.Text))
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"serpico/backend/internal/ai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// documentDates are the optional validity dates accepted when creating or
+// updating a document, as RFC 3339 times or plain dates
+type documentDates struct {
+	EffectiveFrom string `json:"effective_from"`
+	ExpiresAt     string `json:"expires_at"`
+}
+
+// apply parses the dates onto doc
+func (d documentDates) apply(doc *ai.RAGDocument) error {
+	var err error
+	if doc.EffectiveFrom, err = parseDocumentDate("effective_from", d.EffectiveFrom); err != nil {
+		return err
+	}
+	if doc.ExpiresAt, err = parseDocumentDate("expires_at", d.ExpiresAt); err != nil {
+		return err
+	}
+	if doc.EffectiveFrom != nil && doc.ExpiresAt != nil && !doc.ExpiresAt.After(*doc.EffectiveFrom) {
+		return errors.New("expires_at must be after effective_from")
+	}
+	return nil
+}
+
+func parseDocumentDate(name, value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t, nil
+		}
+	}
+	return nil, errors.New(name + " must be a date (YYYY-MM-DD) or RFC 3339 time")
+}
+
+// handleRAGStaleReport lists documents due for review: expired, expiring
+// within within_days (default 30), not re-approved for too long, or
+// published without a date
+func handleRAGStaleReport(c *gin.Context, aiService *ai.AIService) {
+	days, err := strconv.Atoi(c.DefaultQuery("within_days", "30"))
+	if err != nil || days < 0 {
+		badRequest(c, errors.New("within_days must be a non-negative number"))
+		return
+	}
+
+	stale := aiService.GetRAGDatabase().StaleDocuments(time.Duration(days) * 24 * time.Hour)
+	c.JSON(http.StatusOK, gin.H{"documents": stale, "total": len(stale)})
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"serpico/backend/internal/ai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ragContext is the request context tagged with the signed-in user, who is
+// recorded as the author of any knowledge base change
+func ragContext(c *gin.Context) context.Context {
+	return ai.WithAuthor(c.Request.Context(), c.GetString("userID"))
+}
+
+// versionError maps history lookup errors to responses
+func versionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ai.ErrDocumentNotFound):
+		notFound(c, "Document not found")
+	case errors.Is(err, ai.ErrVersionNotFound):
+		notFound(c, "Version not found")
+	case errors.Is(err, ai.ErrRecordDocument):
+		badRequest(c, err)
+	default:
+		internalError(c, err)
+	}
+}
+
+// handleRAGHistory lists every version of a document, which works for
+// deleted documents too
+func handleRAGHistory(c *gin.Context, aiService *ai.AIService) {
+	id := c.Param("id")
+	versions := aiService.GetRAGDatabase().History(id)
+	if len(versions) == 0 || !versions[0].Document.VisibleTo(ragRole(c)) {
+		notFound(c, "Document not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "versions": versions})
+}
+
+func handleRAGGetVersion(c *gin.Context, aiService *ai.AIService) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		badRequest(c, errors.New("version must be a number"))
+		return
+	}
+	v, err := aiService.GetRAGDatabase().GetVersion(c.Param("id"), version)
+	if err == nil && !v.Document.VisibleTo(ragRole(c)) {
+		err = ai.ErrDocumentNotFound
+	}
+	if err != nil {
+		versionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"version": v})
+}
+
+// handleRAGDiff compares two versions of a document. "to" defaults to the
+// latest version and "from" to the one before "to".
+func handleRAGDiff(c *gin.Context, aiService *ai.AIService) {
+	id := c.Param("id")
+	ragDB := aiService.GetRAGDatabase()
+	versions := ragDB.History(id)
+	latest := len(versions)
+	if latest == 0 || !versions[0].Document.VisibleTo(ragRole(c)) {
+		notFound(c, "Document not found")
+		return
+	}
+
+	to, err := queryVersion(c, "to", latest)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+	from, err := queryVersion(c, "from", to-1)
+	if err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	diff, err := ragDB.DiffVersions(id, from, to)
+	if err != nil {
+		versionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"diff": diff})
+}
+
+func queryVersion(c *gin.Context, name string, fallback int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New(name + " must be a version number")
+	}
+	return version, nil
+}
+
+// handleRAGRollback restores a document to an earlier version, adding it
+// back if it was deleted. The result is a draft, or pending review with
+// submit.
+func handleRAGRollback(c *gin.Context, aiService *ai.AIService) {
+	var req struct {
+		Version int `json:"version" binding:"required"`
+		// Submit sends the restored document for review instead of saving
+		// a draft
+		Submit bool `json:"submit"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+
+	status := ai.StatusDraft
+	if req.Submit {
+		status = ai.StatusPendingReview
+	}
+	doc, err := aiService.GetRAGDatabase().RollbackDocument(ragContext(c), c.Param("id"), req.Version, status)
+	if err != nil {
+		versionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"document": doc})
+}
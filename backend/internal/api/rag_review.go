@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"serpico/backend/internal/ai"
+	"serpico/backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleRAGReview applies a review action to a document, with an optional
+// comment in the body
+func handleRAGReview(c *gin.Context, aiService *ai.AIService, action string) {
+	var req struct {
+		Comment string `json:"comment"`
+	}
+	// The body is optional for actions that don't need a comment
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			badRequest(c, err)
+			return
+		}
+	}
+
+	doc, err := aiService.GetRAGDatabase().ReviewDocument(ragContext(c), c.Param("id"), action, req.Comment)
+	switch {
+	case errors.Is(err, ai.ErrDocumentNotFound):
+		notFound(c, "Document not found")
+	case errors.Is(err, ai.ErrSelfReview):
+		middleware.AbortWithError(c, http.StatusForbidden, middleware.ErrCodeForbidden, err.Error())
+	case errors.Is(err, ai.ErrInvalidTransition), errors.Is(err, ai.ErrCommentRequired), errors.Is(err, ai.ErrRecordDocument):
+		badRequest(c, err)
+	case err != nil:
+		internalError(c, err)
+	default:
+		c.JSON(http.StatusOK, gin.H{"document": doc})
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"serpico/backend/internal/ai"
+	"serpico/backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportBytes caps one import request; exported embeddings make a
+// snapshot several times larger than its text
+const maxImportBytes = 256 << 20
+
+// handleRAGExport streams the knowledge base as JSONL. ?embeddings=true
+// includes chunk vectors, ?records=true documents generated from database
+// records, and ?status= (repeatable) limits the review statuses.
+func handleRAGExport(c *gin.Context, aiService *ai.AIService) {
+	options := ai.ExportOptions{
+		Embeddings: c.Query("embeddings") == "true",
+		Records:    c.Query("records") == "true",
+		Statuses:   c.QueryArray("status"),
+	}
+
+	filename := fmt.Sprintf("rag-export-%s.jsonl", time.Now().UTC().Format("20060102-150405"))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+	if _, err := aiService.GetRAGDatabase().Export(c.Writer, options); err != nil {
+		// The response has started, so the client sees a truncated file
+		middleware.LogInternalError(c, err)
+	}
+}
+
+// handleRAGImport applies a JSONL export sent as the request body.
+// ?mode= is merge or replace, ?on_conflict= is skip, overwrite or rename,
+// and ?dry_run=true reports what would change without changing anything.
+func handleRAGImport(c *gin.Context, aiService *ai.AIService) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	options := ai.ImportOptions{
+		Mode:       c.DefaultQuery("mode", ai.ImportMerge),
+		OnConflict: c.DefaultQuery("on_conflict", ai.ConflictSkip),
+		DryRun:     c.Query("dry_run") == "true",
+	}
+
+	report, err := aiService.GetRAGDatabase().Import(ragContext(c), c.Request.Body, options)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		middleware.AbortWithError(c, http.StatusRequestEntityTooLarge, middleware.ErrCodeBadRequest, fmt.Sprintf("Import exceeds %d MB", maxImportBytes>>20))
+	case errors.Is(err, ai.ErrInvalidImport) && options.DryRun:
+		// Finding the errors is what a dry run is for
+		c.JSON(http.StatusOK, gin.H{"report": report})
+	case errors.Is(err, ai.ErrInvalidImport):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":      fmt.Sprintf("%d invalid lines; nothing was imported", len(report.Errors)),
+			"code":       middleware.ErrCodeBadRequest,
+			"request_id": c.GetString("requestID"),
+			"report":     report,
+		})
+	case errors.Is(err, ai.ErrImportOptions):
+		badRequest(c, err)
+	case err != nil:
+		internalError(c, err)
+	default:
+		c.JSON(http.StatusOK, gin.H{"report": report})
+	}
+}
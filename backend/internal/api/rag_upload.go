@@ -0,0 +1,112 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"serpico/backend/internal/ai"
+	"serpico/backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUploadBytes caps one upload request, all files included
+const maxUploadBytes = 32 << 20
+
+// handleRAGUpload ingests files sent as multipart "file" fields. Each file
+// gets its own status, so one bad file doesn't fail the others.
+func handleRAGUpload(c *gin.Context, aiService *ai.AIService) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadBytes)
+	form, err := c.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			middleware.AbortWithError(c, http.StatusRequestEntityTooLarge, middleware.ErrCodeBadRequest, fmt.Sprintf("Upload exceeds %d MB", maxUploadBytes>>20))
+			return
+		}
+		badRequest(c, err)
+		return
+	}
+
+	files := form.File["file"]
+	if len(files) == 0 {
+		middleware.AbortWithError(c, http.StatusBadRequest, middleware.ErrCodeBadRequest, "at least one file is required")
+		return
+	}
+
+	options := ai.IngestOptions{
+		Category: c.PostForm("category"),
+		Location: c.PostForm("location"),
+		Tags:     splitList(c.PostForm("tags")),
+		CSV: ai.CSVMapping{
+			Title:    c.PostForm("title_column"),
+			Content:  splitList(c.PostForm("content_columns")),
+			Category: c.PostForm("category_column"),
+			Location: c.PostForm("location_column"),
+			Tags:     c.PostForm("tags_column"),
+		},
+		Submit: c.PostForm("submit") == "true",
+	}
+	// A title only makes sense for a single document
+	if len(files) == 1 {
+		options.Title = c.PostForm("title")
+	}
+	if options.Category == "" && options.CSV.Category == "" {
+		middleware.AbortWithError(c, http.StatusBadRequest, middleware.ErrCodeBadRequest, "category is required")
+		return
+	}
+
+	ragDB := aiService.GetRAGDatabase()
+	results := make([]ai.IngestResult, 0, len(files))
+	ingested := 0
+	for _, header := range files {
+		file := ai.UploadedFile{Name: header.Filename, ContentType: header.Header.Get("Content-Type")}
+		reader, err := header.Open()
+		if err == nil {
+			file.Data, err = io.ReadAll(reader)
+			reader.Close()
+		}
+		if err != nil {
+			results = append(results, ai.IngestResult{File: header.Filename, Status: ai.UploadFailed, Error: "failed to read file"})
+			continue
+		}
+
+		result := ragDB.IngestFile(ragContext(c), file, options)
+		if result.Status == ai.UploadIngested {
+			ingested++
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"files": results, "ingested": ingested, "total": len(results)})
+}
+
+// handleRAGDownloadFile returns the uploaded file a document came from
+func handleRAGDownloadFile(c *gin.Context, aiService *ai.AIService) {
+	ragDB := aiService.GetRAGDatabase()
+	doc := ragDB.GetDocumentByID(c.Param("id"))
+	if doc == nil || !ragReadable(c, doc) {
+		notFound(c, "Document not found")
+		return
+	}
+	if doc.Source == nil {
+		notFound(c, "Document was not uploaded from a file")
+		return
+	}
+
+	c.FileAttachment(ragDB.OriginalPath(doc.Source), doc.Source.FileName)
+}
+
+// splitList splits a comma-separated form value, dropping blanks
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
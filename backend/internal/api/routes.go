@@ -1,19 +1,24 @@
 package api
 
 import (
+	"serpico/backend/internal/ai"
 	"serpico/backend/internal/database"
+	"serpico/backend/internal/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(r *gin.RouterGroup, db *database.Database, aiService interface{}) {
+func SetupRoutes(r *gin.RouterGroup, db *database.Database, aiService *ai.AIService, records *ai.RecordSync, limiter *middleware.RateLimiter) {
+	r.Use(middleware.Authenticate(db))
+	r.Use(limiter.Limit("default"))
+
 	// Auth routes (mock)
-	auth := r.Group("/auth")
+	auth := r.Group("/auth", limiter.Limit("auth"))
 	{
-		auth.POST("/login", handleLogin)
-		auth.POST("/login/google", handleGoogleLogin)
-		auth.POST("/login/apple", handleAppleLogin)
-		auth.POST("/logout", handleLogout)
+		auth.POST("/login", func(c *gin.Context) { handleLogin(c, db) })
+		auth.POST("/login/google", func(c *gin.Context) { handleGoogleLogin(c, db) })
+		auth.POST("/login/apple", func(c *gin.Context) { handleAppleLogin(c, db) })
+		auth.POST("/logout", func(c *gin.Context) { handleLogout(c, db) })
 	}
 
 	// User routes
@@ -28,7 +33,7 @@ func SetupRoutes(r *gin.RouterGroup, db *database.Database, aiService interface{
 	{
 		cases.GET("", func(c *gin.Context) { handleGetCases(c, db) })
 		cases.GET("/:id", func(c *gin.Context) { handleGetCase(c, db) })
-		cases.POST("", func(c *gin.Context) { handleCreateCase(c, db) })
+		cases.POST("", func(c *gin.Context) { handleCreateCase(c, db, records) })
 	}
 
 	// Perps routes
@@ -49,14 +54,24 @@ func SetupRoutes(r *gin.RouterGroup, db *database.Database, aiService interface{
 	emergencies := r.Group("/emergencies")
 	{
 		emergencies.GET("", func(c *gin.Context) { handleGetEmergencies(c, db) })
-		emergencies.POST("", func(c *gin.Context) { handleCreateEmergency(c, db) })
+		emergencies.POST("", func(c *gin.Context) { handleCreateEmergency(c, db, records) })
 		emergencies.GET("/:id", func(c *gin.Context) { handleGetEmergency(c, db) })
 	}
 
 	// Chat routes
-	chat := r.Group("/chat")
+	chat := r.Group("/chat", limiter.Limit("chat"))
+	{
+		chat.POST("", func(c *gin.Context) { handleChat(c, db, aiService) })
+		chat.POST("/stream", func(c *gin.Context) { handleChatStream(c, db, aiService) })
+		chat.GET("/modes", func(c *gin.Context) { handleListChatModes(c, aiService) })
+	}
+
+	// Conversation history routes
+	conversations := r.Group("/conversations", middleware.RequireUser())
 	{
-		chat.POST("", func(c *gin.Context) { handleChat(c, aiService) })
+		conversations.GET("", func(c *gin.Context) { handleListConversations(c, db) })
+		conversations.GET("/:id", func(c *gin.Context) { handleGetConversation(c, db) })
+		conversations.DELETE("/:id", func(c *gin.Context) { handleDeleteConversation(c, db) })
 	}
 
 	// Recommendations routes
@@ -69,26 +84,81 @@ func SetupRoutes(r *gin.RouterGroup, db *database.Database, aiService interface{
 	// Admin routes
 	admin := r.Group("/admin")
 	{
-		admin.POST("/login", handleAdminLogin)
+		admin.POST("/login", limiter.Limit("admin_login"), func(c *gin.Context) { handleAdminLogin(c, db) })
 		admin.GET("/cases", func(c *gin.Context) { handleAdminGetAllCases(c, db) })
-		admin.POST("/cases", func(c *gin.Context) { handleAdminCreateCase(c, db) })
+		admin.POST("/cases", func(c *gin.Context) { handleAdminCreateCase(c, db, records) })
 		admin.GET("/perps", func(c *gin.Context) { handleAdminGetAllPerps(c, db) })
-		admin.POST("/perps", func(c *gin.Context) { handleAdminCreatePerp(c, db) })
+		admin.POST("/perps", func(c *gin.Context) { handleAdminCreatePerp(c, db, records) })
 		admin.GET("/officers", func(c *gin.Context) { handleAdminGetAllOfficers(c, db) })
 		admin.POST("/officers", func(c *gin.Context) { handleAdminCreateOfficer(c, db) })
 		admin.GET("/emergencies", func(c *gin.Context) { handleAdminGetAllEmergencies(c, db) })
-		admin.POST("/emergencies", func(c *gin.Context) { handleAdminCreateEmergency(c, db) })
+		admin.POST("/emergencies", func(c *gin.Context) { handleAdminCreateEmergency(c, db, records) })
 		admin.GET("/users", func(c *gin.Context) { handleAdminGetAllUsers(c, db) })
+
+		// Modes set the system prompt, roles and tools chat runs with, so
+		// they're admin only
+		modes := admin.Group("/chat-modes", middleware.RequireRole(ai.ChatRoleAdmin))
+		modes.GET("", func(c *gin.Context) { handleAdminGetChatModes(c, aiService) })
+		modes.GET("/:name", func(c *gin.Context) { handleAdminGetChatMode(c, aiService) })
+		modes.PUT("/:name", func(c *gin.Context) { handleAdminSaveChatMode(c, aiService) })
+
+		// The rejected log holds users' prompts, so the screener is admin only
+		screener := admin.Group("/screener", middleware.RequireRole(ai.ChatRoleAdmin))
+		screener.GET("", func(c *gin.Context) { handleAdminGetScreener(c, aiService) })
+		screener.POST("/reload", func(c *gin.Context) { handleAdminReloadScreener(c, aiService) })
+		screener.POST("/test", func(c *gin.Context) { handleAdminTestScreener(c, aiService) })
+		screener.GET("/rejected", func(c *gin.Context) { handleAdminGetRejectedPrompts(c, aiService) })
+
+		pii := admin.Group("/pii", middleware.RequireRole(ai.ChatRoleAdmin))
+		pii.GET("", func(c *gin.Context) { handleAdminGetPIIPolicy(c, aiService) })
+		pii.POST("/reload", func(c *gin.Context) { handleAdminReloadPIIPolicy(c, aiService) })
+		pii.POST("/test", func(c *gin.Context) { handleAdminTestPIIPolicy(c, aiService) })
+
+		// The audit log holds answers that were withheld, so it's admin only
+		safety := admin.Group("/safety", middleware.RequireRole(ai.ChatRoleAdmin))
+		safety.GET("", func(c *gin.Context) { handleAdminGetSafety(c, aiService) })
+		safety.POST("/reload", func(c *gin.Context) { handleAdminReloadSafety(c, aiService) })
+		safety.POST("/test", func(c *gin.Context) { handleAdminTestSafety(c, aiService) })
+		safety.GET("/audit", func(c *gin.Context) { handleAdminGetSafetyAudit(c, aiService) })
 	}
 
 	// RAG Management routes
-	rag := r.Group("/rag")
+	rag := r.Group("/rag", limiter.Limit("rag"))
 	{
+		rag.GET("/search", func(c *gin.Context) { handleRAGSearch(c, aiService) })
 		rag.GET("/documents", func(c *gin.Context) { handleRAGGetDocuments(c, aiService) })
 		rag.GET("/documents/:id", func(c *gin.Context) { handleRAGGetDocument(c, aiService) })
-		rag.POST("/documents", func(c *gin.Context) { handleRAGCreateDocument(c, aiService) })
-		rag.PUT("/documents/:id", func(c *gin.Context) { handleRAGUpdateDocument(c, aiService) })
-		rag.DELETE("/documents/:id", func(c *gin.Context) { handleRAGDeleteDocument(c, aiService) })
+		rag.GET("/documents/:id/file", func(c *gin.Context) { handleRAGDownloadFile(c, aiService) })
+
+		// History and the stale report show drafts, so they're for the
+		// people who manage the knowledge base
+		manage := rag.Group("", middleware.RequireRole(ai.ChatRoleAdmin, ai.RoleReviewer))
+		manage.GET("/reports/stale", func(c *gin.Context) { handleRAGStaleReport(c, aiService) })
+		manage.GET("/documents/:id/history", func(c *gin.Context) { handleRAGHistory(c, aiService) })
+		manage.GET("/documents/:id/history/:version", func(c *gin.Context) { handleRAGGetVersion(c, aiService) })
+		manage.GET("/documents/:id/diff", func(c *gin.Context) { handleRAGDiff(c, aiService) })
+
+		// Every change is a version recorded with its author. What chat
+		// retrieves is the department's to decide, so only admins edit.
+		edit := rag.Group("", middleware.RequireRole(ai.ChatRoleAdmin))
+		edit.POST("/documents", func(c *gin.Context) { handleRAGCreateDocument(c, aiService) })
+		edit.POST("/upload", func(c *gin.Context) { handleRAGUpload(c, aiService) })
+		edit.PUT("/documents/:id", func(c *gin.Context) { handleRAGUpdateDocument(c, aiService) })
+		edit.DELETE("/documents/:id", func(c *gin.Context) { handleRAGDeleteDocument(c, aiService) })
+		edit.POST("/documents/:id/rollback", func(c *gin.Context) { handleRAGRollback(c, aiService) })
+		edit.POST("/documents/:id/submit", func(c *gin.Context) { handleRAGReview(c, aiService, ai.VersionSubmitted) })
+		edit.POST("/documents/:id/archive", func(c *gin.Context) { handleRAGReview(c, aiService, ai.VersionArchived) })
+
+		// An export holds every draft and, on request, the record
+		// documents. Imported documents keep their status, so an import can
+		// publish or delete anything. Only admins may run either.
+		rag.GET("/export", middleware.RequireRole(ai.ChatRoleAdmin), func(c *gin.Context) { handleRAGExport(c, aiService) })
+		rag.POST("/import", middleware.RequireRole(ai.ChatRoleAdmin), func(c *gin.Context) { handleRAGImport(c, aiService) })
+
+		// Publishing is left to reviewers, so an edit can't go live unchecked
+		review := rag.Group("", middleware.RequireRole(ai.RoleReviewer))
+		review.POST("/documents/:id/approve", func(c *gin.Context) { handleRAGReview(c, aiService, ai.VersionApproved) })
+		review.POST("/documents/:id/reject", func(c *gin.Context) { handleRAGReview(c, aiService, ai.VersionRejected) })
 	}
 }
 
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"serpico/backend/internal/ai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleAdminGetSafety returns the output safety filter's rules
+func handleAdminGetSafety(c *gin.Context, aiService *ai.AIService) {
+	c.JSON(http.StatusOK, gin.H{"config": aiService.Output().Config()})
+}
+
+// handleAdminReloadSafety rereads the rules file after it's been edited
+func handleAdminReloadSafety(c *gin.Context, aiService *ai.AIService) {
+	if err := aiService.Output().Reload(); err != nil {
+		// The file is the admin's to fix, so say what's wrong with it
+		badRequest(c, err)
+		return
+	}
+	handleAdminGetSafety(c, aiService)
+}
+
+// handleAdminTestSafety checks an answer as chat would for a role, without
+// auditing or counting it, and explains the verdict
+func handleAdminTestSafety(c *gin.Context, aiService *ai.AIService) {
+	var req struct {
+		Answer   string `json:"answer" binding:"required"`
+		Question string `json:"question"`
+		Role     string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = ai.ChatRoleCivilian
+	}
+	c.JSON(http.StatusOK, gin.H{"role": req.Role, "review": aiService.Output().Review(c.Request.Context(), req.Role, req.Question, req.Answer)})
+}
+
+// handleAdminGetSafetyAudit lists recently blocked or amended answers,
+// newest first, with the reasons for each
+func handleAdminGetSafetyAudit(c *gin.Context, aiService *ai.AIService) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	entries, err := aiService.Output().Audit(limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "total": len(entries)})
+}
+
+// safetySummary is what a chat client is told about the output safety
+// filter's verdict: the action and reason codes, without the details of
+// what was withheld
+func safetySummary(review *ai.SafetyReview) gin.H {
+	if review == nil {
+		return nil
+	}
+	reasons := make([]string, 0, len(review.Reasons))
+	seen := make(map[string]bool)
+	for _, reason := range review.Reasons {
+		if !seen[reason.Code] {
+			seen[reason.Code] = true
+			reasons = append(reasons, reason.Code)
+		}
+	}
+	return gin.H{"action": review.Action, "reasons": reasons}
+}
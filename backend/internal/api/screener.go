@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"serpico/backend/internal/ai"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleAdminGetScreener returns the screener's rules and how many
+// examples its classifier was trained on
+func handleAdminGetScreener(c *gin.Context, aiService *ai.AIService) {
+	config, examples := aiService.Screener().Config()
+	c.JSON(http.StatusOK, gin.H{"config": config, "examples": examples})
+}
+
+// handleAdminReloadScreener rereads the rules and examples files after
+// they've been edited
+func handleAdminReloadScreener(c *gin.Context, aiService *ai.AIService) {
+	if err := aiService.Screener().Reload(); err != nil {
+		// The files are the admin's to fix, so say what's wrong with them
+		badRequest(c, err)
+		return
+	}
+	handleAdminGetScreener(c, aiService)
+}
+
+// handleAdminTestScreener screens a prompt as chat would, without logging
+// or counting a rejection, and explains the decision
+func handleAdminTestScreener(c *gin.Context, aiService *ai.AIService) {
+	var req struct {
+		Prompt string `json:"prompt" binding:"required"`
+		Mode   string `json:"mode"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err)
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = ai.DefaultChatMode
+	}
+	mode, ok := aiService.Modes().Get(req.Mode)
+	if !ok {
+		notFound(c, "Chat mode not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mode": mode.Name, "decision": aiService.Screener().Screen(req.Prompt, mode.Screener)})
+}
+
+// handleAdminGetRejectedPrompts lists recently rejected prompts, newest
+// first, for tuning the rules and examples
+func handleAdminGetRejectedPrompts(c *gin.Context, aiService *ai.AIService) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		limit = 100
+	}
+	rejected, err := aiService.Screener().Rejected(limit)
+	if err != nil {
+		internalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"prompts": rejected, "total": len(rejected)})
+}
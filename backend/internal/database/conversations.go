@@ -0,0 +1,161 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// timestampLayout is RFC 3339 with a fixed nine fractional digits, so
+// timestamps sort correctly as text. time.RFC3339Nano drops trailing
+// zeros, which puts "05.1Z" after "05.12Z".
+const timestampLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// ErrConversationNotFound is returned when a conversation doesn't exist or
+// belongs to another user
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// Conversation is a stored chat thread
+type Conversation struct {
+	ID        string `json:"id"`
+	UserID    string `json:"user_id"`
+	Title     string `json:"title"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// ConversationMessage is one stored turn
+type ConversationMessage struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversation_id"`
+	Role           string `json:"role"`
+	Content        string `json:"content"`
+	CreatedAt      string `json:"created_at"`
+}
+
+// CreateConversation starts a new conversation for userID
+func (d *Database) CreateConversation(userID, title string) (*Conversation, error) {
+	now := time.Now().UTC().Format(timestampLayout)
+	conv := &Conversation{
+		ID:        "conv-" + uuid.New().String(),
+		UserID:    userID,
+		Title:     title,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	_, err := d.Exec("INSERT INTO conversations (id, user_id, title, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		conv.ID, conv.UserID, conv.Title, conv.CreatedAt, conv.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// GetConversation returns userID's conversation by ID
+func (d *Database) GetConversation(userID, id string) (*Conversation, error) {
+	conv := &Conversation{}
+	err := d.QueryRow("SELECT id, user_id, title, created_at, updated_at FROM conversations WHERE id = ? AND user_id = ?", id, userID).
+		Scan(&conv.ID, &conv.UserID, &conv.Title, &conv.CreatedAt, &conv.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// ListConversations returns userID's conversations, most recent first
+func (d *Database) ListConversations(userID string) ([]Conversation, error) {
+	rows, err := d.Query("SELECT id, user_id, title, created_at, updated_at FROM conversations WHERE user_id = ? ORDER BY updated_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []Conversation{}
+	for rows.Next() {
+		var conv Conversation
+		if err := rows.Scan(&conv.ID, &conv.UserID, &conv.Title, &conv.CreatedAt, &conv.UpdatedAt); err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conv)
+	}
+	return conversations, rows.Err()
+}
+
+// DeleteConversation removes userID's conversation and its messages
+func (d *Database) DeleteConversation(userID, id string) error {
+	tx, err := d.SQLite.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM conversations WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrConversationNotFound
+	}
+
+	// SQLite only enforces ON DELETE CASCADE with foreign_keys enabled, so
+	// delete messages explicitly
+	if _, err := tx.Exec("DELETE FROM conversation_messages WHERE conversation_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetConversationMessages returns a conversation's turns in order
+func (d *Database) GetConversationMessages(conversationID string) ([]ConversationMessage, error) {
+	rows, err := d.Query("SELECT id, conversation_id, role, content, created_at FROM conversation_messages WHERE conversation_id = ? ORDER BY created_at, rowid", conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []ConversationMessage{}
+	for rows.Next() {
+		var msg ConversationMessage
+		if err := rows.Scan(&msg.ID, &msg.ConversationID, &msg.Role, &msg.Content, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+// AppendConversationMessages stores turns and bumps the conversation's
+// updated_at in one transaction
+func (d *Database) AppendConversationMessages(conversationID string, messages ...ConversationMessage) error {
+	tx, err := d.SQLite.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC().Format(timestampLayout)
+	for _, msg := range messages {
+		if msg.ID == "" {
+			msg.ID = "msg-" + uuid.New().String()
+		}
+		if msg.CreatedAt == "" {
+			msg.CreatedAt = now
+		}
+		_, err := tx.Exec("INSERT INTO conversation_messages (id, conversation_id, role, content, created_at) VALUES (?, ?, ?, ?, ?)",
+			msg.ID, conversationID, msg.Role, msg.Content, msg.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE conversations SET updated_at = ? WHERE id = ?", now, conversationID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -1,8 +1,10 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
 	"os"
 	"path/filepath"
 
@@ -36,7 +38,7 @@ func Initialize() (*Database, error) {
 
 	// Seed database with mock data
 	if err := SeedDatabase(db); err != nil {
-		log.Printf("Warning: Failed to seed database: %v", err)
+		slog.Warn("failed to seed database", "error", err)
 	}
 
 	// Initialize BadgerDB for caching
@@ -46,7 +48,7 @@ func Initialize() (*Database, error) {
 		return nil, err
 	}
 
-	log.Println("Database initialized successfully")
+	slog.Info("database initialized successfully")
 
 	return &Database{
 		SQLite: db,
@@ -68,6 +70,27 @@ func (d *Database) Close() error {
 	return nil
 }
 
+// PingSQLite checks that SQLite is reachable and its schema is readable,
+// which fails while another connection holds an exclusive lock
+func (d *Database) PingSQLite(ctx context.Context) error {
+	var tables int
+	return d.SQLite.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master").Scan(&tables)
+}
+
+// PingCache checks that Badger is open and can serve a read
+func (d *Database) PingCache(ctx context.Context) error {
+	if d.Cache.IsClosed() {
+		return errors.New("badger is closed")
+	}
+	return d.Cache.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte("health:probe"))
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
 func createTables(db *sql.DB) error {
 	queries := []string{
 		`CREATE TABLE IF NOT EXISTS users (
@@ -103,6 +126,7 @@ func createTables(db *sql.DB) error {
 			vehicle_plate TEXT,
 			vehicle_number TEXT,
 			current_location TEXT,
+			area TEXT,
 			status TEXT NOT NULL,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
@@ -116,6 +140,22 @@ func createTables(db *sql.DB) error {
 			status TEXT NOT NULL,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
+		`CREATE TABLE IF NOT EXISTS conversations (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			title TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_conversations_user ON conversations (user_id, updated_at)`,
+		`CREATE TABLE IF NOT EXISTS conversation_messages (
+			id TEXT PRIMARY KEY,
+			conversation_id TEXT NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+			role TEXT NOT NULL,
+			content TEXT NOT NULL,
+			created_at TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_conversation_messages_conversation ON conversation_messages (conversation_id, created_at)`,
 	}
 
 	for _, query := range queries {
@@ -124,6 +164,30 @@ func createTables(db *sql.DB) error {
 		}
 	}
 
-	return nil
+	// Columns added after the table was first created
+	return addColumn(db, "officers", "area", "TEXT")
+}
+
+// addColumn adds a column to a table created before it existed
+func addColumn(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	_, err = db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition)
+	return err
 }
 
@@ -0,0 +1,349 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Read-only lookups used by the AI assistant's tools. Officers' personal
+// details such as vehicle plates are deliberately left out.
+
+// Case is a row of the cases table
+type Case struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Location    string `json:"location"`
+	Date        string `json:"date"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+	Solved      bool   `json:"solved"`
+}
+
+// Perp is a row of the perps table with its related case count
+type Perp struct {
+	ID        string `json:"id"`
+	Alias     string `json:"alias"`
+	Location  string `json:"location"`
+	LastSeen  string `json:"last_seen"`
+	Status    string `json:"status"`
+	CaseCount int    `json:"case_count"`
+}
+
+// Officer is an on-duty officer's public information
+type Officer struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Rank            string `json:"rank"`
+	VehicleNumber   string `json:"vehicle_number"`
+	CurrentLocation string `json:"current_location"`
+	// Area is the part of the city the officer patrols
+	Area   string `json:"area"`
+	Status string `json:"status"`
+}
+
+// OfficerRecord is an officer's row including details kept from the
+// public, such as the vehicle plate
+type OfficerRecord struct {
+	Officer
+	VehiclePlate string `json:"vehicle_plate"`
+}
+
+// Emergency is a row of the emergencies table
+type Emergency struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Location  string `json:"location"`
+	Priority  string `json:"priority"`
+	Category  string `json:"category"`
+	Status    string `json:"status"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CrimeStats summarizes cases in an area
+type CrimeStats struct {
+	Area   string         `json:"area"`
+	Total  int            `json:"total"`
+	Solved int            `json:"solved"`
+	Open   int            `json:"open"`
+	ByType map[string]int `json:"by_type"`
+}
+
+func likePattern(s string) string {
+	return "%" + strings.TrimSpace(s) + "%"
+}
+
+// SearchCases finds cases whose type, location or description match query,
+// optionally filtered by status
+func (d *Database) SearchCases(query, status string, limit int) ([]Case, error) {
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+
+	rows, err := d.Query(`SELECT id, type, location, date, status, COALESCE(description, ''), solved FROM cases
+		WHERE (type LIKE ? OR location LIKE ? OR description LIKE ?) AND (? = '' OR status = ?)
+		ORDER BY date DESC LIMIT ?`,
+		likePattern(query), likePattern(query), likePattern(query), status, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cases := []Case{}
+	for rows.Next() {
+		var c Case
+		var solved int
+		if err := rows.Scan(&c.ID, &c.Type, &c.Location, &c.Date, &c.Status, &c.Description, &solved); err != nil {
+			return nil, err
+		}
+		c.Solved = solved == 1
+		cases = append(cases, c)
+	}
+	return cases, rows.Err()
+}
+
+// FindPerp looks up a perp by ID or alias
+func (d *Database) FindPerp(idOrAlias string) (*Perp, error) {
+	p := &Perp{}
+	err := d.QueryRow(`SELECT id, alias, COALESCE(location, ''), COALESCE(last_seen, ''), status FROM perps
+		WHERE id = ? OR alias LIKE ? ORDER BY last_seen DESC LIMIT 1`, idOrAlias, idOrAlias).
+		Scan(&p.ID, &p.Alias, &p.Location, &p.LastSeen, &p.Status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Location != "" {
+		if err := d.QueryRow("SELECT COUNT(*) FROM cases WHERE location LIKE ?", likePattern(p.Location)).Scan(&p.CaseCount); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// OnDutyOfficers returns on-duty officers, optionally those patrolling an
+// area
+func (d *Database) OnDutyOfficers(area string, limit int) ([]Officer, error) {
+	if limit <= 0 || limit > 20 {
+		limit = 5
+	}
+
+	rows, err := d.Query(`SELECT id, name, rank, COALESCE(vehicle_number, ''), COALESCE(current_location, ''), COALESCE(area, ''), status FROM officers
+		WHERE status IN ('On Duty', 'On Patrol') AND (? = '' OR area LIKE ?) LIMIT ?`,
+		area, likePattern(area), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	officers := []Officer{}
+	for rows.Next() {
+		var o Officer
+		if err := rows.Scan(&o.ID, &o.Name, &o.Rank, &o.VehicleNumber, &o.CurrentLocation, &o.Area, &o.Status); err != nil {
+			return nil, err
+		}
+		officers = append(officers, o)
+	}
+	return officers, rows.Err()
+}
+
+// AllOfficers returns every officer, on duty or not, with their vehicle
+// plate
+func (d *Database) AllOfficers() ([]OfficerRecord, error) {
+	rows, err := d.Query(`SELECT id, name, rank, COALESCE(vehicle_number, ''), COALESCE(current_location, ''), COALESCE(area, ''), status,
+		COALESCE(vehicle_plate, '') FROM officers ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	officers := []OfficerRecord{}
+	for rows.Next() {
+		var o OfficerRecord
+		if err := rows.Scan(&o.ID, &o.Name, &o.Rank, &o.VehicleNumber, &o.CurrentLocation, &o.Area, &o.Status, &o.VehiclePlate); err != nil {
+			return nil, err
+		}
+		officers = append(officers, o)
+	}
+	return officers, rows.Err()
+}
+
+// ActiveEmergencies returns active emergencies, optionally in an area
+func (d *Database) ActiveEmergencies(area string) ([]Emergency, error) {
+	rows, err := d.Query(`SELECT id, type, location, priority, category, status, COALESCE(created_at, '') FROM emergencies
+		WHERE status = 'Active' AND (? = '' OR location LIKE ?) ORDER BY created_at DESC`,
+		area, likePattern(area))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emergencies := []Emergency{}
+	for rows.Next() {
+		var e Emergency
+		if err := rows.Scan(&e.ID, &e.Type, &e.Location, &e.Priority, &e.Category, &e.Status, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		emergencies = append(emergencies, e)
+	}
+	return emergencies, rows.Err()
+}
+
+// CrimeStatsByArea counts cases by type for locations matching area
+func (d *Database) CrimeStatsByArea(area string) (*CrimeStats, error) {
+	rows, err := d.Query(`SELECT type, COUNT(*), SUM(solved) FROM cases WHERE location LIKE ? GROUP BY type`, likePattern(area))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := &CrimeStats{Area: area, ByType: map[string]int{}}
+	for rows.Next() {
+		var caseType string
+		var count, solved int
+		if err := rows.Scan(&caseType, &count, &solved); err != nil {
+			return nil, err
+		}
+		stats.ByType[caseType] = count
+		stats.Total += count
+		stats.Solved += solved
+	}
+	stats.Open = stats.Total - stats.Solved
+	return stats, rows.Err()
+}
+
+// Full-table reads used to mirror records into the RAG knowledge base
+
+// rowScanner is a *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const caseColumns = `id, type, location, date, status, COALESCE(description, ''), solved`
+
+func scanCase(row rowScanner) (Case, error) {
+	var c Case
+	var solved int
+	err := row.Scan(&c.ID, &c.Type, &c.Location, &c.Date, &c.Status, &c.Description, &solved)
+	c.Solved = solved == 1
+	return c, err
+}
+
+// AllCases returns every case, oldest first
+func (d *Database) AllCases() ([]Case, error) {
+	rows, err := d.Query(`SELECT ` + caseColumns + ` FROM cases ORDER BY date, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cases := []Case{}
+	for rows.Next() {
+		c, err := scanCase(rows)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	return cases, rows.Err()
+}
+
+// GetCase returns a case by ID, or nil if there's none
+func (d *Database) GetCase(id string) (*Case, error) {
+	c, err := scanCase(d.QueryRow(`SELECT `+caseColumns+` FROM cases WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+const perpColumns = `id, alias, COALESCE(location, ''), COALESCE(last_seen, ''), status`
+
+func scanPerp(row rowScanner) (Perp, error) {
+	var p Perp
+	err := row.Scan(&p.ID, &p.Alias, &p.Location, &p.LastSeen, &p.Status)
+	return p, err
+}
+
+// AllPerps returns every perp, without case counts
+func (d *Database) AllPerps() ([]Perp, error) {
+	rows, err := d.Query(`SELECT ` + perpColumns + ` FROM perps ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perps := []Perp{}
+	for rows.Next() {
+		p, err := scanPerp(rows)
+		if err != nil {
+			return nil, err
+		}
+		perps = append(perps, p)
+	}
+	return perps, rows.Err()
+}
+
+// GetPerp returns a perp by ID, without its case count, or nil if there's
+// none
+func (d *Database) GetPerp(id string) (*Perp, error) {
+	p, err := scanPerp(d.QueryRow(`SELECT `+perpColumns+` FROM perps WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+const emergencyColumns = `id, type, location, priority, category, status, COALESCE(created_at, '')`
+
+func scanEmergency(row rowScanner) (Emergency, error) {
+	var e Emergency
+	err := row.Scan(&e.ID, &e.Type, &e.Location, &e.Priority, &e.Category, &e.Status, &e.CreatedAt)
+	return e, err
+}
+
+// AllEmergencies returns every emergency regardless of status
+func (d *Database) AllEmergencies() ([]Emergency, error) {
+	rows, err := d.Query(`SELECT ` + emergencyColumns + ` FROM emergencies ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emergencies := []Emergency{}
+	for rows.Next() {
+		e, err := scanEmergency(rows)
+		if err != nil {
+			return nil, err
+		}
+		emergencies = append(emergencies, e)
+	}
+	return emergencies, rows.Err()
+}
+
+// GetEmergency returns an emergency by ID, or nil if there's none
+func (d *Database) GetEmergency(id string) (*Emergency, error) {
+	e, err := scanEmergency(d.QueryRow(`SELECT `+emergencyColumns+` FROM emergencies WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// LatestCaseDate returns the date of the most recent case, or "" if there
+// are none
+func (d *Database) LatestCaseDate() (string, error) {
+	var date string
+	err := d.QueryRow(`SELECT COALESCE(MAX(date), '') FROM cases`).Scan(&date)
+	return date, err
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+
+	"serpico/backend/internal/metrics"
+)
+
+var queryTable = regexp.MustCompile(`(?i)\b(?:FROM|INTO|UPDATE)\s+([A-Za-z_][A-Za-z0-9_]*)`)
+
+// Query runs a SQLite query and records its latency
+func (d *Database) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := d.SQLite.Query(query, args...)
+	observeQuery(query, start, err)
+	return rows, err
+}
+
+// QueryRow runs a single-row SQLite query and records its latency
+func (d *Database) QueryRow(query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := d.SQLite.QueryRow(query, args...)
+	observeQuery(query, start, row.Err())
+	return row
+}
+
+// Exec runs a SQLite statement and records its latency
+func (d *Database) Exec(query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := d.SQLite.Exec(query, args...)
+	observeQuery(query, start, err)
+	return result, err
+}
+
+func observeQuery(query string, start time.Time, err error) {
+	operation := "unknown"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToLower(fields[0])
+	}
+
+	table := "unknown"
+	if match := queryTable.FindStringSubmatch(query); match != nil {
+		table = strings.ToLower(match[1])
+	}
+
+	metrics.ObserveDBQuery(operation, table, time.Since(start), err)
+}
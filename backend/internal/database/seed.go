@@ -2,7 +2,7 @@ package database
 
 import (
 	"database/sql"
-	"log"
+	"log/slog"
 	"time"
 )
 
@@ -12,11 +12,12 @@ func SeedDatabase(db *sql.DB) error {
 	var count int
 	err := db.QueryRow("SELECT COUNT(*) FROM cases").Scan(&count)
 	if err == nil && count > 0 {
-		log.Println("Database already contains data, skipping seed")
+		slog.Info("database already contains data, skipping seed")
+		seedOfficerAreas(db)
 		return nil
 	}
 
-	log.Println("Seeding database with Olathe PD mock data...")
+	slog.Info("seeding database with Olathe PD mock data")
 
 	// Seed Cases
 	if err := seedCases(db); err != nil {
@@ -43,7 +44,7 @@ func SeedDatabase(db *sql.DB) error {
 		return err
 	}
 
-	log.Println("Database seeded successfully")
+	slog.Info("database seeded successfully")
 	return nil
 }
 
@@ -78,7 +79,7 @@ func seedCases(db *sql.DB) error {
 	for _, c := range cases {
 		_, err := stmt.Exec(c.id, c.caseType, c.location, c.date, c.status, c.description, c.solved)
 		if err != nil {
-			log.Printf("Error seeding case %s: %v", c.id, err)
+			slog.Warn("error seeding case", "id", c.id, "error", err)
 		}
 	}
 
@@ -114,44 +115,46 @@ func seedPerps(db *sql.DB) error {
 	for _, p := range perps {
 		_, err := stmt.Exec(p.id, p.alias, p.location, p.lastSeen, p.status)
 		if err != nil {
-			log.Printf("Error seeding perp %s: %v", p.id, err)
+			slog.Warn("error seeding perp", "id", p.id, "error", err)
 		}
 	}
 
 	return nil
 }
 
-func seedOfficers(db *sql.DB) error {
-	// Olathe PD officers with Olathe coordinates
-	officers := []struct {
-		id              string
-		name            string
-		rank            string
-		vehiclePlate    string
-		vehicleNumber   string
-		currentLocation string
-		status          string
-	}{
-		{"officer-001", "Officer Sarah Smith", "Sergeant", "OPD-1234", "1234", "38.8814,-94.8191", "On Duty"},
-		{"officer-002", "Officer Michael Johnson", "Officer", "OPD-5678", "5678", "38.8914,-94.8091", "On Duty"},
-		{"officer-003", "Officer Emily Davis", "Lieutenant", "OPD-9012", "9012", "38.8714,-94.8291", "On Duty"},
-		{"officer-004", "Officer James Wilson", "Officer", "OPD-3456", "3456", "38.9014,-94.7991", "On Patrol"},
-		{"officer-005", "Officer Lisa Anderson", "Sergeant", "OPD-7890", "7890", "38.8614,-94.8391", "On Duty"},
-		{"officer-006", "Officer Robert Brown", "Officer", "OPD-2468", "2468", "38.9114,-94.7891", "On Patrol"},
-		{"officer-007", "Officer Jennifer Martinez", "Captain", "OPD-1357", "1357", "38.8514,-94.8491", "On Duty"},
-		{"officer-008", "Officer David Taylor", "Officer", "OPD-8024", "8024", "38.9214,-94.7791", "On Patrol"},
-	}
+// seededOfficers are Olathe PD officers with Olathe coordinates and the
+// area they patrol
+var seededOfficers = []struct {
+	id              string
+	name            string
+	rank            string
+	vehiclePlate    string
+	vehicleNumber   string
+	currentLocation string
+	area            string
+	status          string
+}{
+	{"officer-001", "Officer Sarah Smith", "Sergeant", "OPD-1234", "1234", "38.8814,-94.8191", "Downtown Olathe", "On Duty"},
+	{"officer-002", "Officer Michael Johnson", "Officer", "OPD-5678", "5678", "38.8914,-94.8091", "North Olathe", "On Duty"},
+	{"officer-003", "Officer Emily Davis", "Lieutenant", "OPD-9012", "9012", "38.8714,-94.8291", "South Olathe", "On Duty"},
+	{"officer-004", "Officer James Wilson", "Officer", "OPD-3456", "3456", "38.9014,-94.7991", "East Olathe", "On Patrol"},
+	{"officer-005", "Officer Lisa Anderson", "Sergeant", "OPD-7890", "7890", "38.8614,-94.8391", "West Olathe", "On Duty"},
+	{"officer-006", "Officer Robert Brown", "Officer", "OPD-2468", "2468", "38.9114,-94.7891", "North Olathe", "On Patrol"},
+	{"officer-007", "Officer Jennifer Martinez", "Captain", "OPD-1357", "1357", "38.8514,-94.8491", "South Olathe", "On Duty"},
+	{"officer-008", "Officer David Taylor", "Officer", "OPD-8024", "8024", "38.9214,-94.7791", "East Olathe", "On Patrol"},
+}
 
-	stmt, err := db.Prepare(`INSERT OR IGNORE INTO officers (id, name, rank, vehicle_plate, vehicle_number, current_location, status) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+func seedOfficers(db *sql.DB) error {
+	stmt, err := db.Prepare(`INSERT OR IGNORE INTO officers (id, name, rank, vehicle_plate, vehicle_number, current_location, area, status) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	for _, o := range officers {
-		_, err := stmt.Exec(o.id, o.name, o.rank, o.vehiclePlate, o.vehicleNumber, o.currentLocation, o.status)
+	for _, o := range seededOfficers {
+		_, err := stmt.Exec(o.id, o.name, o.rank, o.vehiclePlate, o.vehicleNumber, o.currentLocation, o.area, o.status)
 		if err != nil {
-			log.Printf("Error seeding officer %s: %v", o.id, err)
+			slog.Warn("error seeding officer", "id", o.id, "error", err)
 		}
 	}
 
@@ -189,7 +192,7 @@ func seedEmergencies(db *sql.DB) error {
 	for _, e := range emergencies {
 		_, err := stmt.Exec(e.id, e.emergencyType, e.location, e.priority, e.category, e.assignedOfficer, e.status, e.createdAt.Format(time.RFC3339))
 		if err != nil {
-			log.Printf("Error seeding emergency %s: %v", e.id, err)
+			slog.Warn("error seeding emergency", "id", e.id, "error", err)
 		}
 	}
 
@@ -218,9 +221,19 @@ func seedUsers(db *sql.DB) error {
 	for _, u := range users {
 		_, err := stmt.Exec(u.id, u.email, u.name, u.role, u.rank)
 		if err != nil {
-			log.Printf("Error seeding user %s: %v", u.id, err)
+			slog.Warn("error seeding user", "id", u.id, "error", err)
 		}
 	}
 
 	return nil
 }
+
+// seedOfficerAreas fills in the area of officers seeded before the column
+// existed
+func seedOfficerAreas(db *sql.DB) {
+	for _, o := range seededOfficers {
+		if _, err := db.Exec(`UPDATE officers SET area = ? WHERE id = ? AND area IS NULL`, o.area, o.id); err != nil {
+			slog.Warn("error seeding officer area", "id", o.id, "error", err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// sessionTTL is how long a login token stays valid
+const sessionTTL = 24 * time.Hour
+
+// ErrSessionNotFound is returned for unknown or expired tokens
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is the user behind a login token
+type Session struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Rank   string `json:"rank,omitempty"`
+}
+
+func sessionKey(token string) []byte {
+	return []byte("session:" + token)
+}
+
+// CreateSession stores a session for token in Badger
+func (d *Database) CreateSession(token string, session Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return d.Cache.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(badger.NewEntry(sessionKey(token), data).WithTTL(sessionTTL))
+	})
+}
+
+// GetSession looks up the session for token
+func (d *Database) GetSession(token string) (*Session, error) {
+	var session Session
+	err := d.Cache.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(sessionKey(token))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &session)
+		})
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// DeleteSession invalidates token
+func (d *Database) DeleteSession(token string) error {
+	return d.Cache.Update(func(txn *badger.Txn) error {
+		return txn.Delete(sessionKey(token))
+	})
+}
@@ -0,0 +1,164 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Component and overall statuses
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+// Check is a single readiness probe
+type Check struct {
+	Name string
+	// Critical checks mark the service down when they fail; the rest only
+	// mark it degraded.
+	Critical bool
+	Timeout  time.Duration
+	// CacheFor reuses the last result for expensive probes (e.g. the LLM
+	// provider) so frequent health polling doesn't hit them every time.
+	CacheFor time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// ComponentStatus is the result of one check
+type ComponentStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	// Error says only whether the check failed or timed out; the cause,
+	// which can hold URLs or credentials, is logged instead
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the readiness response body
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+	Timestamp  time.Time                  `json:"timestamp"`
+}
+
+// Checker runs registered checks concurrently
+type Checker struct {
+	checks []Check
+
+	mu    sync.Mutex
+	cache map[string]ComponentStatus
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		cache: make(map[string]ComponentStatus),
+	}
+}
+
+// Register adds a check. Call before serving requests.
+func (h *Checker) Register(check Check) {
+	if check.Timeout <= 0 {
+		check.Timeout = 2 * time.Second
+	}
+	h.checks = append(h.checks, check)
+}
+
+// Run executes all checks and summarizes them
+func (h *Checker) Run(ctx context.Context) Report {
+	report := Report{
+		Status:     StatusOK,
+		Components: make(map[string]ComponentStatus, len(h.checks)),
+		Timestamp:  time.Now(),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := h.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[check.Name] = result
+			if result.Status == StatusOK {
+				return
+			}
+			if check.Critical {
+				report.Status = StatusDown
+			} else if report.Status == StatusOK {
+				report.Status = StatusDegraded
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (h *Checker) run(ctx context.Context, check Check) ComponentStatus {
+	if check.CacheFor > 0 {
+		h.mu.Lock()
+		cached, ok := h.cache[check.Name]
+		h.mu.Unlock()
+		if ok && time.Since(cached.CheckedAt) < check.CacheFor {
+			return cached
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Run(ctx)
+	result := ComponentStatus{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		result.Status = StatusDown
+		if !check.Critical {
+			result.Status = StatusDegraded
+		}
+		result.Error = "check failed"
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "timed out"
+		}
+		slog.WarnContext(ctx, "health check failed", "check", check.Name, "error", err)
+	}
+
+	if check.CacheFor > 0 {
+		h.mu.Lock()
+		h.cache[check.Name] = result
+		h.mu.Unlock()
+	}
+
+	return result
+}
+
+// Live reports that the process is up and serving HTTP. It never touches
+// dependencies so a slow database can't get the instance restarted.
+func (h *Checker) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": StatusOK})
+}
+
+// Ready reports per-component status. Degraded still returns 200 so the
+// instance keeps receiving traffic; down returns 503.
+func (h *Checker) Ready(c *gin.Context) {
+	report := h.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,240 @@
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "serpico"
+
+var (
+	// HTTP
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// SQLite
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "SQLite query latency by operation and table.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "table"})
+
+	dbQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "SQLite query errors by operation and table.",
+	}, []string{"operation", "table"})
+
+	// LLM
+	llmRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_requests_total",
+		Help:      "LLM provider calls by provider, model and outcome.",
+	}, []string{"provider", "model", "outcome"})
+
+	llmDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "llm_request_duration_seconds",
+		Help:      "LLM provider call latency by provider and model.",
+		Buckets:   []float64{.1, .25, .5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"provider", "model"})
+
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "llm_tokens_total",
+		Help:      "LLM tokens used by provider, model and type (prompt or completion).",
+	}, []string{"provider", "model", "type"})
+
+	embeddingRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "embedding_requests_total",
+		Help:      "Embedding provider calls by provider and outcome.",
+	}, []string{"provider", "outcome"})
+
+	embeddingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "embedding_request_duration_seconds",
+		Help:      "Embedding provider call latency by provider.",
+		Buckets:   []float64{.05, .1, .25, .5, 1, 2, 4, 8, 15, 30},
+	}, []string{"provider"})
+
+	// RAG
+	ragSearches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rag_searches_total",
+		Help:      "RAG searches by result (hit when at least one document was retrieved, miss otherwise).",
+	}, []string{"result"})
+
+	ragDocumentsRetrieved = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rag_documents_retrieved",
+		Help:      "Number of documents returned per RAG search.",
+		Buckets:   []float64{0, 1, 2, 3, 5, 10, 20},
+	})
+
+	// Screener
+	screenerRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "screener_rejections_total",
+		Help:      "Prompts rejected by the screener, by reason.",
+	}, []string{"reason"})
+	promptInjections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "prompt_injections_total",
+		Help:      "Prompt injection attempts detected, by where the text came from and the rule matched.",
+	}, []string{"source", "rule"})
+	piiFound = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pii_found_total",
+		Help:      "Personal information found in text bound for a provider, by type and the action taken.",
+	}, []string{"type", "action"})
+	outputReviews = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "output_reviews_total",
+		Help:      "Reasons the output safety filter blocked or amended an answer, by reason and role.",
+	}, []string{"reason", "role"})
+)
+
+// ObserveHTTPRequest records one handled HTTP request
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+// ObserveDBQuery records one SQLite query
+func ObserveDBQuery(operation, table string, duration time.Duration, err error) {
+	dbQueryDuration.WithLabelValues(operation, table).Observe(duration.Seconds())
+	if err != nil {
+		dbQueryErrors.WithLabelValues(operation, table).Inc()
+	}
+}
+
+// ObserveLLMRequest records one LLM provider call and its token usage
+func ObserveLLMRequest(provider, model string, duration time.Duration, promptTokens, completionTokens int, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	llmRequests.WithLabelValues(provider, model, outcome).Inc()
+	llmDuration.WithLabelValues(provider, model).Observe(duration.Seconds())
+	if promptTokens > 0 {
+		llmTokens.WithLabelValues(provider, model, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		llmTokens.WithLabelValues(provider, model, "completion").Add(float64(completionTokens))
+	}
+}
+
+// ObserveEmbeddingRequest records one embedding provider call
+func ObserveEmbeddingRequest(provider string, duration time.Duration, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	embeddingRequests.WithLabelValues(provider, outcome).Inc()
+	embeddingDuration.WithLabelValues(provider).Observe(duration.Seconds())
+}
+
+// ObserveRAGSearch records how many documents a RAG search returned
+func ObserveRAGSearch(results int) {
+	result := "hit"
+	if results == 0 {
+		result = "miss"
+	}
+	ragSearches.WithLabelValues(result).Inc()
+	ragDocumentsRetrieved.Observe(float64(results))
+}
+
+// ObserveScreenerRejection records a prompt rejected by the screener
+func ObserveScreenerRejection(reason string) {
+	screenerRejections.WithLabelValues(reason).Inc()
+}
+
+// ObservePromptInjection records a prompt injection attempt found in a
+// user message, document or web result
+func ObservePromptInjection(source, rule string) {
+	promptInjections.WithLabelValues(source, rule).Inc()
+}
+
+// ObservePII records personal information found in text bound for a
+// provider and whether it was allowed, tokenized or redacted
+func ObservePII(kind, action string) {
+	piiFound.WithLabelValues(kind, action).Inc()
+}
+
+// ObserveOutputReview records one reason the output safety filter blocked
+// or amended an answer
+func ObserveOutputReview(reason, role string) {
+	outputReviews.WithLabelValues(reason, role).Inc()
+}
+
+// RegisterDatabase exposes connection pool stats for SQLite and size and
+// block cache stats for Badger.
+func RegisterDatabase(sqlite *sql.DB, cache *badger.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(sqlite, "sqlite"))
+	prometheus.MustRegister(newBadgerCollector(cache))
+}
+
+// badgerCollector reads Badger stats at scrape time
+type badgerCollector struct {
+	db *badger.DB
+
+	size        *prometheus.Desc
+	cacheHits   *prometheus.Desc
+	cacheMisses *prometheus.Desc
+	cacheKeys   *prometheus.Desc
+}
+
+func newBadgerCollector(db *badger.DB) *badgerCollector {
+	return &badgerCollector{
+		db: db,
+		size: prometheus.NewDesc(namespace+"_badger_size_bytes",
+			"Badger on-disk size by component (lsm or vlog).", []string{"component"}, nil),
+		cacheHits: prometheus.NewDesc(namespace+"_badger_block_cache_hits_total",
+			"Badger block cache hits.", nil, nil),
+		cacheMisses: prometheus.NewDesc(namespace+"_badger_block_cache_misses_total",
+			"Badger block cache misses.", nil, nil),
+		cacheKeys: prometheus.NewDesc(namespace+"_badger_block_cache_keys_added_total",
+			"Keys added to the Badger block cache.", nil, nil),
+	}
+}
+
+func (b *badgerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- b.size
+	ch <- b.cacheHits
+	ch <- b.cacheMisses
+	ch <- b.cacheKeys
+}
+
+func (b *badgerCollector) Collect(ch chan<- prometheus.Metric) {
+	if b.db.IsClosed() {
+		return
+	}
+
+	lsm, vlog := b.db.Size()
+	ch <- prometheus.MustNewConstMetric(b.size, prometheus.GaugeValue, float64(lsm), "lsm")
+	ch <- prometheus.MustNewConstMetric(b.size, prometheus.GaugeValue, float64(vlog), "vlog")
+
+	if cache := b.db.BlockCacheMetrics(); cache != nil {
+		ch <- prometheus.MustNewConstMetric(b.cacheHits, prometheus.CounterValue, float64(cache.Hits()))
+		ch <- prometheus.MustNewConstMetric(b.cacheMisses, prometheus.CounterValue, float64(cache.Misses()))
+		ch <- prometheus.MustNewConstMetric(b.cacheKeys, prometheus.CounterValue, float64(cache.KeysAdded()))
+	}
+}
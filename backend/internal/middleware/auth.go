@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"serpico/backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BearerToken returns the token from the Authorization header, if any
+func BearerToken(c *gin.Context) string {
+	auth := c.GetHeader("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+}
+
+// Authenticate resolves the bearer token to a session and stores the user
+// in the context as "session", "userID" and "userRole". Requests without a
+// valid token continue anonymously; use RequireUser to reject them.
+func Authenticate(db *database.Database) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := BearerToken(c)
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		session, err := db.GetSession(token)
+		if err != nil {
+			if err != database.ErrSessionNotFound {
+				slog.ErrorContext(c.Request.Context(), "session lookup failed", "error", err)
+			}
+			c.Next()
+			return
+		}
+
+		c.Set("session", session)
+		c.Set("userID", session.UserID)
+		c.Set("userRole", session.Role)
+		c.Next()
+	}
+}
+
+// RequireUser rejects requests without an authenticated user
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("userID") == "" {
+			AbortWithError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole rejects requests from users without one of roles
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("userID") == "" {
+			AbortWithError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+			return
+		}
+		role := c.GetString("userRole")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		AbortWithError(c, http.StatusForbidden, ErrCodeForbidden, "Your role can't perform this action")
+	}
+}
+
+// CurrentSession returns the authenticated session, or nil
+func CurrentSession(c *gin.Context) *database.Session {
+	session, _ := c.Get("session")
+	s, _ := session.(*database.Session)
+	return s
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes returned in the "code" field of error responses
+const (
+	ErrCodeBadRequest   = "bad_request"
+	ErrCodeUnauthorized = "unauthorized"
+	ErrCodeForbidden    = "forbidden"
+	ErrCodeNotFound     = "not_found"
+	ErrCodeRateLimited  = "rate_limited"
+	ErrCodeInternal     = "internal_error"
+	ErrCodeUnavailable  = "service_unavailable"
+)
+
+// ErrorResponse is the body of every error response. Error stays a plain
+// string so existing clients reading response.data.error keep working.
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// AbortWithError aborts the request with the standard error envelope
+func AbortWithError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, ErrorResponse{
+		Error:     message,
+		Code:      code,
+		RequestID: c.GetString("requestID"),
+	})
+}
+
+// AbortWithInternalError logs err server-side and returns a generic message,
+// so SQL and upstream errors never leak to clients.
+func AbortWithInternalError(c *gin.Context, err error) {
+	LogInternalError(c, err)
+	AbortWithError(c, http.StatusInternalServerError, ErrCodeInternal, "An internal error occurred")
+}
+
+// LogInternalError logs err for a request whose response has already
+// started, e.g. a stream, where a status can no longer be sent
+func LogInternalError(c *gin.Context, err error) {
+	slog.ErrorContext(c.Request.Context(), "internal error",
+		"method", c.Request.Method,
+		"path", c.Request.URL.Path,
+		"error", err,
+	)
+}
+
+// Recovery turns panics into logged internal errors
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		AbortWithInternalError(c, fmt.Errorf("panic: %v", recovered))
+	})
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"time"
+
+	"serpico/backend/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records request counts and latencies per route. Unmatched paths
+// share one label so scanners can't blow up the series count.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit describes a token bucket: Burst tokens that refill at
+// Requests per Period.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// refillRate returns tokens added per second
+func (l RateLimit) refillRate() float64 {
+	if l.Period <= 0 {
+		return 0
+	}
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// RateLimitConfig holds per-route-group limits
+type RateLimitConfig struct {
+	Enabled bool
+	Store   string // "memory" or "badger"
+	Default RateLimit
+	Groups  map[string]RateLimit
+}
+
+// LoadRateLimitConfig reads limits from the environment. Each group can be
+// overridden with RATE_LIMIT_<GROUP>=<requests>/<period>[:burst], for
+// example RATE_LIMIT_CHAT=10/1m:5.
+func LoadRateLimitConfig() *RateLimitConfig {
+	config := &RateLimitConfig{
+		Enabled: os.Getenv("RATE_LIMIT_ENABLED") != "false",
+		Store:   os.Getenv("RATE_LIMIT_STORE"),
+		Default: RateLimit{Requests: 120, Period: time.Minute, Burst: 60},
+		Groups: map[string]RateLimit{
+			"chat":        {Requests: 10, Period: time.Minute, Burst: 5},
+			"admin_login": {Requests: 5, Period: 15 * time.Minute, Burst: 5},
+			"auth":        {Requests: 20, Period: time.Minute, Burst: 10},
+			"rag":         {Requests: 60, Period: time.Minute, Burst: 20},
+		},
+	}
+	if config.Store == "" {
+		config.Store = "badger"
+	}
+
+	if limit, ok := parseRateLimit(os.Getenv("RATE_LIMIT_DEFAULT")); ok {
+		config.Default = limit
+	}
+	for group := range config.Groups {
+		if limit, ok := parseRateLimit(os.Getenv("RATE_LIMIT_" + strings.ToUpper(group))); ok {
+			config.Groups[group] = limit
+		}
+	}
+
+	return config
+}
+
+// parseRateLimit parses "<requests>/<period>[:burst]"
+func parseRateLimit(value string) (RateLimit, bool) {
+	if value == "" {
+		return RateLimit{}, false
+	}
+
+	spec, burstStr, hasBurst := strings.Cut(value, ":")
+	reqStr, periodStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		slog.Warn("invalid rate limit, expected <requests>/<period>[:burst]", "value", value)
+		return RateLimit{}, false
+	}
+
+	requests, err := strconv.Atoi(reqStr)
+	if err != nil || requests <= 0 {
+		slog.Warn("invalid rate limit request count", "value", reqStr)
+		return RateLimit{}, false
+	}
+
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period <= 0 {
+		slog.Warn("invalid rate limit period", "value", periodStr)
+		return RateLimit{}, false
+	}
+
+	burst := requests
+	if hasBurst {
+		burst, err = strconv.Atoi(burstStr)
+		if err != nil || burst <= 0 {
+			slog.Warn("invalid rate limit burst", "value", burstStr)
+			return RateLimit{}, false
+		}
+	}
+
+	return RateLimit{Requests: requests, Period: period, Burst: burst}, true
+}
+
+// RateLimiter applies token-bucket limits per client and route group
+type RateLimiter struct {
+	config *RateLimitConfig
+	store  RateLimitStore
+}
+
+func NewRateLimiter(config *RateLimitConfig, store RateLimitStore) *RateLimiter {
+	return &RateLimiter{
+		config: config,
+		store:  store,
+	}
+}
+
+// Limit returns middleware enforcing the named group's limit. Unknown
+// groups fall back to the default limit.
+func (rl *RateLimiter) Limit(group string) gin.HandlerFunc {
+	limit, ok := rl.config.Groups[group]
+	if !ok {
+		limit = rl.config.Default
+	}
+
+	return func(c *gin.Context) {
+		if !rl.config.Enabled || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		key := group + ":" + clientKey(c)
+		result, err := rl.store.Take(key, limit, time.Now())
+		if err != nil {
+			// Fail open so a store problem doesn't take the API down
+			slog.ErrorContext(c.Request.Context(), "rate limit store error", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(result.ResetAt.Unix(), 10))
+
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			AbortWithError(c, http.StatusTooManyRequests, ErrCodeRateLimited, "Rate limit exceeded, try again later")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// clientKey identifies the caller by authenticated user, otherwise by IP.
+// An unvalidated bearer token isn't a key, as a new made-up token on every
+// request would get a fresh bucket each time.
+func clientKey(c *gin.Context) string {
+	if userID := c.GetString("userID"); userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+)
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAt    time.Time
+}
+
+// RateLimitStore persists token buckets
+type RateLimitStore interface {
+	Take(key string, limit RateLimit, now time.Time) (RateLimitResult, error)
+}
+
+// bucket is the persisted state of a single token bucket
+type bucket struct {
+	Tokens float64   `json:"tokens"`
+	Last   time.Time `json:"last"`
+}
+
+// take refills the bucket up to now and tries to remove one token
+func (b *bucket) take(limit RateLimit, now time.Time) RateLimitResult {
+	rate := limit.refillRate()
+	capacity := float64(limit.Burst)
+
+	if b.Last.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.Last).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+elapsed*rate)
+	}
+	b.Last = now
+
+	result := RateLimitResult{}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		result.Allowed = true
+	} else if rate > 0 {
+		result.RetryAfter = time.Duration((1 - b.Tokens) / rate * float64(time.Second))
+	} else {
+		result.RetryAfter = limit.Period
+	}
+
+	result.Remaining = int(math.Floor(b.Tokens))
+	if rate > 0 {
+		result.ResetAt = now.Add(time.Duration((capacity - b.Tokens) / rate * float64(time.Second)))
+	} else {
+		result.ResetAt = now
+	}
+
+	return result
+}
+
+// ttl is how long an idle bucket is kept before it would be full again
+func (b *bucket) ttl(limit RateLimit) time.Duration {
+	rate := limit.refillRate()
+	if rate <= 0 {
+		return limit.Period
+	}
+	return time.Duration(float64(limit.Burst)/rate*float64(time.Second)) + time.Minute
+}
+
+// MemoryRateLimitStore keeps buckets in process memory
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	expires map[string]time.Time
+	sweepAt time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*bucket),
+		expires: make(map[string]time.Time),
+	}
+}
+
+func (m *MemoryRateLimitStore) Take(key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Drop idle buckets once a minute so the map doesn't grow unbounded
+	if now.After(m.sweepAt) {
+		for k, expiresAt := range m.expires {
+			if now.After(expiresAt) {
+				delete(m.buckets, k)
+				delete(m.expires, k)
+			}
+		}
+		m.sweepAt = now.Add(time.Minute)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{}
+		m.buckets[key] = b
+	}
+
+	result := b.take(limit, now)
+	m.expires[key] = now.Add(b.ttl(limit))
+	return result, nil
+}
+
+// BadgerRateLimitStore keeps buckets in Badger so limits survive restarts
+type BadgerRateLimitStore struct {
+	db *badger.DB
+}
+
+func NewBadgerRateLimitStore(db *badger.DB) *BadgerRateLimitStore {
+	return &BadgerRateLimitStore{db: db}
+}
+
+func (s *BadgerRateLimitStore) Take(key string, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	var result RateLimitResult
+	var err error
+
+	// Requests racing on the same bucket conflict; retry with fresh state
+	for attempt := 0; attempt < 3; attempt++ {
+		result, err = s.take([]byte("ratelimit:"+key), limit, now)
+		if err != badger.ErrConflict {
+			break
+		}
+	}
+
+	return result, err
+}
+
+func (s *BadgerRateLimitStore) take(dbKey []byte, limit RateLimit, now time.Time) (RateLimitResult, error) {
+	var result RateLimitResult
+
+	err := s.db.Update(func(txn *badger.Txn) error {
+		b := bucket{}
+		item, err := txn.Get(dbKey)
+		switch {
+		case err == badger.ErrKeyNotFound:
+		case err != nil:
+			return err
+		default:
+			if err := item.Value(func(val []byte) error {
+				return json.Unmarshal(val, &b)
+			}); err != nil {
+				return err
+			}
+		}
+
+		result = b.take(limit, now)
+
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(badger.NewEntry(dbKey, data).WithTTL(b.ttl(limit)))
+	})
+
+	return result, err
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
 	"os"
+	"time"
 
 	"serpico/backend/internal/ai"
 	"serpico/backend/internal/api"
 	db "serpico/backend/internal/database"
+	"serpico/backend/internal/health"
+	"serpico/backend/internal/metrics"
 	"serpico/backend/internal/middleware"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -30,38 +36,91 @@ import (
 // @BasePath /api/v1
 
 func main() {
+	// Structured JSON logging; plain log.Printf output is routed through it too
+	logLevel := slog.LevelInfo
+	if os.Getenv("LOG_LEVEL") == "debug" {
+		logLevel = slog.LevelDebug
+	}
+	logHandler := middleware.NewLogHandler(
+		slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}),
+	)
+	slog.SetDefault(slog.New(logHandler))
+
 	// Initialize database
 	database, err := db.Initialize()
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal("failed to initialize database", err)
 	}
 	defer database.Close()
 
 	// Initialize AI service
 	aiConfig := ai.LoadConfig()
-	aiService, err := ai.NewAIService(aiConfig)
+	aiService, err := ai.NewAIService(aiConfig, ai.NewToolRegistry(ai.DatabaseTools(database)...), database)
 	if err != nil {
-		log.Fatalf("Failed to initialize AI service: %v", err)
+		fatal("failed to initialize AI service", err)
 	}
-	log.Println("AI service initialized successfully")
+	// From here on logs can hold chat text, so redact it under the PII policy
+	slog.SetDefault(slog.New(aiService.PII().LogHandler(logHandler)))
+	slog.Info("AI service initialized successfully")
+
+	// Keep RAG documents generated from cases, perps and emergencies
+	// current; the first sync runs in the background so startup isn't held
+	// up by embedding
+	records := ai.NewRecordSync(aiService.GetRAGDatabase(), database, aiConfig.RAGSyncInterval)
+	go records.Run(context.Background())
 
 	// Set up router
-	r := gin.Default()
+	r := gin.New()
+	r.Use(middleware.RequestID())
+	r.Use(middleware.Logger())
+	r.Use(middleware.Recovery())
+	r.Use(middleware.Metrics())
 
 	// CORS middleware
 	r.Use(middleware.CORS())
 
-	// Health check
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Rate limiting
+	rateLimitConfig := middleware.LoadRateLimitConfig()
+	var rateLimitStore middleware.RateLimitStore
+	if rateLimitConfig.Store == "memory" {
+		rateLimitStore = middleware.NewMemoryRateLimitStore()
+	} else {
+		rateLimitStore = middleware.NewBadgerRateLimitStore(database.Cache)
+	}
+	limiter := middleware.NewRateLimiter(rateLimitConfig, rateLimitStore)
+
+	// Health checks
+	checker := health.NewChecker()
+	checker.Register(health.Check{Name: "sqlite", Critical: true, Run: database.PingSQLite})
+	checker.Register(health.Check{Name: "badger", Run: database.PingCache})
+	checker.Register(health.Check{Name: "rag", Run: func(ctx context.Context) error {
+		if aiService.GetRAGDatabase().Count() == 0 {
+			return errors.New("no RAG documents loaded")
+		}
+		return nil
+	}})
+	if os.Getenv("HEALTH_CHECK_LLM") == "true" {
+		checker.Register(health.Check{
+			Name:     "llm",
+			Timeout:  5 * time.Second,
+			CacheFor: time.Minute,
+			Run:      aiService.CheckProvider,
+		})
+	}
+	r.GET("/health", checker.Ready)
+	r.GET("/health/live", checker.Live)
+	r.GET("/health/ready", checker.Ready)
 
 	// API routes
 	v1 := r.Group("/api/v1")
 	{
-		api.SetupRoutes(v1, database, aiService)
+		api.SetupRoutes(v1, database, aiService, records, limiter)
 	}
 
+	// Prometheus metrics
+	metrics.RegisterDatabase(database.SQLite, database.Cache)
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	// Swagger documentation
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -70,11 +129,16 @@ func main() {
 		port = "5092"
 	}
 
-	log.Printf("Server starting on port %s", port)
-	log.Printf("Swagger UI available at http://localhost:%s/swagger/index.html", port)
-	
+	slog.Info("server starting", "port", port)
+	slog.Info("swagger UI available", "url", "http://localhost:"+port+"/swagger/index.html")
+
 	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		fatal("failed to start server", err)
 	}
 }
 
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
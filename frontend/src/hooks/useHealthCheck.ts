@@ -8,10 +8,13 @@ export const useHealthCheck = () => {
   useEffect(() => {
     const checkHealth = async () => {
       try {
-        await axios.get(`${HEALTH_CHECK_URL}/health`, {
+        const response = await axios.get(`${HEALTH_CHECK_URL}/health`, {
           timeout: 5000, // 5 second timeout
         });
-        // Health check successful - backend is alive
+        // Backend is alive; a degraded status means some components are failing
+        if (response.data?.status === 'degraded') {
+          console.warn('Backend is degraded:', response.data.components);
+        }
       } catch (error) {
         // Health check failed - backend might be down
         // You could add error handling/notifications here if needed